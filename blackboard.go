@@ -0,0 +1,271 @@
+package cogent
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/gob"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	math "github.com/chewxy/math32"
+	"github.com/pkg/errors"
+)
+
+//Blackboard is where particles share their swarm's and the global best positions, under
+//globalKey and swarm_%d. Swarms in separate processes train together by sharing one, e.g. a
+//BlackboardHandler served to HTTPBlackboards. Positions are shared, not copied, so neither the
+//offerer nor anyone loading one may modify its layers.
+type Blackboard interface {
+	//Load returns the position stored under key, ok is false when there is none
+	Load(key string) (position Position, ok bool, err error)
+	//Offer stores the position when there is none under key or its loss is lower than the
+	//stored one's, returning whether it was stored
+	Offer(key string, position Position) (stored bool, err error)
+}
+
+//loadPosition is a position that must be on the blackboard, NewMultiSwarm stores them all
+func loadPosition(bb Blackboard, key string) (Position, error) {
+	position, ok, err := bb.Load(key)
+	if err != nil {
+		return Position{}, errors.Wrapf(err, "can't load '%s' position", key)
+	}
+	if !ok {
+		return Position{}, errors.Errorf("no '%s' position on the blackboard", key)
+	}
+	return position, nil
+}
+
+//memoryBlackboard is the in-process Blackboard NewMultiSwarm uses
+type memoryBlackboard struct {
+	mu        sync.RWMutex
+	positions map[string]Position
+}
+
+//NewMemoryBlackboard x
+func NewMemoryBlackboard() Blackboard {
+	return &memoryBlackboard{
+		positions: map[string]Position{},
+	}
+}
+
+func (bb *memoryBlackboard) Load(key string) (Position, bool, error) {
+	bb.mu.RLock()
+	defer bb.mu.RUnlock()
+	p, ok := bb.positions[key]
+	return p, ok, nil
+}
+
+func (bb *memoryBlackboard) Offer(key string, position Position) (bool, error) {
+	bb.mu.Lock()
+	defer bb.mu.Unlock()
+	if stored, ok := bb.positions[key]; ok && position.Loss >= stored.Loss {
+		return false, nil
+	}
+	bb.positions[key] = position
+	return true, nil
+}
+
+const blackboardPath = "/positions/"
+
+//blackboardContentType is gob, the same encoding NeuralNetwork.Marshal uses
+const blackboardContentType = "application/x-gob"
+
+//maxBlackboardPositionSize caps a POSTed position, far more than any network a swarm trains
+const maxBlackboardPositionSize = 64 << 20
+
+//checkPositionLayers is an error unless every layer of position has a known activation and weights
+func checkPositionLayers(position Position) error {
+	for i, l := range position.Layers {
+		if _, ok := activations[l.Activation]; !ok {
+			return invalidConfig("layer %d has unknown activation %d", i, l.Activation)
+		}
+		if l.WeightsAndBiases == nil {
+			return shapeMismatch("layer %d weights are missing", i)
+		}
+	}
+	return nil
+}
+
+//checkPosition is an error unless position's layers have the node counts, activations and
+//weight shapes of expected, the layers of a position already stored or of a local network
+func checkPosition(expected []LayerData, position Position) error {
+	if err := checkPositionLayers(position); err != nil {
+		return err
+	}
+	if len(position.Layers) != len(expected) {
+		return shapeMismatch("position has %d layers, not %d", len(position.Layers), len(expected))
+	}
+	for i, l := range position.Layers {
+		e := expected[i]
+		if l.NodeCount != e.NodeCount {
+			return shapeMismatch("layer %d has %d nodes, not %d", i, l.NodeCount, e.NodeCount)
+		}
+		if l.Activation != e.Activation {
+			return invalidConfig("layer %d activation is %s, not %s", i, l.Activation, e.Activation)
+		}
+		if e.WeightsAndBiases != nil && !l.WeightsAndBiases.Shape().Eq(e.WeightsAndBiases.Shape()) {
+			return shapeMismatch("layer %d weights are %v, not %v", i, l.WeightsAndBiases.Shape(), e.WeightsAndBiases.Shape())
+		}
+	}
+	return nil
+}
+
+//BlackboardHandler serves bb over HTTP for HTTPBlackboards, GET /positions/{key} loads and
+//POST /positions/{key} offers a gob encoded Position, which has to have a finite loss and the
+//same layers as the position already stored under key. A non empty token is required as a
+//bearer token on every request. Anyone who can reach the handler steers every swarm sharing
+//bb, so don't expose it beyond the machines training together.
+func BlackboardHandler(bb Blackboard, token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, blackboardPath) {
+			http.NotFound(w, r)
+			return
+		}
+		if token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		key := strings.TrimPrefix(r.URL.Path, blackboardPath)
+
+		var res interface{}
+		switch r.Method {
+		case http.MethodGet:
+			position, ok, err := bb.Load(key)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			res = position
+		case http.MethodPost:
+			position := Position{}
+			body := http.MaxBytesReader(w, r.Body, maxBlackboardPositionSize)
+			if err := gob.NewDecoder(body).Decode(&position); err != nil {
+				http.Error(w, "can't decode position: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			//a loss no training can beat would steer every swarm for good
+			if math.IsNaN(position.Loss) || math.IsInf(position.Loss, 0) {
+				http.Error(w, "position loss isn't finite", http.StatusBadRequest)
+				return
+			}
+			current, ok, err := bb.Load(key)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if ok {
+				err = checkPosition(current.Layers, position)
+			} else {
+				err = checkPositionLayers(position)
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			stored, err := bb.Offer(key, position)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			res = stored
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		buf := bytes.Buffer{}
+		if err := gob.NewEncoder(&buf).Encode(res); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", blackboardContentType)
+		w.Write(buf.Bytes())
+	})
+}
+
+//httpBlackboard is a Blackboard served by a BlackboardHandler in another process
+type httpBlackboard struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+//DefaultBlackboardTimeout bounds each request of an HTTPBlackboard made without a client, so a
+//stalled blackboard fails training rather than hanging it
+const DefaultBlackboardTimeout = 30 * time.Second
+
+//NewHTTPBlackboard uses the BlackboardHandler served at baseURL with the same token, empty when
+//it has none, client defaults to one with DefaultBlackboardTimeout
+func NewHTTPBlackboard(baseURL, token string, client *http.Client) Blackboard {
+	if client == nil {
+		client = &http.Client{Timeout: DefaultBlackboardTimeout}
+	}
+	return &httpBlackboard{
+		url:    strings.TrimRight(baseURL, "/") + blackboardPath,
+		token:  token,
+		client: client,
+	}
+}
+
+func (bb *httpBlackboard) do(req *http.Request, v interface{}) (bool, error) {
+	if bb.token != "" {
+		req.Header.Set("Authorization", "Bearer "+bb.token)
+	}
+	res, err := bb.client.Do(req)
+	if err != nil {
+		return false, errors.Wrap(err, "can't reach blackboard")
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		if err := gob.NewDecoder(res.Body).Decode(v); err != nil {
+			return false, errors.Wrap(err, "can't decode blackboard response")
+		}
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return false, errors.Errorf("blackboard responded %s: %s", res.Status, strings.TrimSpace(string(msg)))
+	}
+}
+
+func (bb *httpBlackboard) Load(key string) (Position, bool, error) {
+	position := Position{}
+	req, err := http.NewRequest(http.MethodGet, bb.url+url.PathEscape(key), nil)
+	if err != nil {
+		return position, false, errors.Wrap(err, "can't create blackboard request")
+	}
+	ok, err := bb.do(req, &position)
+	if err == nil && ok {
+		err = errors.Wrapf(checkPositionLayers(position), "blackboard position '%s'", key)
+	}
+	return position, ok, err
+}
+
+func (bb *httpBlackboard) Offer(key string, position Position) (bool, error) {
+	buf := bytes.Buffer{}
+	if err := gob.NewEncoder(&buf).Encode(position); err != nil {
+		return false, errors.Wrap(err, "can't encode position")
+	}
+	req, err := http.NewRequest(http.MethodPost, bb.url+url.PathEscape(key), &buf)
+	if err != nil {
+		return false, errors.Wrap(err, "can't create blackboard request")
+	}
+	req.Header.Set("Content-Type", blackboardContentType)
+
+	stored := false
+	if _, err := bb.do(req, &stored); err != nil {
+		return false, err
+	}
+	return stored, nil
+}
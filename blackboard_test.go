@@ -0,0 +1,163 @@
+package cogent
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	math "github.com/chewxy/math32"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Blackboard(t *testing.T) {
+	bb := NewMemoryBlackboard()
+	_, ok, err := bb.Load(globalKey)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	for _, c := range []struct {
+		loss   float32
+		stored bool
+	}{
+		{math.MaxFloat32, true},
+		{0.5, true},
+		{0.75, false},
+		{0.5, false},
+		{0.25, true},
+	} {
+		stored, err := bb.Offer(globalKey, Position{Loss: c.loss})
+		assert.Nil(t, err)
+		assert.Equal(t, c.stored, stored, c.loss)
+	}
+	position, ok, err := bb.Load(globalKey)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, float32(0.25), position.Loss)
+
+	// two processes' swarms training against one blackboard server
+	data := Data{
+		{Inputs: []float32{0, 0}, Outputs: []float32{0, 1}},
+		{Inputs: []float32{1, 1}, Outputs: []float32{1, 0}},
+	}
+	bucket, err := DataToTensorDataBucket(data, true)
+	assert.Nil(t, err)
+	buckets, err := DataBucketToBuckets(2, bucket)
+	assert.Nil(t, err)
+
+	shared := NewMemoryBlackboard()
+	server := httptest.NewServer(BlackboardHandler(shared, ""))
+	defer server.Close()
+
+	tc := DefaultTrainingConfig
+	tc.MaxIterations = 5
+	swarms := make([]*MultiSwarm, 2)
+	for i := range swarms {
+		config := basicMathConfig(data)
+		config.FirstSwarmID = i * config.SwarmCount
+		swarms[i], err = NewMultiSwarmWithBlackboard(config, tc, NewHTTPBlackboard(server.URL, "", nil))
+		assert.Nil(t, err)
+		swarms[i].SetLogger(NopLogger)
+	}
+	wg := sync.WaitGroup{}
+	for _, ms := range swarms {
+		wg.Add(1)
+		go func(ms *MultiSwarm) {
+			defer wg.Done()
+			assert.Nil(t, ms.Train(buckets, false))
+		}(ms)
+	}
+	wg.Wait()
+
+	global, ok, err := shared.Load(globalKey)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	for i := 0; i < 2*basicMathConfig(data).SwarmCount; i++ {
+		swarm, ok, err := shared.Load(fmt.Sprintf(swarmKeyFormat, i))
+		assert.Nil(t, err)
+		assert.True(t, ok, i)
+		assert.True(t, global.Loss <= swarm.Loss, i)
+	}
+	for _, ms := range swarms {
+		nn, err := ms.Best()
+		assert.Nil(t, err)
+		assert.Equal(t, global.Loss, nn.Best.Loss)
+	}
+
+	// a stalled blackboard times out instead of hanging training
+	stalled := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer stalled.Close()
+	assert.Equal(t, DefaultBlackboardTimeout, NewHTTPBlackboard(stalled.URL, "", nil).(*httpBlackboard).client.Timeout)
+	_, _, err = NewHTTPBlackboard(stalled.URL, "", &http.Client{Timeout: 50 * time.Millisecond}).Load(globalKey)
+	assert.NotNil(t, err)
+
+	// a position has to match the layers of the first one stored under its key
+	remote := NewHTTPBlackboard(server.URL, "", nil)
+	nn := testNeuralNetwork(2, LayerConfig{NodeCount: 2, Activation: Softmax})
+	_, err = remote.Offer(globalKey, nnToPosition(0, nn))
+	assert.NotNil(t, err)
+	wider := testNeuralNetwork(3, LayerConfig{NodeCount: 4, Activation: ReLU}, LayerConfig{NodeCount: 2, Activation: Softmax})
+	_, err = remote.Offer(globalKey, nnToPosition(0, wider))
+	assert.NotNil(t, err)
+	for _, change := range []func(l *LayerData){
+		func(l *LayerData) { l.Activation = ActivationMode(99) },
+		func(l *LayerData) { l.Activation = Softmax },
+		func(l *LayerData) { l.NodeCount++ },
+	} {
+		changed := Position{Layers: make([]LayerData, len(global.Layers))}
+		for i, l := range global.Layers {
+			changed.Layers[i] = l.Clone()
+		}
+		change(&changed.Layers[0])
+		_, err = remote.Offer(globalKey, changed)
+		assert.NotNil(t, err)
+	}
+	for _, loss := range []float32{math.Inf(-1), math.NaN()} {
+		_, err = remote.Offer(globalKey, Position{Loss: loss, Layers: global.Layers})
+		assert.NotNil(t, err)
+	}
+	position, _, err = shared.Load(globalKey)
+	assert.Nil(t, err)
+	assert.Equal(t, global.Loss, position.Loss)
+
+	// a global best that doesn't fit the network isn't used to predict
+	unknown := Position{Layers: make([]LayerData, len(global.Layers))}
+	for i, l := range global.Layers {
+		unknown.Layers[i] = l.Clone()
+	}
+	unknown.Layers[0].Activation = ActivationMode(99)
+	_, err = shared.Offer(globalKey, unknown)
+	assert.Nil(t, err)
+	_, _, err = remote.Load(globalKey)
+	assert.Equal(t, ErrInvalidConfig, errors.Cause(err))
+	local := NewMemoryBlackboard()
+	ms, err := NewMultiSwarmWithBlackboard(basicMathConfig(data), tc, local)
+	assert.Nil(t, err)
+	ms.SetLogger(NopLogger)
+	assert.Nil(t, ms.Train(buckets, false))
+	_, err = local.Offer(globalKey, unknown)
+	assert.Nil(t, err)
+	nn, err = ms.Best()
+	assert.Nil(t, err)
+	assert.NotEqual(t, float32(0), nn.Best.Loss)
+	_, err = ms.Predict(bucket.Inputs)
+	assert.Nil(t, err)
+
+	// bodies are capped, the gob message length claims just over the cap
+	prefix := make([]byte, 5)
+	prefix[0] = 0xFC
+	binary.BigEndian.PutUint32(prefix[1:], maxBlackboardPositionSize+1)
+	body := io.MultiReader(bytes.NewReader(prefix), bytes.NewReader(make([]byte, maxBlackboardPositionSize+1)))
+	res := httptest.NewRecorder()
+	BlackboardHandler(shared, "").ServeHTTP(res, httptest.NewRequest(http.MethodPost, blackboardPath+globalKey, body))
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+	assert.Contains(t, res.Body.String(), "too large")
+}
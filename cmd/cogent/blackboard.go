@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/delaneyj/cogent"
+)
+
+//blackboardTokenEnv is where blackboard and train read a shared token from when it isn't passed
+//as a flag, which other users of the machine could read from the process list
+const blackboardTokenEnv = "COGENT_BLACKBOARD_TOKEN"
+
+func blackboard(args []string) error {
+	server := blackboardServer(args)
+	logger := cogent.NewLogger(os.Stderr, cogent.InfoLevel)
+	logger.Info("serving blackboard", "addr", server.Addr)
+	return server.ListenAndServe()
+}
+
+//blackboardServer parses the flags into a server that isn't listening yet
+func blackboardServer(args []string) *http.Server {
+	fs := flag.NewFlagSet("blackboard", flag.ExitOnError)
+	addr := fs.String("addr", "localhost:8070", "address to listen on, pass http://host:port to train's -blackboard. Anyone who can reach it steers training, listen beyond localhost only with a -token on a trusted network")
+	token := fs.String("token", os.Getenv(blackboardTokenEnv), "token train has to send with -blackboard-token, defaults to $"+blackboardTokenEnv)
+	fs.Parse(args)
+
+	return &http.Server{
+		Addr:              *addr,
+		Handler:           cogent.BlackboardHandler(cogent.NewMemoryBlackboard(), *token),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+}
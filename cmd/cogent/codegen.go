@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+
+	"github.com/delaneyj/cogent"
+	"github.com/pkg/errors"
+)
+
+func codegen(args []string) error {
+	fs := flag.NewFlagSet("codegen", flag.ExitOnError)
+	modelPath := fs.String("model", "", "model file written by train")
+	outPath := fs.String("out", "", "Go file to write, defaults to stdout")
+	packageName := fs.String("package", "model", "package name of the generated file")
+	fs.Parse(args)
+
+	nn, err := loadModel(*modelPath)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *outPath != "" {
+		f, err := os.Create(*outPath)
+		if err != nil {
+			return errors.Wrap(err, "can't create output")
+		}
+		defer f.Close()
+		w = f
+	}
+	return nn.GenerateGo(w, *packageName)
+}
+
+func loadModel(path string) (*cogent.NeuralNetwork, error) {
+	if path == "" {
+		return nil, errors.New("-model is required")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't open model")
+	}
+	defer f.Close()
+
+	nn, err := cogent.Load(f)
+	return nn, errors.Wrapf(err, "can't load model '%s'", path)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"io/ioutil"
+
+	"github.com/delaneyj/cogent"
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v3"
+)
+
+//config is read from YAML, or JSON which is valid YAML
+type config struct {
+	Columns  []cogent.ColumnSpec `yaml:"columns"`
+	Network  networkConfig       `yaml:"network"`
+	Swarm    swarmConfig         `yaml:"swarm"`
+	Training trainingConfig      `yaml:"training"`
+}
+
+type networkConfig struct {
+	Loss   cogent.LossMode `yaml:"loss"`
+	Layers []layerConfig   `yaml:"layers"`
+}
+
+type layerConfig struct {
+	//Nodes of the last layer default to the encoded output width
+	Nodes      int                   `yaml:"nodes"`
+	Activation cogent.ActivationMode `yaml:"activation"`
+}
+
+type swarmConfig struct {
+	Swarms      int  `yaml:"swarms"`
+	Particles   int  `yaml:"particles"`
+	Folds       int  `yaml:"folds"`
+	Multithread bool `yaml:"multithread"`
+	//Islands, when set, evolves the swarms as islands with periodic migration
+	Islands *cogent.IslandConfiguration `yaml:"islands"`
+}
+
+type trainingConfig struct {
+	InertialWeight        *float32 `yaml:"inertialWeight"`
+	CognitiveWeight       *float32 `yaml:"cognitiveWeight"`
+	SocialWeight          *float32 `yaml:"socialWeight"`
+	GlobalWeight          *float32 `yaml:"globalWeight"`
+	MaxIterations         *int     `yaml:"maxIterations"`
+	TargetAccuracy        *float32 `yaml:"targetAccuracy"`
+	WeightRange           *float32 `yaml:"weightRange"`
+	ProbablityOfDeath     *float32 `yaml:"probabilityOfDeath"`
+	RidgeRegressionWeight *float32 `yaml:"ridgeRegressionWeight"`
+	Parallelism           *int     `yaml:"parallelism"`
+	ParallelBuckets       *bool    `yaml:"parallelBuckets"`
+	BatchSize             *int     `yaml:"batchSize"`
+	ReevaluationSize      *int     `yaml:"reevaluationSize"`
+}
+
+func loadConfig(path string) (*config, error) {
+	if path == "" {
+		return nil, errors.New("-config is required")
+	}
+
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't read config")
+	}
+
+	c := &config{
+		Network: networkConfig{
+			Loss: cogent.CrossLoss,
+		},
+		Swarm: swarmConfig{
+			Swarms:      4,
+			Particles:   8,
+			Folds:       4,
+			Multithread: true,
+		},
+	}
+	if err := yaml.Unmarshal(buf, c); err != nil {
+		return nil, errors.Wrapf(err, "can't parse config '%s'", path)
+	}
+
+	if len(c.Columns) == 0 {
+		return nil, errors.New("config has no columns")
+	}
+	if len(c.Network.Layers) == 0 {
+		return nil, errors.New("config has no network layers")
+	}
+	return c, nil
+}
+
+//trainingConfiguration overrides the library defaults with whatever the config sets
+func (tc trainingConfig) trainingConfiguration() cogent.TrainingConfiguration {
+	c := cogent.DefaultTrainingConfig
+	setFloat := func(dst *float32, src *float32) {
+		if src != nil {
+			*dst = *src
+		}
+	}
+	setFloat(&c.InertialWeight, tc.InertialWeight)
+	setFloat(&c.CognitiveWeight, tc.CognitiveWeight)
+	setFloat(&c.SocialWeight, tc.SocialWeight)
+	setFloat(&c.GlobalWeight, tc.GlobalWeight)
+	setFloat(&c.TargetAccuracy, tc.TargetAccuracy)
+	setFloat(&c.WeightRange, tc.WeightRange)
+	setFloat(&c.ProbablityOfDeath, tc.ProbablityOfDeath)
+	setFloat(&c.RidgeRegressionWeight, tc.RidgeRegressionWeight)
+	if tc.MaxIterations != nil {
+		c.MaxIterations = *tc.MaxIterations
+	}
+	if tc.Parallelism != nil {
+		c.Parallelism = *tc.Parallelism
+	}
+	if tc.ParallelBuckets != nil {
+		c.ParallelBuckets = *tc.ParallelBuckets
+	}
+	if tc.BatchSize != nil {
+		c.BatchSize = *tc.BatchSize
+	}
+	if tc.ReevaluationSize != nil {
+		c.ReevaluationSize = *tc.ReevaluationSize
+	}
+	return c
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/delaneyj/cogent"
+	"github.com/pkg/errors"
+)
+
+//csvFlags are the flags shared by every command that reads a csv
+type csvFlags struct {
+	path      *string
+	delimiter *string
+}
+
+func addCSVFlags(fs *flag.FlagSet, usage string) csvFlags {
+	return csvFlags{
+		path:      fs.String("data", "", usage),
+		delimiter: fs.String("delimiter", "", `field delimiter, "tab" for TSV, defaults to tab for .tsv files and comma otherwise`),
+	}
+}
+
+//open returns the csv file and how to read it, the caller closes the file
+func (cf csvFlags) open() (*os.File, cogent.CSVOptions, error) {
+	opts := cogent.CSVOptions{}
+	if *cf.path == "" {
+		return nil, opts, errors.New("-data is required")
+	}
+
+	switch d := *cf.delimiter; {
+	case d == "" && strings.EqualFold(filepath.Ext(*cf.path), ".tsv"), d == "tab", d == `\t`:
+		opts.Delimiter = '\t'
+	case d == "":
+	case utf8.RuneCountInString(d) == 1:
+		opts.Delimiter, _ = utf8.DecodeRuneInString(d)
+	default:
+		return nil, opts, errors.Errorf("delimiter '%s' isn't a single character", d)
+	}
+
+	f, err := os.Open(*cf.path)
+	if err != nil {
+		return nil, opts, errors.Wrap(err, "can't open data")
+	}
+	return f, opts, nil
+}
+
+//columnNames lists the names of the columns with the given role, in spec order
+func columnNames(columns []cogent.ColumnSpec, role cogent.ColumnRole) []string {
+	var names []string
+	for _, c := range columns {
+		if c.Role == role {
+			names = append(names, c.Name)
+		}
+	}
+	return names
+}
+
+//loadCSV encodes a csv with the encoders saved in the model, outputs are only read when
+//withOutputs is set
+func loadCSV(cf csvFlags, meta cogent.ModelMetadata, withOutputs bool) (cogent.Data, error) {
+	if meta.InputEncoder == nil || (withOutputs && meta.OutputEncoder == nil) {
+		return nil, errors.New("model has no saved encoders")
+	}
+
+	f, opts, err := cf.open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var outputEncoder *cogent.TableEncoder
+	if withOutputs {
+		outputEncoder = meta.OutputEncoder
+	}
+	data, err := cogent.LoadCSVWithEncoders(f, meta.Columns, meta.InputEncoder, outputEncoder, opts)
+	return data, errors.Wrapf(err, "can't load '%s'", *cf.path)
+}
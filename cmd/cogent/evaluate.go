@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/delaneyj/cogent"
+	"github.com/pkg/errors"
+)
+
+type evaluation struct {
+	rows     int
+	loss     float32
+	rmse     float64
+	accuracy float32
+}
+
+func evaluate(args []string) error {
+	fs := flag.NewFlagSet("evaluate", flag.ExitOnError)
+	modelPath := fs.String("model", "", "model file written by train")
+	cf := addCSVFlags(fs, "labelled csv with a header row")
+	fs.Parse(args)
+
+	nn, err := loadModel(*modelPath)
+	if err != nil {
+		return err
+	}
+
+	data, err := loadCSV(cf, nn.Metadata, true)
+	if err != nil {
+		return err
+	}
+
+	e, err := evaluateData(nn, data)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stdout, "rows      %d\n", e.rows)
+	fmt.Fprintf(os.Stdout, "loss      %f (%s)\n", e.loss, nn.Loss)
+	fmt.Fprintf(os.Stdout, "rmse      %f\n", e.rmse)
+	fmt.Fprintf(os.Stdout, "accuracy  %0.2f%%\n", 100*e.accuracy)
+	return nil
+}
+
+func evaluateData(nn *cogent.NeuralNetwork, data cogent.Data) (evaluation, error) {
+	p, err := cogent.NewPredictor(nn, cogent.PredictorConfiguration{})
+	if err != nil {
+		return evaluation{}, err
+	}
+
+	inputs := make([][]float32, len(data))
+	expected := make([][]float32, len(data))
+	for i, row := range data {
+		inputs[i] = row.Inputs
+		expected[i] = row.Outputs
+	}
+
+	actual, err := p.Predict(inputs)
+	if err != nil {
+		return evaluation{}, err
+	}
+
+	e := evaluation{
+		rows: len(data),
+		loss: cogent.LossFns[nn.Loss](expected, actual) / float32(len(data)),
+	}
+	var squared float64
+	var count, correct int
+	for i, a := range actual {
+		if len(a) != len(expected[i]) {
+			return e, errors.Errorf("row %d has %d outputs, model predicts %d", i, len(expected[i]), len(a))
+		}
+		for j, x := range a {
+			d := float64(x - expected[i][j])
+			squared += d * d
+			count++
+		}
+		if argmax(a) == argmax(expected[i]) {
+			correct++
+		}
+	}
+	e.rmse = math.Sqrt(squared / float64(count))
+	e.accuracy = float32(correct) / float32(len(data))
+	return e, nil
+}
+
+func argmax(row []float32) int {
+	best := 0
+	for i, x := range row {
+		if x > row[best] {
+			best = i
+		}
+	}
+	return best
+}
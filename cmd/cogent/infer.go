@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/delaneyj/cogent"
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v3"
+)
+
+func infer(args []string) error {
+	fs := flag.NewFlagSet("infer", flag.ExitOnError)
+	cf := addCSVFlags(fs, "csv with a header row to profile")
+	outputs := fs.String("outputs", "", "comma separated names of the columns to predict")
+	maxOneHot := fs.Int("max-one-hot", cogent.DefaultInferenceOptions.MaxOneHotCardinality, "most categories to one-hot encode")
+	fs.Parse(args)
+
+	f, opts, err := cf.open()
+	if err != nil {
+		return err
+	}
+	header, rows, err := cogent.ReadCSVTable(f, opts)
+	f.Close()
+	if err != nil {
+		return errors.Wrapf(err, "can't load '%s'", *cf.path)
+	}
+
+	specs, report, err := cogent.InferEncodings(rows, header, cogent.InferenceOptions{
+		MaxOneHotCardinality: *maxOneHot,
+	})
+	if err != nil {
+		return err
+	}
+
+	if *outputs != "" {
+		for _, name := range strings.Split(*outputs, ",") {
+			found := false
+			for i := range specs {
+				if specs[i].Name == name {
+					specs[i].Role = cogent.OutputRole
+					found = true
+				}
+			}
+			if !found {
+				return errors.Errorf("output column '%s' isn't in the csv header", name)
+			}
+		}
+	}
+
+	fmt.Fprint(os.Stderr, report)
+	return yaml.NewEncoder(os.Stdout).Encode(struct {
+		Columns []cogent.ColumnSpec `yaml:"columns"`
+	}{specs})
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/delaneyj/cogent"
+	"github.com/pkg/errors"
+)
+
+func inspect(args []string) error {
+	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
+	modelPath := fs.String("model", "", "model file written by train")
+	fs.Parse(args)
+
+	nn, err := loadModel(*modelPath)
+	if err != nil {
+		return err
+	}
+
+	m := nn.Metadata
+	c := nn.Configuration()
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "created\t%s\n", m.CreatedAt)
+	fmt.Fprintf(w, "loss\t%s\n", nn.Loss)
+	fmt.Fprintf(w, "inputs\t%d\n", c.InputCount)
+
+	weightCount := 0
+	for i, l := range nn.Layers {
+		s := l.WeightsAndBiases.Shape()
+		weightCount += s[0] * s[1]
+		fmt.Fprintf(w, "layer %d\t%d nodes\t%s\t%dx%d weights\n", i, l.NodeCount, l.Activation, s[0], s[1])
+	}
+	fmt.Fprintf(w, "weights\t%d\n", weightCount)
+	fmt.Fprintf(w, "metrics\tloss %f\trmse %f\taccuracy %0.2f%%\n", m.Metrics.Loss, m.Metrics.RMSE, 100*m.Metrics.Accuracy)
+	schemas := map[cogent.ColumnRole]cogent.EncodedSchema{}
+	for role, te := range map[cogent.ColumnRole]*cogent.TableEncoder{
+		cogent.InputRole:  m.InputEncoder,
+		cogent.OutputRole: m.OutputEncoder,
+	} {
+		if te == nil {
+			continue
+		}
+		s, err := te.Schema(columnNames(m.Columns, role))
+		if err != nil {
+			return errors.Wrapf(err, "can't describe %s columns", role)
+		}
+		schemas[role] = s
+	}
+
+	for _, col := range m.Columns {
+		features := ""
+		if ec, ok := schemas[col.Role].Column(col.Name); ok {
+			features = fmt.Sprintf("features %d-%d", ec.Start, ec.End-1)
+		}
+		fmt.Fprintf(w, "column\t%s\t%s\t%s\t%s\n", col.Name, col.Role, col.Encoding, features)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+var commands = []command{
+	{"infer", "profile a csv and propose column encodings", infer},
+	{"train", "train a model from a labelled csv", train},
+	{"evaluate", "report metrics of a model on a labelled csv", evaluate},
+	{"predict", "predict every row of a csv", predict},
+	{"inspect", "summarise a model file", inspect},
+	{"codegen", "generate a dependency free Go predictor from a model", codegen},
+	{"serve", "serve models over HTTP and JSON, reloading them when they change", serveModels},
+	{"blackboard", "share bests between train processes over HTTP", blackboard},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: cogent <command> [flags]\n\ncommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.name, c.summary)
+	}
+}
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	name, args := os.Args[1], os.Args[2:]
+	for _, c := range commands {
+		if c.name == name {
+			if err := c.run(args); err != nil {
+				log.Fatalf("cogent %s: %v", name, err)
+			}
+			return
+		}
+	}
+
+	usage()
+	os.Exit(2)
+}
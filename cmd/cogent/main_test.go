@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/delaneyj/cogent"
+	"github.com/stretchr/testify/assert"
+)
+
+//captureStdout runs fn with os.Stdout sent to a file and returns what it wrote
+func captureStdout(t *testing.T, fn func() error) string {
+	f, err := ioutil.TempFile(t.TempDir(), "stdout")
+	assert.Nil(t, err)
+	defer f.Close()
+
+	stdout := os.Stdout
+	os.Stdout = f
+	err = fn()
+	os.Stdout = stdout
+	assert.Nil(t, err)
+
+	buf, err := ioutil.ReadFile(f.Name())
+	assert.Nil(t, err)
+	return string(buf)
+}
+
+func Test_Commands(t *testing.T) {
+	dir := t.TempDir()
+	dataPath := filepath.Join(dir, "data.csv")
+	rows := []string{"width,height,colour,label"}
+	for i := 0; i < 24; i++ {
+		x, y := i%4, i/4%3
+		label := "low"
+		if x+y > 2 {
+			label = "high"
+		}
+		rows = append(rows, fmt.Sprintf("%d,%d,%s,%s", x, y, []string{"red", "green", "blue"}[i%3], label))
+	}
+	assert.Nil(t, ioutil.WriteFile(dataPath, []byte(strings.Join(rows, "\n")+"\n"), 0644))
+
+	// infer proposes the columns train is configured with
+	columns := captureStdout(t, func() error {
+		return infer([]string{"-data", dataPath, "-outputs", "label"})
+	})
+	assert.True(t, strings.HasPrefix(columns, "columns:"), columns)
+	for _, name := range []string{"width", "height", "colour", "label"} {
+		assert.Contains(t, columns, "name: "+name)
+	}
+
+	configPath := filepath.Join(dir, "config.yaml")
+	config := columns + `
+network:
+  loss: CrossLoss
+  layers:
+    - {nodes: 4, activation: LeakyReLU}
+    - {activation: Softmax}
+swarm:
+  swarms: 1
+  particles: 2
+  folds: 2
+training:
+  maxIterations: 2
+`
+	assert.Nil(t, ioutil.WriteFile(configPath, []byte(config), 0644))
+
+	modelPath := filepath.Join(dir, "model.nn")
+	assert.Nil(t, train([]string{"-data", dataPath, "-config", configPath, "-out", modelPath}))
+	nn, err := loadModel(modelPath)
+	assert.Nil(t, err)
+	assert.Len(t, nn.Layers, 2)
+
+	evaluation := captureStdout(t, func() error {
+		return evaluate([]string{"-model", modelPath, "-data", dataPath})
+	})
+	assert.Contains(t, evaluation, "rows      24\n")
+	assert.Contains(t, evaluation, "accuracy")
+
+	predictionsPath := filepath.Join(dir, "predictions.csv")
+	assert.Nil(t, predict([]string{"-model", modelPath, "-data", dataPath, "-out", predictionsPath}))
+	f, err := os.Open(predictionsPath)
+	assert.Nil(t, err)
+	predictions, err := csv.NewReader(f).ReadAll()
+	f.Close()
+	assert.Nil(t, err)
+	assert.Len(t, predictions, 25)
+	assert.Equal(t, []string{"label"}, predictions[0])
+	for _, p := range predictions[1:] {
+		assert.Contains(t, []string{"low", "high"}, p[0])
+	}
+
+	summary := captureStdout(t, func() error {
+		return inspect([]string{"-model", modelPath})
+	})
+	assert.Contains(t, summary, "layer 0")
+	assert.Contains(t, summary, "layer 1")
+	assert.Regexp(t, `column\s+colour\s+input\s+OneHotEncodingMode`, summary)
+
+	goPath := filepath.Join(dir, "model.go")
+	assert.Nil(t, codegen([]string{"-model", modelPath, "-package", "iris", "-out", goPath}))
+	source, err := ioutil.ReadFile(goPath)
+	assert.Nil(t, err)
+	assert.Contains(t, string(source), "package iris")
+
+	_, _, err = modelServer(nil, cogent.NopLogger)
+	assert.NotNil(t, err)
+	server, _, err := modelServer([]string{"-model", "labels=" + modelPath, "-addr", ":0"}, cogent.NopLogger)
+	assert.Nil(t, err)
+	assert.Equal(t, ":0", server.Addr)
+	res := httptest.NewRecorder()
+	server.Handler.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Contains(t, res.Body.String(), `"labels"`)
+	res = httptest.NewRecorder()
+	body := strings.NewReader(`{"row":{"width":"3","height":"2","colour":"red"}}`)
+	server.Handler.ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/models/labels/predict", body))
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+}
+
+func Test_BlackboardCommand(t *testing.T) {
+	assert.Equal(t, "localhost:8070", blackboardServer(nil).Addr)
+	server := blackboardServer([]string{"-addr", ":0", "-token", "secret"})
+	assert.Equal(t, ":0", server.Addr)
+	ts := httptest.NewServer(server.Handler)
+	defer ts.Close()
+
+	_, _, err := cogent.NewHTTPBlackboard(ts.URL, "", nil).Load("global")
+	assert.NotNil(t, err)
+	_, _, err = cogent.NewHTTPBlackboard(ts.URL, "guess", nil).Load("global")
+	assert.NotNil(t, err)
+	bb := cogent.NewHTTPBlackboard(ts.URL, "secret", nil)
+	_, ok, err := bb.Load("global")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	stored, err := bb.Offer("global", cogent.Position{Loss: 0.5})
+	assert.Nil(t, err)
+	assert.True(t, stored)
+	position, ok, err := bb.Load("global")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, float32(0.5), position.Loss)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/delaneyj/cogent"
+	"github.com/pkg/errors"
+)
+
+func predict(args []string) error {
+	fs := flag.NewFlagSet("predict", flag.ExitOnError)
+	modelPath := fs.String("model", "", "model file written by train")
+	cf := addCSVFlags(fs, "csv with a header row containing the input columns")
+	outPath := fs.String("out", "", "csv to write predictions to, defaults to stdout")
+	raw := fs.Bool("raw", false, "write the network's outputs instead of decoding them")
+	fs.Parse(args)
+
+	nn, err := loadModel(*modelPath)
+	if err != nil {
+		return err
+	}
+
+	data, err := loadCSV(cf, nn.Metadata, false)
+	if err != nil {
+		return err
+	}
+	inputs := make([][]float32, len(data))
+	for i, row := range data {
+		inputs[i] = row.Inputs
+	}
+
+	p, err := cogent.NewPredictor(nn, cogent.PredictorConfiguration{})
+	if err != nil {
+		return err
+	}
+
+	outputs, err := p.Predict(inputs)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *outPath != "" {
+		f, err := os.Create(*outPath)
+		if err != nil {
+			return errors.Wrap(err, "can't create output")
+		}
+		defer f.Close()
+		w = f
+	}
+
+	cw := csv.NewWriter(w)
+	if !*raw && nn.Metadata.OutputEncoder != nil {
+		decoded, err := nn.Metadata.OutputEncoder.InverseTransform(outputs)
+		if err != nil {
+			return errors.Wrap(err, "can't decode predictions")
+		}
+
+		cw.Write(columnNames(nn.Metadata.Columns, cogent.OutputRole))
+		cw.WriteAll(decoded)
+		return errors.Wrap(cw.Error(), "can't write predictions")
+	}
+
+	outputHeader := make([]string, p.OutputCount())
+	for i := range outputHeader {
+		outputHeader[i] = fmt.Sprintf("output_%d", i)
+	}
+	cw.Write(outputHeader)
+	for _, row := range outputs {
+		record := make([]string, len(row))
+		for i, x := range row {
+			record[i] = strconv.FormatFloat(float64(x), 'g', -1, 32)
+		}
+		cw.Write(record)
+	}
+	cw.Flush()
+	return errors.Wrap(cw.Error(), "can't write predictions")
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/delaneyj/cogent"
+	"github.com/delaneyj/cogent/serve"
+	"github.com/pkg/errors"
+)
+
+//modelFlags collects repeated -model name=path flags, the name defaults to the file's base name
+type modelFlags map[string]string
+
+func (mf modelFlags) String() string {
+	return ""
+}
+
+func (mf modelFlags) Set(value string) error {
+	name, path := "", value
+	if i := strings.Index(value, "="); i >= 0 {
+		name, path = value[:i], value[i+1:]
+	}
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if _, ok := mf[name]; ok {
+		return errors.Errorf("model '%s' given twice", name)
+	}
+	mf[name] = path
+	return nil
+}
+
+func serveModels(args []string) error {
+	logger := cogent.NewLogger(os.Stderr, cogent.InfoLevel)
+	server, s, err := modelServer(args, logger)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Watch(ctx)
+
+	logger.Info("serving", "addr", server.Addr)
+	return server.ListenAndServe()
+}
+
+//modelServer parses the flags and loads the models into a server that isn't listening or watching yet
+func modelServer(args []string, logger cogent.Logger) (*http.Server, *serve.Server, error) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	models := modelFlags{}
+	fs.Var(models, "model", "model file written by train as name=path or path, repeat to serve several")
+	addr := fs.String("addr", ":8080", "address to listen on")
+	reload := fs.Duration("reload", serve.DefaultReloadInterval, "how often to check the model files for changes")
+	batchSize := fs.Int("batch", 0, "largest micro-batch to predict at once, 0 for no limit")
+	withMetrics := fs.Bool("metrics", true, "serve Prometheus metrics on /metrics")
+	fs.Parse(args)
+
+	if len(models) == 0 {
+		return nil, nil, errors.New("-model is required")
+	}
+
+	config := serve.Configuration{
+		Models:         models,
+		ReloadInterval: *reload,
+		MaxBatchSize:   *batchSize,
+		Logger:         logger,
+	}
+	if *withMetrics {
+		config.Metrics = cogent.NewMetrics("")
+	}
+	s, err := serve.New(config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return server, s, nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"os"
+
+	"github.com/delaneyj/cogent"
+	"github.com/pkg/errors"
+)
+
+func train(args []string) error {
+	fs := flag.NewFlagSet("train", flag.ExitOnError)
+	cf := addCSVFlags(fs, "labelled csv with a header row")
+	configPath := fs.String("config", "", "YAML or JSON encoding, network and swarm config")
+	outPath := fs.String("out", "model.nn", "model file to write")
+	metricsAddr := fs.String("metrics", "", "address to serve Prometheus metrics on at /metrics while training, e.g. :9090")
+	dashboardAddr := fs.String("dashboard", "", "address to serve a live training dashboard on, e.g. :9090")
+	blackboardURL := fs.String("blackboard", "", "URL of a cogent blackboard to share bests with swarms training in other processes")
+	blackboardToken := fs.String("blackboard-token", os.Getenv(blackboardTokenEnv), "token the blackboard was started with, defaults to $"+blackboardTokenEnv)
+	firstSwarm := fs.Int("first-swarm", 0, "id of this process's first swarm, processes sharing a blackboard need distinct ranges")
+	verbose := fs.Bool("v", false, "log every particle's bests and deaths and the encoders' widths")
+	fs.Parse(args)
+
+	c, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	f, opts, err := cf.open()
+	if err != nil {
+		return err
+	}
+	level := cogent.InfoLevel
+	if *verbose {
+		level = cogent.DebugLevel
+	}
+	logger := cogent.NewLogger(os.Stderr, level)
+	opts.Logger = logger
+
+	//target encoded columns are fitted out-of-fold on the same folds the swarm trains with
+	opts.TargetFolds = c.Swarm.Folds
+	loaded, err := cogent.LoadCSV(f, c.Columns, opts)
+	f.Close()
+	if err != nil {
+		return errors.Wrapf(err, "can't load '%s'", *cf.path)
+	}
+	data := loaded.Data
+
+	nnc := cogent.NeuralNetworkConfiguration{
+		Loss:         c.Network.Loss,
+		InputCount:   len(data[0].Inputs),
+		LayerConfigs: make([]cogent.LayerConfig, len(c.Network.Layers)),
+	}
+	lastLayerIndex := len(c.Network.Layers) - 1
+	for i, l := range c.Network.Layers {
+		if l.Nodes <= 0 && i == lastLayerIndex {
+			l.Nodes = len(data[0].Outputs)
+		}
+		if l.Nodes <= 0 {
+			return errors.Errorf("layer %d needs nodes", i)
+		}
+		nnc.LayerConfigs[i] = cogent.LayerConfig{
+			NodeCount:  l.Nodes,
+			Activation: l.Activation,
+		}
+	}
+
+	msc := cogent.MultiSwarmConfiguration{
+		NeuralNetworkConfiguration: nnc,
+		SwarmCount:                 c.Swarm.Swarms,
+		ParticleCount:              c.Swarm.Particles,
+		FirstSwarmID:               *firstSwarm,
+		Islands:                    c.Swarm.Islands,
+	}
+	bucket, err := loaded.Bucket()
+	if err != nil {
+		return err
+	}
+	diagnostics := msc.Validate(bucket)
+	for _, d := range diagnostics {
+		if d.Severity == cogent.DiagnosticError {
+			logger.Error(d.Message, "field", d.Field)
+		} else {
+			logger.Warn(d.Message, "field", d.Field)
+		}
+	}
+	if err := cogent.DiagnosticsError(diagnostics); err != nil {
+		return err
+	}
+
+	buckets, err := loaded.Buckets(c.Swarm.Folds)
+	if err != nil {
+		return err
+	}
+
+	bb := cogent.NewMemoryBlackboard()
+	if *blackboardURL != "" {
+		bb = cogent.NewHTTPBlackboard(*blackboardURL, *blackboardToken, nil)
+	}
+	ms, err := cogent.NewMultiSwarmWithBlackboard(msc, c.Training.trainingConfiguration(), bb)
+	if err != nil {
+		return err
+	}
+	ms.SetLogger(logger)
+	muxes := map[string]*http.ServeMux{}
+	mux := func(addr string) *http.ServeMux {
+		if muxes[addr] == nil {
+			muxes[addr] = http.NewServeMux()
+		}
+		return muxes[addr]
+	}
+	if *metricsAddr != "" {
+		metrics := cogent.NewMetrics("")
+		ms.OnIteration(metrics.ObserveIteration)
+		mux(*metricsAddr).Handle("/metrics", metrics)
+	}
+	if *dashboardAddr != "" {
+		dashboard := cogent.NewDashboard()
+		dashboard.Attach(ms)
+		mux(*dashboardAddr).Handle("/", dashboard)
+	}
+	for addr, m := range muxes {
+		go func(addr string, m *http.ServeMux) {
+			if err := http.ListenAndServe(addr, m); err != nil {
+				logger.Error("http server stopped", "addr", addr, "err", err)
+			}
+		}(addr, m)
+	}
+	if err := ms.Train(buckets, c.Swarm.Multithread); err != nil {
+		return err
+	}
+
+	nn, err := ms.Best()
+	if err != nil {
+		return err
+	}
+	nn.Metadata.Columns = c.Columns
+	nn.Metadata.InputEncoder = loaded.InputEncoder
+	nn.Metadata.OutputEncoder = loaded.OutputEncoder
+
+	out, err := os.Create(*outPath)
+	if err != nil {
+		return errors.Wrap(err, "can't create model file")
+	}
+	if err := nn.Save(out); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return errors.Wrap(err, "can't close model file")
+	}
+
+	m := nn.Metadata.Metrics
+	logger.Info("wrote model", "path", *outPath, "loss", m.Loss, "rmse", m.RMSE, "accuracy", m.Accuracy)
+	return nil
+}
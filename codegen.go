@@ -0,0 +1,164 @@
+package cogent
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+//goActivations are the inlined Go statements for each element-wise activation, operating on x
+var goActivations = map[ActivationMode]string{
+	Identity: ``,
+	//Maxout only rewrites values equal to the max with the max, so it leaves the input untouched
+	Maxout: ``,
+	BinaryStep: `if x > 0 {
+		x = 1
+	}`,
+	Sigmoid: `x = float32(1 / (1 + math.Exp(-float64(x))))`,
+	HyperbolicTangent: `switch {
+	case x < -20:
+		x = -1
+	case x > 20:
+		x = 1
+	default:
+		x = float32(math.Tanh(float64(x)))
+	}`,
+	ArcTan:   `x = float32(math.Atan(float64(x)))`,
+	Softsign: `x = x / (1 + float32(math.Abs(float64(x))))`,
+	ISRU:     `x = x / float32(math.Sqrt(float64(1+x*x)))`,
+	ReLU: `if x < 0 {
+		x = 0
+	}`,
+	LeakyReLU: `if x < 0 {
+		x *= 0.01
+	}`,
+	ELU: `if x < 0 {
+		x = float32(math.Exp(float64(x))) - 1
+	}`,
+	SELU: `if x < 0 {
+		x = 1.0507 * 1.67326 * (float32(math.Exp(float64(x))) - 1)
+	} else {
+		x *= 1.0507
+	}
+	x = clamp(x)`,
+	SoftPlus:     `x = clamp(float32(math.Log(1 + math.Exp(float64(x)))))`,
+	BentIdentity: `x = clamp((float32(math.Sqrt(float64(x*x+1)))-1)/2 + x)`,
+	Sinusoid:     `x = float32(math.Sin(float64(x)))`,
+	Sinc: `if x != 0 {
+		x = clamp(float32(math.Sin(float64(x))) / x)
+	} else {
+		x = 1
+	}`,
+	Gaussian: `x = float32(math.Exp(-float64(x * x)))`,
+}
+
+const goHelpers = `
+func clamp(x float32) float32 {
+	if math.IsInf(float64(x), 1) {
+		return math.MaxFloat32
+	}
+	return x
+}
+
+func softmax(row []float32) {
+	max := float32(-math.MaxFloat32)
+	for _, x := range row {
+		if x > max {
+			max = x
+		}
+	}
+
+	var sum float32
+	for i, x := range row {
+		row[i] = float32(math.Exp(float64(x - max)))
+		sum += row[i]
+	}
+	for i := range row {
+		row[i] /= sum
+	}
+}
+`
+
+//GenerateGo writes a dependency free Go file for the given package with a
+//func Predict(in []float32) []float32 that computes the same outputs as Activate
+func (nn *NeuralNetwork) GenerateGo(w io.Writer, packageName string) error {
+	if len(nn.Layers) == 0 {
+		return errors.New("can't generate code for network without layers")
+	}
+
+	inputCount := nn.Layers[0].WeightsAndBiases.Shape()[0] - 1
+	lastLayerIndex := len(nn.Layers) - 1
+	outputCount := nn.Layers[lastLayerIndex].WeightsAndBiases.Shape()[1]
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "// Code generated by cogent. DO NOT EDIT.\n\n")
+	fmt.Fprintf(buf, "package %s\n\n", packageName)
+	fmt.Fprintf(buf, "import \"math\"\n\n")
+	fmt.Fprintf(buf, "// InputCount is the number of values Predict expects.\nconst InputCount = %d\n\n", inputCount)
+	fmt.Fprintf(buf, "// OutputCount is the number of values Predict returns.\nconst OutputCount = %d\n\n", outputCount)
+
+	for i, l := range nn.Layers {
+		fmt.Fprintf(buf, "var layer%dWeights = [...]float32{", i)
+		for j, x := range l.WeightsAndBiases.Data().([]float32) {
+			if j%8 == 0 {
+				buf.WriteString("\n")
+			}
+			buf.WriteString(strconv.FormatFloat(float64(x), 'g', -1, 32))
+			buf.WriteString(", ")
+		}
+		buf.WriteString("\n}\n\n")
+	}
+
+	fmt.Fprintf(buf, "// Predict feeds in forward through the network.\n")
+	fmt.Fprintf(buf, "func Predict(in []float32) []float32 {\n")
+	fmt.Fprintf(buf, "if len(in) != InputCount {\npanic(\"Predict expects %d inputs\")\n}\n", inputCount)
+	fmt.Fprintf(buf, "inputs := append(append(make([]float32, 0, InputCount+1), in...), 1)\n")
+
+	for i, l := range nn.Layers {
+		s := l.WeightsAndBiases.Shape()
+		rows, cols := s[0], s[1]
+
+		fmt.Fprintf(buf, "\n// layer %d, %d nodes, %s\n", i, l.NodeCount, l.Activation)
+		fmt.Fprintf(buf, "layer%d := make([]float32, %d)\n", i, cols)
+		fmt.Fprintf(buf, "for c := range layer%d {\n", i)
+		fmt.Fprintf(buf, "var sum float32\n")
+		fmt.Fprintf(buf, "for r := 0; r < %d; r++ {\n", rows)
+		fmt.Fprintf(buf, "sum += inputs[r] * layer%dWeights[r*%d+c]\n", i, cols)
+		fmt.Fprintf(buf, "}\n")
+
+		if inlined, ok := goActivations[l.Activation]; ok {
+			fmt.Fprintf(buf, "x := sum\n%s\nlayer%d[c] = x\n}\n", inlined, i)
+		} else {
+			fmt.Fprintf(buf, "layer%d[c] = sum\n}\n", i)
+			switch l.Activation {
+			case Softmax:
+				fmt.Fprintf(buf, "softmax(layer%d)\n", i)
+			case SplitSoftmax:
+				fmt.Fprintf(buf, "softmax(layer%d[:%d])\n", i, cols/2)
+				fmt.Fprintf(buf, "softmax(layer%d[%d:])\n", i, cols/2)
+				fmt.Fprintf(buf, "softmax(layer%d)\n", i)
+			default:
+				return errors.Errorf("can't generate code for activation '%s'", l.Activation)
+			}
+		}
+
+		if i != lastLayerIndex {
+			fmt.Fprintf(buf, "layer%d[%d] = 1\n", i, cols-1)
+			fmt.Fprintf(buf, "inputs = layer%d\n", i)
+		}
+	}
+	fmt.Fprintf(buf, "return layer%d\n}\n", lastLayerIndex)
+	buf.WriteString(goHelpers)
+
+	formatted, err := format.Source(buf.Bytes())
+	if err != nil {
+		return errors.Wrap(err, "can't format generated code")
+	}
+
+	_, err = w.Write(formatted)
+	return errors.Wrap(err, "can't write generated code")
+}
@@ -0,0 +1,109 @@
+package cogent
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const codegenMain = `package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"gen/model"
+)
+
+func main() {
+	var inputs [][]float32
+	if err := json.NewDecoder(os.Stdin).Decode(&inputs); err != nil {
+		panic(err)
+	}
+
+	outputs := make([][]float32, len(inputs))
+	for i, in := range inputs {
+		outputs[i] = model.Predict(in)
+	}
+	json.NewEncoder(os.Stdout).Encode(outputs)
+}
+`
+
+func Test_GenerateGo(t *testing.T) {
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go toolchain not available")
+	}
+
+	dir, err := ioutil.TempDir("", "cogent_codegen")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	data := Data{
+		{Inputs: []float32{0, 0, 1}, Outputs: []float32{1, 0, 0, 1}},
+		{Inputs: []float32{0.5, -1, 0}, Outputs: []float32{0, 1, 1, 0}},
+		{Inputs: []float32{-2, 0.25, 3}, Outputs: []float32{1, 0, 1, 0}},
+	}
+	inputs := make([][]float32, len(data))
+	for i, row := range data {
+		inputs[i] = row.Inputs
+	}
+	inputsJSON, err := json.Marshal(inputs)
+	assert.Nil(t, err)
+	bucket, err := DataToTensorDataBucket(data, true)
+	assert.Nil(t, err)
+
+	// one network per activation keeps the number of go runs small
+	lc := []LayerConfig{}
+	for mode := range activations {
+		lc = append(lc, LayerConfig{NodeCount: 4, Activation: mode})
+	}
+	lc = append(lc, LayerConfig{NodeCount: 4, Activation: SplitSoftmax})
+	nn := testNeuralNetwork(3, lc...)
+	// keep weights small so deep stacks don't saturate everything
+	for _, l := range nn.Layers {
+		for i, w := range l.WeightsAndBiases.Data().([]float32) {
+			l.WeightsAndBiases.Data().([]float32)[i] = w / 2
+		}
+	}
+	expected, _, err := nn.Activate(bucket.Inputs)
+	assert.Nil(t, err)
+
+	code := bytes.Buffer{}
+	assert.Nil(t, nn.GenerateGo(&code, "model"))
+	assert.Contains(t, code.String(), "func Predict(in []float32) []float32")
+	assert.NotContains(t, code.String(), "gorgonia")
+
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, "model"), 0755))
+	files := map[string]string{
+		"go.mod":         "module gen\n",
+		"main.go":        codegenMain,
+		"model/model.go": code.String(),
+	}
+	for name, contents := range files {
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644))
+	}
+
+	cmd := exec.Command(goBin, "run", ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=", "GOPROXY=off")
+	cmd.Stdin = bytes.NewReader(inputsJSON)
+	out, err := cmd.CombinedOutput()
+	if !assert.Nil(t, err, string(out)) {
+		return
+	}
+
+	var actual [][]float32
+	assert.Nil(t, json.Unmarshal(out, &actual))
+	flattened := []float32{}
+	for _, row := range actual {
+		flattened = append(flattened, row...)
+	}
+	assert.InDeltaSlice(t, expected.Data(), flattened, 1e-4)
+}
@@ -0,0 +1,339 @@
+package cogent
+
+import (
+	"encoding/csv"
+	"io"
+	"math/rand"
+
+	"github.com/pkg/errors"
+)
+
+//CSVOptions controls how delimited text is read
+type CSVOptions struct {
+	//Delimiter defaults to ',', use '\t' for TSV
+	Delimiter rune
+	//Comment skips lines starting with it when set
+	Comment          rune
+	LazyQuotes       bool
+	TrimLeadingSpace bool
+	//TargetFolds is how many folds LoadCSV target encodes out-of-fold with, defaults to DefaultTargetFolds
+	TargetFolds int
+	//TargetSeed seeds how LoadCSV assigns rows to target folds, the same seed gives the same folds
+	TargetSeed int64
+	//Logger is given to the encoders LoadCSV fits, defaults to DefaultLogger
+	Logger Logger
+}
+
+func (o CSVOptions) reader(r io.Reader) *csv.Reader {
+	cr := csv.NewReader(r)
+	if o.Delimiter != 0 {
+		cr.Comma = o.Delimiter
+	}
+	cr.Comment = o.Comment
+	cr.LazyQuotes = o.LazyQuotes
+	cr.TrimLeadingSpace = o.TrimLeadingSpace
+	cr.ReuseRecord = true
+	return cr
+}
+
+//ReadCSVTable reads the header and every row as strings, e.g. to InferEncodings
+func ReadCSVTable(r io.Reader, opts CSVOptions) ([]string, [][]string, error) {
+	cr := opts.reader(r)
+	cr.ReuseRecord = false
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "can't read csv")
+	}
+	if len(records) < 2 {
+		return nil, nil, errors.New("csv needs a header and at least one row")
+	}
+	return records[0], records[1:], nil
+}
+
+//CSVData is a labelled table read by LoadCSV along with the encoders fitted to it
+type CSVData struct {
+	Data          Data
+	InputEncoder  *TableEncoder
+	OutputEncoder *TableEncoder
+	InputSchema   EncodedSchema
+	OutputSchema  EncodedSchema
+
+	//Folds is each row's fold when inputs were target encoded out-of-fold, nil otherwise
+	Folds []int
+}
+
+//Bucket converts the rows to tensors ready for training
+func (d *CSVData) Bucket() (*DataBucket, error) {
+	return DataToTensorDataBucket(d.Data, true)
+}
+
+//Buckets splits the rows into k buckets for k-fold training. When inputs were target
+//encoded the target encoding's folds are used instead, so no bucket's inputs saw its targets.
+func (d *CSVData) Buckets(k int) (DataBuckets, error) {
+	if d.Folds == nil {
+		bucket, err := d.Bucket()
+		if err != nil {
+			return nil, err
+		}
+		return DataBucketToBuckets(k, bucket)
+	}
+	return DataToFoldBuckets(d.Data, d.Folds)
+}
+
+//csvColumns is where the spec's columns of one role are in each record
+type csvColumns struct {
+	specs   []ColumnSpec
+	indices []int
+}
+
+func (cc csvColumns) names() []string {
+	names := make([]string, len(cc.specs))
+	for i, c := range cc.specs {
+		names[i] = c.Name
+	}
+	return names
+}
+
+//readCSV streams every record after the header to fn, picking out the spec's columns for
+//each of the roles. Rows are counted from 1, the header isn't a row.
+func readCSV(r io.Reader, columns []ColumnSpec, opts CSVOptions, roles []ColumnRole, fn func(row int, fields [][]string) error) error {
+	cr := opts.reader(r)
+	header, err := cr.Read()
+	if err == io.EOF {
+		return errors.New("csv is empty, expected a header")
+	}
+	if err != nil {
+		return errors.Wrap(err, "can't read csv header")
+	}
+
+	indices := map[string]int{}
+	for i, name := range header {
+		if _, ok := indices[name]; ok {
+			return errors.Errorf("column '%s' is in the csv header more than once", name)
+		}
+		indices[name] = i
+	}
+
+	picked := make([]csvColumns, len(roles))
+	seen := map[string]bool{}
+	for _, c := range columns {
+		if seen[c.Name] {
+			return errors.Errorf("column '%s' is in the spec more than once", c.Name)
+		}
+		seen[c.Name] = true
+
+		for i, role := range roles {
+			if c.Role != role {
+				continue
+			}
+			index, ok := indices[c.Name]
+			if !ok {
+				return errors.Errorf("%s column '%s' isn't in the csv header", role, c.Name)
+			}
+			picked[i].specs = append(picked[i].specs, c)
+			picked[i].indices = append(picked[i].indices, index)
+		}
+	}
+	for i, role := range roles {
+		if len(picked[i].specs) == 0 {
+			return errors.Errorf("no %s columns in the spec", role)
+		}
+	}
+
+	for row := 1; ; row++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			if row == 1 {
+				return errors.New("csv has a header but no rows")
+			}
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "can't read row %d", row)
+		}
+
+		fields := make([][]string, len(roles))
+		for i, p := range picked {
+			fields[i] = make([]string, len(p.indices))
+			for j, index := range p.indices {
+				fields[i][j] = record[index]
+			}
+		}
+		if err := fn(row, fields); err != nil {
+			return err
+		}
+	}
+}
+
+//csvSpecColumns picks the columns of a role out of the spec, in spec order
+func csvSpecColumns(columns []ColumnSpec, role ColumnRole) csvColumns {
+	cc := csvColumns{}
+	for _, c := range columns {
+		if c.Role == role {
+			cc.specs = append(cc.specs, c)
+		}
+	}
+	return cc
+}
+
+//LoadCSV reads a labelled table with a header row, fitting an encoder to the input and
+//output columns of the spec. Only the spec's columns are kept in memory while fitting.
+//Inputs with TargetEncodingMode are fitted to the encoded outputs out-of-fold, see CSVData.Folds.
+func LoadCSV(r io.Reader, columns []ColumnSpec, opts CSVOptions) (*CSVData, error) {
+	roles := []ColumnRole{InputRole, OutputRole}
+	encoders := make([]*TableEncoder, len(roles))
+	names := make([][]string, len(roles))
+	for i, role := range roles {
+		cc := csvSpecColumns(columns, role)
+		if len(cc.specs) == 0 {
+			return nil, errors.Errorf("no %s columns in the spec", role)
+		}
+
+		te, err := NewTableEncoderFromSpecs(cc.specs)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s columns", role)
+		}
+		te.SetLogger(opts.Logger)
+		encoders[i], names[i] = te, cc.names()
+	}
+	//target encoded inputs can only be learnt once the outputs are encoded
+	targeted := encoders[0].needsTargets()
+
+	var tables [2][][]string
+	err := readCSV(r, columns, opts, roles, func(row int, fields [][]string) error {
+		for i, te := range encoders {
+			tables[i] = append(tables[i], fields[i])
+			if i == 0 && targeted {
+				continue
+			}
+			for c, value := range fields[i] {
+				if err := te.columns[c].Learn(value); err != nil {
+					return errors.Wrapf(err, "row %d column '%s'", row, names[i][c])
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	d := &CSVData{
+		Data:          make(Data, len(tables[0])),
+		InputEncoder:  encoders[0],
+		OutputEncoder: encoders[1],
+	}
+	if err := encoders[1].finishFit(); err != nil {
+		return nil, errors.Wrapf(err, "%s columns", roles[1])
+	}
+	for r := range d.Data {
+		outputs, err := encodeCSVRow(encoders[1], names[1], r+1, tables[1][r])
+		if err != nil {
+			return nil, err
+		}
+		d.Data[r].Outputs = outputs
+	}
+
+	if targeted {
+		folds := opts.TargetFolds
+		if folds <= 0 {
+			folds = DefaultTargetFolds
+		}
+		targets := make([][]float32, len(d.Data))
+		for r, dr := range d.Data {
+			targets[r] = dr.Outputs
+		}
+		inputs, err := encoders[0].FitTransformWithTargets(tables[0], targets, folds, rand.New(rand.NewSource(opts.TargetSeed)))
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s columns", roles[0])
+		}
+		for r := range d.Data {
+			d.Data[r].Inputs = inputs[r]
+		}
+		d.Folds = encoders[0].Folds()
+	} else {
+		if err := encoders[0].finishFit(); err != nil {
+			return nil, errors.Wrapf(err, "%s columns", roles[0])
+		}
+		for r := range d.Data {
+			inputs, err := encodeCSVRow(encoders[0], names[0], r+1, tables[0][r])
+			if err != nil {
+				return nil, err
+			}
+			d.Data[r].Inputs = inputs
+		}
+	}
+
+	schemas := []*EncodedSchema{&d.InputSchema, &d.OutputSchema}
+	for i, te := range encoders {
+		var err error
+		if *schemas[i], err = te.Schema(names[i]); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+//LoadCSVWithEncoders streams a table with a header row through already fitted encoders,
+//e.g. ones saved with a model. When outputEncoder is nil the output columns aren't read
+//and every row's Outputs is empty.
+func LoadCSVWithEncoders(r io.Reader, columns []ColumnSpec, inputEncoder, outputEncoder *TableEncoder, opts CSVOptions) (Data, error) {
+	if inputEncoder == nil {
+		return nil, errors.New("an input encoder is required")
+	}
+
+	roles := []ColumnRole{InputRole}
+	encoders := []*TableEncoder{inputEncoder}
+	if outputEncoder != nil {
+		roles = append(roles, OutputRole)
+		encoders = append(encoders, outputEncoder)
+	}
+
+	names := make([][]string, len(roles))
+	for i, role := range roles {
+		cc := csvSpecColumns(columns, role)
+		if len(cc.specs) != len(encoders[i].columns) {
+			msg := "spec has %d %s columns, the encoder expects %d"
+			return nil, errors.Errorf(msg, len(cc.specs), role, len(encoders[i].columns))
+		}
+		names[i] = cc.names()
+	}
+
+	data := Data{}
+	err := readCSV(r, columns, opts, roles, func(row int, fields [][]string) error {
+		dr := DataRow{}
+		for i, te := range encoders {
+			encoded, err := encodeCSVRow(te, names[i], row, fields[i])
+			if err != nil {
+				return err
+			}
+			if roles[i] == InputRole {
+				dr.Inputs = encoded
+			} else {
+				dr.Outputs = encoded
+			}
+		}
+		data = append(data, dr)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func encodeCSVRow(te *TableEncoder, names []string, row int, fields []string) ([]float32, error) {
+	if !te.fitted {
+		return nil, errors.New("table encoder isn't fitted")
+	}
+
+	encodedRow := []float32{}
+	for c, value := range fields {
+		encoded, err := te.encodeColumn(c, value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "row %d column '%s'", row, names[c])
+		}
+		encodedRow = append(encodedRow, encoded...)
+	}
+	return encodedRow, nil
+}
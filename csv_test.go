@@ -0,0 +1,114 @@
+package cogent
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_LoadCSV(t *testing.T) {
+	columns := []ColumnSpec{
+		{Name: "age", Encoding: NormalizedEncodingMode, Role: InputRole},
+		{Name: "tags", Encoding: StringArrayEncodingMode, Role: InputRole},
+		{Name: "id", Role: IgnoredRole},
+		{Name: "label", Encoding: OneHotEncodingMode, Role: OutputRole},
+	}
+	csv := "id,age,tags,label\n" +
+		"1,20,\"a,b\",yes\n" +
+		"2,40,b,no\n" +
+		"3,30,\"c\",yes\n"
+
+	loaded, err := LoadCSV(strings.NewReader(csv), columns, CSVOptions{})
+	assert.Nil(t, err)
+	assert.Len(t, loaded.Data, 3)
+	assert.Equal(t, []string{"age_z", "tags=a", "tags=b", "tags=c"}, loaded.InputSchema.FeatureNames())
+	assert.Equal(t, []string{"label=yes", "label=no"}, loaded.OutputSchema.FeatureNames())
+
+	first := loaded.Data[0]
+	assert.InDelta(t, -1.2247, first.Inputs[0], 1e-4)
+	assert.Equal(t, []float32{1, 1, 0}, first.Inputs[1:])
+	assert.Equal(t, []float32{1, 0}, first.Outputs)
+	bucket, err := loaded.Bucket()
+	assert.Nil(t, err)
+	assert.Equal(t, 3, bucket.RowCount())
+
+	// the same table as TSV through the fitted encoders gives the same rows
+	tsv := strings.Replace(strings.Replace(csv, ",", "\t", -1), "\"a\tb\"", "a,b", 1)
+	reloaded, err := LoadCSVWithEncoders(strings.NewReader(tsv), columns, loaded.InputEncoder, loaded.OutputEncoder, CSVOptions{Delimiter: '\t'})
+	assert.Nil(t, err)
+	assert.Equal(t, loaded.Data, reloaded)
+
+	// outputs aren't needed to predict
+	unlabelled := "age,tags\n25,a\n"
+	inputsOnly, err := LoadCSVWithEncoders(strings.NewReader(unlabelled), columns, loaded.InputEncoder, nil, CSVOptions{})
+	assert.Nil(t, err)
+	assert.Len(t, inputsOnly, 1)
+	assert.Len(t, inputsOnly[0].Inputs, 4)
+	assert.Nil(t, inputsOnly[0].Outputs)
+}
+
+func Test_LoadCSVTargetEncoding(t *testing.T) {
+	columns := []ColumnSpec{
+		{Name: "zip", Encoding: TargetEncodingMode, Role: InputRole},
+		{Name: "age", Encoding: NormalizedEncodingMode, Role: InputRole},
+		{Name: "bought", Encoding: BooleanEncodingMode, Role: OutputRole},
+	}
+	csv := "zip,age,bought\n"
+	for i := 0; i < 12; i++ {
+		csv += []string{"90210,30,true\n", "10001,40,false\n", "60601,50,true\n"}[i%3]
+	}
+
+	loaded, err := LoadCSV(strings.NewReader(csv), columns, CSVOptions{TargetFolds: 3})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"zip_target", "age_z"}, loaded.InputSchema.FeatureNames())
+	assert.Len(t, loaded.Folds, 12)
+	reloaded, err := LoadCSV(strings.NewReader(csv), columns, CSVOptions{TargetFolds: 3})
+	assert.Nil(t, err)
+	assert.Equal(t, loaded.Folds, reloaded.Folds)
+	assert.Equal(t, loaded.Data, reloaded.Data)
+
+	// one bucket per fold, so no bucket's inputs were encoded with its own targets
+	buckets, err := loaded.Buckets(5)
+	assert.Nil(t, err)
+	assert.Len(t, buckets, 3)
+	for _, b := range buckets {
+		assert.Equal(t, 4, b.RowCount())
+		// two encoded inputs and the bias
+		assert.Equal(t, 3, b.Inputs.Shape()[1])
+	}
+
+	row, err := loaded.InputEncoder.TransformRow([]string{"90210", "40"})
+	assert.Nil(t, err)
+	assert.InDelta(t, (4+10*8.0/12)/(4+10), row[0], 1e-5)
+	assert.InDelta(t, 0, row[1], 1e-5)
+}
+
+func Test_LoadCSVErrors(t *testing.T) {
+	columns := []ColumnSpec{
+		{Name: "age", Encoding: NormalizedEncodingMode, Role: InputRole},
+		{Name: "label", Encoding: OneHotEncodingMode, Role: OutputRole},
+	}
+
+	tests := []struct {
+		name     string
+		csv      string
+		columns  []ColumnSpec
+		contains string
+	}{
+		{"empty", "", columns, "empty"},
+		{"no rows", "age,label\n", columns, "no rows"},
+		{"missing column", "age,other\n1,a\n", columns, "output column 'label' isn't in the csv header"},
+		{"duplicate header", "age,age,label\n1,2,a\n", columns, "more than once"},
+		{"bad value", "age,label\n1,a\nold,b\n", columns, "row 2 column 'age'"},
+		{"ragged", "age,label\n1,a\n2\n", columns, "row 2"},
+		{"no outputs", "age,label\n1,a\n", columns[:1], "no output columns"},
+	}
+
+	for _, tt := range tests {
+		_, err := LoadCSV(strings.NewReader(tt.csv), tt.columns, CSVOptions{})
+		if assert.NotNil(t, err, tt.name) {
+			assert.Contains(t, err.Error(), tt.contains, tt.name)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package cogent
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	math "github.com/chewxy/math32"
+)
+
+//DefaultDashboardHistory is how many iterations a Dashboard replays to a newly connected page
+const DefaultDashboardHistory = 1000
+
+//dashboardClientBuffer is how many events a slow page can fall behind before it misses some
+const dashboardClientBuffer = 64
+
+//IterationEvent is the "iteration" server-sent event, losses are null until a best is found
+type IterationEvent struct {
+	Iteration       int        `json:"iteration"`
+	DurationMS      float64    `json:"durationMs"`
+	GlobalBestLoss  *float32   `json:"globalBestLoss"`
+	SwarmIDs        []int      `json:"swarmIds"`
+	SwarmBestLosses []*float32 `json:"swarmBestLosses"`
+	SwarmDiversity  []float32  `json:"swarmDiversity"`
+	ParticleDeaths  int        `json:"particleDeaths"`
+	TotalDeaths     int        `json:"totalDeaths"`
+}
+
+//BestEvent is the "best" server-sent event, sent when an iteration improves the global best
+type BestEvent struct {
+	Iteration int      `json:"iteration"`
+	Loss      float32  `json:"loss"`
+	Previous  *float32 `json:"previous"`
+}
+
+type dashboardEvent struct {
+	name string
+	data []byte
+}
+
+//Dashboard streams training as server-sent events and serves a self-contained page charting
+//them. Attach it to a MultiSwarm, even while training, and mount it with a trailing slash,
+//e.g. mux.Handle("/dashboard/", http.StripPrefix("/dashboard", d)). The page is served at /
+//and the events at /events.
+type Dashboard struct {
+	mu       sync.Mutex
+	history  []dashboardEvent
+	clients  map[chan dashboardEvent]struct{}
+	bestLoss float32
+	deaths   int
+
+	//History is how many iterations are replayed to a new page, defaults to DefaultDashboardHistory
+	History int
+}
+
+//NewDashboard x
+func NewDashboard() *Dashboard {
+	return &Dashboard{
+		clients:  map[chan dashboardEvent]struct{}{},
+		bestLoss: math.MaxFloat32,
+		History:  DefaultDashboardHistory,
+	}
+}
+
+//Attach streams the swarm's iterations from the next one on
+func (d *Dashboard) Attach(ms *MultiSwarm) {
+	ms.OnIteration(d.ObserveIteration)
+}
+
+func knownLoss(loss float32) *float32 {
+	if loss == math.MaxFloat32 {
+		return nil
+	}
+	return &loss
+}
+
+//ObserveIteration sends the iteration, and a best event if the global best improved, to every page
+func (d *Dashboard) ObserveIteration(stats IterationStats) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.deaths += stats.ParticleDeaths
+	e := IterationEvent{
+		Iteration:       stats.Iteration,
+		DurationMS:      float64(stats.Duration.Nanoseconds()) / 1e6,
+		GlobalBestLoss:  knownLoss(stats.GlobalBestLoss),
+		SwarmIDs:        stats.SwarmIDs,
+		SwarmBestLosses: make([]*float32, len(stats.SwarmBestLosses)),
+		SwarmDiversity:  stats.SwarmDiversity,
+		ParticleDeaths:  stats.ParticleDeaths,
+		TotalDeaths:     d.deaths,
+	}
+	for i, loss := range stats.SwarmBestLosses {
+		e.SwarmBestLosses[i] = knownLoss(loss)
+	}
+	d.publish("iteration", e)
+
+	if stats.GlobalBestLoss < d.bestLoss {
+		d.publish("best", BestEvent{
+			Iteration: stats.Iteration,
+			Loss:      stats.GlobalBestLoss,
+			Previous:  knownLoss(d.bestLoss),
+		})
+		d.bestLoss = stats.GlobalBestLoss
+	}
+}
+
+//publish sends to every client without waiting, a client whose buffer is full misses the event
+func (d *Dashboard) publish(name string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	e := dashboardEvent{name, data}
+
+	d.history = append(d.history, e)
+	if max := 2 * d.History; max > 0 && len(d.history) > max {
+		d.history = append(d.history[:0], d.history[len(d.history)-max:]...)
+	}
+	for c := range d.clients {
+		select {
+		case c <- e:
+		default:
+		}
+	}
+}
+
+//ServeHTTP serves the events at /events and the page everywhere else
+func (d *Dashboard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasSuffix(r.URL.Path, "/events") {
+		d.serveEvents(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, dashboardPage)
+}
+
+func (d *Dashboard) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming isn't supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	c := make(chan dashboardEvent, dashboardClientBuffer)
+	d.mu.Lock()
+	history := append([]dashboardEvent(nil), d.history...)
+	d.clients[c] = struct{}{}
+	d.mu.Unlock()
+	defer func() {
+		d.mu.Lock()
+		delete(d.clients, c)
+		d.mu.Unlock()
+	}()
+
+	for _, e := range history {
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.name, e.data)
+	}
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e := <-c:
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.name, e.data)
+			flusher.Flush()
+		}
+	}
+}
+
+const dashboardPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>cogent training</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+canvas { border: 1px solid #ccc; width: 100%; height: 320px; }
+table { border-collapse: collapse; margin-top: 1em; }
+td, th { padding: 0.2em 1em; text-align: right; border-bottom: 1px solid #eee; }
+#best { color: #06c; }
+</style>
+</head>
+<body>
+<h1>cogent training</h1>
+<p>iteration <b id="iteration">-</b>, global best loss <b id="best">-</b>, deaths <b id="deaths">0</b>, <span id="status">connecting</span></p>
+<canvas id="chart" width="1200" height="320"></canvas>
+<table>
+<thead><tr><th>swarm</th><th>best loss</th><th>diversity</th></tr></thead>
+<tbody id="swarms"></tbody>
+</table>
+<script>
+var iterations = [];
+var colours = ["#06c", "#c60", "#090", "#c09", "#690", "#099", "#906", "#666"];
+var source = new EventSource("events");
+var $ = function(id) { return document.getElementById(id); };
+source.onopen = function() { $("status").textContent = "live"; };
+source.onerror = function() { $("status").textContent = "disconnected"; };
+source.addEventListener("iteration", function(m) {
+  var e = JSON.parse(m.data);
+  iterations.push(e);
+  if (iterations.length > 1000) iterations.shift();
+  $("iteration").textContent = e.iteration;
+  $("deaths").textContent = e.totalDeaths;
+  if (e.globalBestLoss !== null) $("best").textContent = e.globalBestLoss.toPrecision(6);
+  var rows = "";
+  e.swarmBestLosses.forEach(function(loss, i) {
+    rows += "<tr><td style='color:" + colours[i % colours.length] + "'>" + (e.swarmIds ? e.swarmIds[i] : i) + "</td><td>" +
+      (loss === null ? "-" : loss.toPrecision(6)) + "</td><td>" + e.swarmDiversity[i].toPrecision(4) + "</td></tr>";
+  });
+  $("swarms").innerHTML = rows;
+  draw();
+});
+
+function draw() {
+  var canvas = $("chart"), ctx = canvas.getContext("2d");
+  var w = canvas.width, h = canvas.height, pad = 40;
+  ctx.clearRect(0, 0, w, h);
+  var lo = Infinity, hi = -Infinity;
+  iterations.forEach(function(e) {
+    e.swarmBestLosses.concat([e.globalBestLoss]).forEach(function(l) {
+      if (l !== null && l > 0) { lo = Math.min(lo, l); hi = Math.max(hi, l); }
+    });
+  });
+  if (lo === Infinity) return;
+  if (hi === lo) hi = lo * 10;
+  var first = iterations[0].iteration, last = iterations[iterations.length - 1].iteration;
+  var x = function(i) { return pad + (w - 2 * pad) * (last === first ? 1 : (i - first) / (last - first)); };
+  var y = function(l) { return h - pad - (h - 2 * pad) * (Math.log(l) - Math.log(lo)) / (Math.log(hi) - Math.log(lo)); };
+  var line = function(colour, width, value) {
+    ctx.strokeStyle = colour; ctx.lineWidth = width; ctx.beginPath();
+    var started = false;
+    iterations.forEach(function(e) {
+      var l = value(e);
+      if (l === null || l <= 0) return;
+      if (started) ctx.lineTo(x(e.iteration), y(l)); else ctx.moveTo(x(e.iteration), y(l));
+      started = true;
+    });
+    ctx.stroke();
+  };
+  var swarms = iterations[iterations.length - 1].swarmBestLosses.length;
+  for (var s = 0; s < swarms; s++) {
+    line(colours[s % colours.length] + "8", 1, function(e) { return e.swarmBestLosses[s]; });
+  }
+  line("#000", 2, function(e) { return e.globalBestLoss; });
+  ctx.fillStyle = "#444"; ctx.font = "12px sans-serif";
+  ctx.fillText(hi.toPrecision(4), 2, pad); ctx.fillText(lo.toPrecision(4), 2, h - pad);
+  ctx.fillText("iteration " + first, pad, h - 10); ctx.fillText(String(last), w - pad - 20, h - 10);
+}
+</script>
+</body>
+</html>
+`
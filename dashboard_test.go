@@ -0,0 +1,135 @@
+package cogent
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	math "github.com/chewxy/math32"
+	"github.com/stretchr/testify/assert"
+)
+
+//readEvent reads the next server-sent event's name and data
+func readEvent(t *testing.T, r *bufio.Reader) (string, string) {
+	var name, data string
+	for {
+		line, err := r.ReadString('\n')
+		assert.Nil(t, err)
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "":
+			return name, data
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func Test_Dashboard(t *testing.T) {
+	d := NewDashboard()
+	server := httptest.NewServer(http.StripPrefix("/dashboard", d))
+	defer server.Close()
+
+	res, err := http.Get(server.URL + "/dashboard/")
+	assert.Nil(t, err)
+	page, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	assert.Nil(t, err)
+	assert.Contains(t, string(page), `new EventSource("events")`)
+
+	// the first iteration is replayed to pages connecting later
+	d.ObserveIteration(IterationStats{
+		Iteration:       0,
+		GlobalBestLoss:  math.MaxFloat32,
+		SwarmBestLosses: []float32{math.MaxFloat32, math.MaxFloat32},
+		SwarmDiversity:  []float32{3, 4},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequest("GET", server.URL+"/dashboard/events", nil)
+	assert.Nil(t, err)
+	res, err = http.DefaultClient.Do(req.WithContext(ctx))
+	assert.Nil(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+	r := bufio.NewReader(res.Body)
+
+	name, data := readEvent(t, r)
+	assert.Equal(t, "iteration", name)
+	first := IterationEvent{}
+	assert.Nil(t, json.Unmarshal([]byte(data), &first))
+	assert.Nil(t, first.GlobalBestLoss)
+	assert.Equal(t, []*float32{nil, nil}, first.SwarmBestLosses)
+
+	// wait for the page to be subscribed before the next iteration
+	for {
+		d.mu.Lock()
+		subscribed := len(d.clients)
+		d.mu.Unlock()
+		if subscribed == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	d.ObserveIteration(IterationStats{
+		Iteration:       1,
+		Duration:        1500 * time.Microsecond,
+		GlobalBestLoss:  0.5,
+		SwarmBestLosses: []float32{0.5, 0.75},
+		SwarmDiversity:  []float32{2, 3},
+		ParticleDeaths:  2,
+	})
+
+	name, data = readEvent(t, r)
+	assert.Equal(t, "iteration", name)
+	second := IterationEvent{}
+	assert.Nil(t, json.Unmarshal([]byte(data), &second))
+	assert.Equal(t, 1, second.Iteration)
+	assert.Equal(t, 1.5, second.DurationMS)
+	assert.Equal(t, float32(0.5), *second.GlobalBestLoss)
+	assert.Equal(t, float32(0.75), *second.SwarmBestLosses[1])
+	assert.Equal(t, 2, second.TotalDeaths)
+
+	name, data = readEvent(t, r)
+	assert.Equal(t, "best", name)
+	best := BestEvent{}
+	assert.Nil(t, json.Unmarshal([]byte(data), &best))
+	assert.Equal(t, BestEvent{Iteration: 1, Loss: 0.5}, best)
+
+	// attached to a swarm every iteration is streamed
+	rows := Data{
+		{Inputs: []float32{0, 0}, Outputs: []float32{0, 1}},
+		{Inputs: []float32{1, 1}, Outputs: []float32{1, 0}},
+	}
+	bucket, err := DataToTensorDataBucket(rows, true)
+	assert.Nil(t, err)
+	buckets, err := DataBucketToBuckets(2, bucket)
+	assert.Nil(t, err)
+	tc := DefaultTrainingConfig
+	tc.MaxIterations = 2
+	s, err := NewMultiSwarm(basicMathConfig(rows), tc)
+	assert.Nil(t, err)
+	s.SetLogger(NopLogger)
+	d.Attach(s)
+	assert.Nil(t, s.Train(buckets, false))
+	for i := 0; i < 2; i++ {
+		name, data = readEvent(t, r)
+		for name == "best" {
+			name, data = readEvent(t, r)
+		}
+		e := IterationEvent{}
+		assert.Nil(t, json.Unmarshal([]byte(data), &e))
+		assert.Equal(t, i, e.Iteration)
+		assert.Len(t, e.SwarmDiversity, 2)
+		assert.True(t, e.SwarmDiversity[0] > 0)
+	}
+}
@@ -1,6 +1,13 @@
 package cogent
 
-import t "gorgonia.org/tensor"
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	t "gorgonia.org/tensor"
+)
 
 //DataBucket x
 type DataBucket struct {
@@ -98,6 +105,17 @@ type TrainingConfiguration struct {
 	ProbablityOfDeath     float32
 	RidgeRegressionWeight float32
 	StoreGlobalBest       bool
+	//Parallelism is how many particles train at once when Train multithreads, 0 uses GOMAXPROCS
+	Parallelism int
+	//ParallelBuckets evaluates each of a particle's buckets on its own goroutine, on top of
+	//Parallelism. It helps when there are few particles or large buckets.
+	ParallelBuckets bool
+	//BatchSize, when set, scores every particle step on that many rows sampled from the buckets
+	//instead of every row, for datasets too large for a full pass per step
+	BatchSize int
+	//ReevaluationSize is how many sampled rows a step that beat its particle's best on its batch
+	//is scored on again before it becomes a best, 0 scores it on every row. Only used with BatchSize.
+	ReevaluationSize int
 }
 
 //MultiSwarmConfiguration x
@@ -105,6 +123,10 @@ type MultiSwarmConfiguration struct {
 	NeuralNetworkConfiguration NeuralNetworkConfiguration
 	SwarmCount                 int
 	ParticleCount              int
+	//FirstSwarmID numbers the swarms from here, processes sharing a Blackboard need distinct ranges
+	FirstSwarmID int
+	//Islands, when set, evolves each swarm independently with periodic migration between them
+	Islands *IslandConfiguration
 }
 
 //DataRow x
@@ -116,11 +138,24 @@ type DataRow struct {
 //Data x
 type Data []DataRow
 
-//DataToTensorDataBucket x
-func DataToTensorDataBucket(data Data, shouldAddBiasColum bool) *DataBucket {
+//DataToTensorDataBucket copies the rows into tensors, every row needs as many inputs and
+//outputs as the first or ErrShapeMismatch is returned
+func DataToTensorDataBucket(data Data, shouldAddBiasColum bool) (*DataBucket, error) {
 	rows := len(data)
+	if rows == 0 {
+		return nil, shapeMismatch("no rows")
+	}
 	iColCount := len(data[0].Inputs)
 	oColCount := len(data[0].Outputs)
+	if iColCount == 0 {
+		return nil, shapeMismatch("no inputs")
+	}
+	for r, x := range data {
+		if len(x.Inputs) != iColCount || len(x.Outputs) != oColCount {
+			msg := "row %d has %d inputs and %d outputs, expected %d and %d"
+			return nil, shapeMismatch(msg, r, len(x.Inputs), len(x.Outputs), iColCount, oColCount)
+		}
+	}
 	bucket := DataBucket{
 		Inputs: t.New(
 			t.Of(Float),
@@ -144,7 +179,133 @@ func DataToTensorDataBucket(data Data, shouldAddBiasColum bool) *DataBucket {
 	}
 
 	if shouldAddBiasColum {
-		return bucket.CloneAndAddBiasColumn()
+		return bucket.CloneAndAddBiasColumn(), nil
+	}
+	return &bucket, nil
+}
+
+//DataToFoldBuckets groups rows into one bucket per fold, e.g. the TableEncoder.Folds the rows
+//were target encoded with, adding the bias column and shuffling each bucket
+func DataToFoldBuckets(data Data, folds []int) (DataBuckets, error) {
+	if len(data) != len(folds) {
+		return nil, shapeMismatch("%d folds for %d rows", len(folds), len(data))
+	}
+
+	grouped := []Data{}
+	for r, fold := range folds {
+		if fold < 0 {
+			return nil, errors.Errorf("row %d has invalid fold %d", r, fold)
+		}
+		for len(grouped) <= fold {
+			grouped = append(grouped, Data{})
+		}
+		grouped[fold] = append(grouped[fold], data[r])
+	}
+
+	buckets := make(DataBuckets, len(grouped))
+	for i, rows := range grouped {
+		if len(rows) == 0 {
+			return nil, errors.Errorf("fold %d has no rows", i)
+		}
+		bucket, err := DataToTensorDataBucket(rows, true)
+		if err != nil {
+			return nil, errors.Wrapf(err, "fold %d", i)
+		}
+		ShuffleDatabucket(bucket)
+		buckets[i] = bucket
+	}
+	return buckets, nil
+}
+
+var activationModeNames = []string{
+	"Identity",
+	"BinaryStep",
+	"Sigmoid",
+	"HyperbolicTangent",
+	"ArcTan",
+	"Softsign",
+	"ISRU",
+	"ReLU",
+	"LeakyReLU",
+	"ELU",
+	"SELU",
+	"SoftPlus",
+	"BentIdentity",
+	"Sinusoid",
+	"Sinc",
+	"Gaussian",
+	"Softmax",
+	"Maxout",
+	"SplitSoftmax",
+}
+
+//String x
+func (a ActivationMode) String() string {
+	return modeName(activationModeNames, int(a))
+}
+
+//MarshalText x
+func (a ActivationMode) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+//UnmarshalText x
+func (a *ActivationMode) UnmarshalText(text []byte) error {
+	i, err := parseModeName(activationModeNames, "activation", string(text))
+	if err != nil {
+		return err
+	}
+	*a = ActivationMode(i)
+	return nil
+}
+
+var lossModeNames = []string{
+	"SquaredLoss",
+	"CrossLoss",
+	"HingeLoss",
+	"ExponentialLoss",
+	"HellingerDistanceLoss",
+	"KullbackLeiblerDivergenceLoss",
+	"GeneralizedKullbackLeiblerDivergenceLoss",
+	"ItakuraSaitoDistanceLoss",
+}
+
+//String x
+func (l LossMode) String() string {
+	return modeName(lossModeNames, int(l))
+}
+
+//MarshalText x
+func (l LossMode) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+//UnmarshalText x
+func (l *LossMode) UnmarshalText(text []byte) error {
+	i, err := parseModeName(lossModeNames, "loss", string(text))
+	if err != nil {
+		return err
+	}
+	*l = LossMode(i)
+	return nil
+}
+
+func modeName(names []string, i int) string {
+	if i < 0 || i >= len(names) {
+		return strconv.Itoa(i)
+	}
+	return names[i]
+}
+
+func parseModeName(names []string, kind, name string) (int, error) {
+	for i, n := range names {
+		if strings.EqualFold(n, name) {
+			return i, nil
+		}
+	}
+
+	if i, err := strconv.Atoi(name); err == nil && i >= 0 && i < len(names) {
+		return i, nil
 	}
-	return &bucket
+	return -1, invalidConfig("unknown %s mode '%s'", kind, name)
 }
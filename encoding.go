@@ -1,8 +1,8 @@
 package cogent
 
 import (
-	"fmt"
-	"log"
+	"encoding/json"
+	"math/rand"
 
 	math "github.com/chewxy/math32"
 
@@ -39,105 +39,731 @@ const (
 
 	//IntRangeEncodingMode x
 	IntRangeEncodingMode
+
+	//MinMaxEncodingMode scales the learnt range to 0-1
+	MinMaxEncodingMode
+
+	//SymmetricMinMaxEncodingMode scales the learnt range to -1 to 1
+	SymmetricMinMaxEncodingMode
+
+	//RobustEncodingMode centres on the median and scales by the interquartile range
+	RobustEncodingMode
+
+	//LogEncodingMode is log1p of the magnitude, keeping the sign
+	LogEncodingMode
+
+	//BoxCoxEncodingMode power transforms positive numbers towards normal and standardises them
+	BoxCoxEncodingMode
+
+	//YeoJohnsonEncodingMode power transforms any numbers towards normal and standardises them
+	YeoJohnsonEncodingMode
+
+	//QuantileUniformEncodingMode maps numbers through the learnt distribution to 0-1
+	QuantileUniformEncodingMode
+
+	//QuantileNormalEncodingMode maps numbers through the learnt distribution to a standard normal
+	QuantileNormalEncodingMode
+
+	//EqualWidthBinningEncodingMode one-hot encodes which of DefaultBinCount, or BinningOptions.Count,
+	//equally wide buckets a number is in
+	EqualWidthBinningEncodingMode
+
+	//EqualFrequencyBinningEncodingMode one-hot encodes which of DefaultBinCount, or BinningOptions.Count,
+	//equally full buckets a number is in
+	EqualFrequencyBinningEncodingMode
+
+	//DateTimeEncodingMode parses dates and emits cyclical hour, weekday and month features, see DateTimeOptions
+	DateTimeEncodingMode
+
+	//HashingEncodingMode hashes categories into a fixed number of features, see HashingOptions
+	HashingEncodingMode
+
+	//FrequencyEncodingMode replaces categories with how often they were learnt
+	FrequencyEncodingMode
+
+	//TargetEncodingMode replaces categories with the smoothed mean of the output columns, see
+	//TargetEncodingOptions and TableEncoder.FitTransformWithTargets
+	TargetEncodingMode
+
+	//BagOfWordsEncodingMode counts each token of free text in a learnt vocabulary, see TextOptions
+	BagOfWordsEncodingMode
+
+	//TFIDFEncodingMode weights bag-of-words counts by inverse document frequency and scales them to unit length
+	TFIDFEncodingMode
+
+	//CharNGramEncodingMode hashes the character n-grams of free text into a fixed number of features
+	CharNGramEncodingMode
+)
+
+var encodingModeNames = []string{
+	"BooleanEncodingMode",
+	"OrdinalEncodingMode",
+	"OneHotEncodingMode",
+	"BinaryEncodingMode",
+	"HeatMapEncodingMode",
+	"StringArrayEncodingMode",
+	"NormalizedEncodingMode",
+	"IntRangeEncodingMode",
+	"MinMaxEncodingMode",
+	"SymmetricMinMaxEncodingMode",
+	"RobustEncodingMode",
+	"LogEncodingMode",
+	"BoxCoxEncodingMode",
+	"YeoJohnsonEncodingMode",
+	"QuantileUniformEncodingMode",
+	"QuantileNormalEncodingMode",
+	"EqualWidthBinningEncodingMode",
+	"EqualFrequencyBinningEncodingMode",
+	"DateTimeEncodingMode",
+	"HashingEncodingMode",
+	"FrequencyEncodingMode",
+	"TargetEncodingMode",
+	"BagOfWordsEncodingMode",
+	"TFIDFEncodingMode",
+	"CharNGramEncodingMode",
+}
+
+//String x
+func (e EncodingMode) String() string {
+	return modeName(encodingModeNames, int(e))
+}
+
+//MarshalText x
+func (e EncodingMode) MarshalText() ([]byte, error) {
+	return []byte(e.String()), nil
+}
+
+//UnmarshalText x
+func (e *EncodingMode) UnmarshalText(text []byte) error {
+	i, err := parseModeName(encodingModeNames, "encoding", string(text))
+	if err != nil {
+		return err
+	}
+	*e = EncodingMode(i)
+	return nil
+}
+
+//ColumnRole decides whether a table column feeds the network or is what it predicts
+type ColumnRole int
+
+//ColumnRoles
+const (
+	InputRole ColumnRole = iota
+	OutputRole
+	IgnoredRole
 )
 
+var columnRoleNames = []string{
+	"input",
+	"output",
+	"ignored",
+}
+
+//String x
+func (r ColumnRole) String() string {
+	return modeName(columnRoleNames, int(r))
+}
+
+//MarshalText x
+func (r ColumnRole) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+//UnmarshalText x
+func (r *ColumnRole) UnmarshalText(text []byte) error {
+	i, err := parseModeName(columnRoleNames, "column role", string(text))
+	if err != nil {
+		return err
+	}
+	*r = ColumnRole(i)
+	return nil
+}
+
+//ColumnSpec describes how a named table column is encoded and used
+type ColumnSpec struct {
+	Name     string       `json:"name" yaml:"name"`
+	Encoding EncodingMode `json:"encoding" yaml:"encoding"`
+	Role     ColumnRole   `json:"role" yaml:"role"`
+
+	//Missing is how blank cells are handled, nil hands them to the encoding as is
+	Missing *MissingValues `json:"missing,omitempty" yaml:"missing,omitempty"`
+	//DateTime configures DateTimeEncodingMode, nil uses DefaultDateTimeOptions
+	DateTime *DateTimeOptions `json:"dateTime,omitempty" yaml:"dateTime,omitempty"`
+	//Hashing configures HashingEncodingMode, nil uses DefaultHashWidth
+	Hashing *HashingOptions `json:"hashing,omitempty" yaml:"hashing,omitempty"`
+	//Target configures TargetEncodingMode, nil uses DefaultTargetSmoothing
+	Target *TargetEncodingOptions `json:"target,omitempty" yaml:"target,omitempty"`
+	//Text configures BagOfWordsEncodingMode, TFIDFEncodingMode and CharNGramEncodingMode
+	Text *TextOptions `json:"text,omitempty" yaml:"text,omitempty"`
+	//Binning configures EqualWidthBinningEncodingMode and EqualFrequencyBinningEncodingMode, nil uses DefaultBinCount
+	Binning *BinningOptions `json:"binning,omitempty" yaml:"binning,omitempty"`
+}
+
 type valueEncoding interface {
 	Learn(categories ...string) error
 	Encode(category string) ([]float32, error)
+
+	//Width is how many floats Encode returns once learnt
+	Width() int
+	//Decode maps encoded values, e.g. network outputs, back to the closest category or value
+	Decode(encoded []float32) (string, error)
+	//FeatureNames names each of the Width floats after the source column
+	FeatureNames(column string) []string
 }
 
 //TableEncoding converts strings from usually an excel file to data ready for neural network
 func TableEncoding(encodings []EncodingMode, table [][]string) ([][]float32, error) {
+	te, err := NewTableEncoder(encodings)
+	if err != nil {
+		return nil, err
+	}
+	return te.FitTransform(table)
+}
+
+func newValueEncoding(encoding EncodingMode) (valueEncoding, error) {
+	switch encoding {
+	case BooleanEncodingMode:
+		return &booleanEncoding{}, nil
+	case OrdinalEncodingMode:
+		return &ordinalEncoding{}, nil
+	case OneHotEncodingMode:
+		return &oneHotEncoding{}, nil
+	case BinaryEncodingMode:
+		return &binaryEncoding{}, nil
+	case HeatMapEncodingMode:
+		return &heatMapEncoding{}, nil
+	case StringArrayEncodingMode:
+		return &stringArrayEncoding{}, nil
+	case NormalizedEncodingMode:
+		return &normalizedEncoding{}, nil
+	case IntRangeEncodingMode:
+		return &intRangeEncoding{}, nil
+	case MinMaxEncodingMode:
+		return &scaledEncoding{scaler: &minMaxScaler{Low: 0, High: 1}, suffix: "minmax"}, nil
+	case SymmetricMinMaxEncodingMode:
+		return &scaledEncoding{scaler: &minMaxScaler{Low: -1, High: 1}, suffix: "minmax"}, nil
+	case RobustEncodingMode:
+		return &scaledEncoding{scaler: &robustScaler{}, suffix: "robust"}, nil
+	case LogEncodingMode:
+		return &scaledEncoding{scaler: &logScaler{}, suffix: "log1p"}, nil
+	case BoxCoxEncodingMode:
+		return &scaledEncoding{scaler: &powerScaler{}, suffix: "boxcox"}, nil
+	case YeoJohnsonEncodingMode:
+		return &scaledEncoding{scaler: &powerScaler{YeoJohnson: true}, suffix: "yeojohnson"}, nil
+	case QuantileUniformEncodingMode:
+		return &scaledEncoding{scaler: &quantileScaler{}, suffix: "quantile"}, nil
+	case QuantileNormalEncodingMode:
+		return &scaledEncoding{scaler: &quantileScaler{Normal: true}, suffix: "quantile"}, nil
+	case EqualWidthBinningEncodingMode, EqualFrequencyBinningEncodingMode:
+		return newBinnedEncoding(encoding, BinningOptions{})
+	case DateTimeEncodingMode:
+		d, err := newDateTimeEncoding(DefaultDateTimeOptions)
+		if err != nil {
+			return nil, err
+		}
+		return d, nil
+	case HashingEncodingMode:
+		return &hashingEncoding{width: DefaultHashWidth}, nil
+	case FrequencyEncodingMode:
+		return &frequencyEncoding{}, nil
+	case TargetEncodingMode:
+		return &targetEncoding{
+			TargetEncodingOptions: TargetEncodingOptions{Smoothing: DefaultTargetSmoothing},
+			categories:            map[string]*targetStats{},
+		}, nil
+	case BagOfWordsEncodingMode, TFIDFEncodingMode, CharNGramEncodingMode:
+		return newTextEncoding(encoding, TextOptions{})
+	default:
+		return nil, errors.Errorf("can't find valid encoding '%s'", encoding)
+	}
+}
+
+//newColumnEncoding builds a column's encoding with the spec's options
+func newColumnEncoding(spec ColumnSpec) (valueEncoding, error) {
+	var ve valueEncoding
+	var err error
+	switch {
+	case spec.Encoding == DateTimeEncodingMode && spec.DateTime != nil:
+		ve, err = newDateTimeEncoding(*spec.DateTime)
+	case spec.Encoding == HashingEncodingMode && spec.Hashing != nil:
+		ve, err = newHashingEncoding(*spec.Hashing)
+	case spec.Encoding == TargetEncodingMode && spec.Target != nil:
+		ve, err = newTargetEncoding(*spec.Target)
+	case isTextEncoding(spec.Encoding) && spec.Text != nil:
+		ve, err = newTextEncoding(spec.Encoding, *spec.Text)
+	case isBinningEncoding(spec.Encoding) && spec.Binning != nil:
+		ve, err = newBinnedEncoding(spec.Encoding, *spec.Binning)
+	default:
+		ve, err = newValueEncoding(spec.Encoding)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if mv := spec.Missing; mv != nil && (mv.Policy != MissingPassthrough || mv.Indicator) {
+		//blanks are already a category of their own with a mean like any other
+		if spec.Encoding == TargetEncodingMode {
+			return nil, errors.New("target encoding can't have a missing value policy")
+		}
+		return newMissingValueEncoding(spec.Encoding, ve, *mv)
+	}
+	return ve, nil
+}
+
+//learningFinisher is implemented by encodings that precompute state once every example is learnt,
+//so Encode never mutates and fitted encoders are safe for concurrent use
+type learningFinisher interface {
+	finishLearning() error
+}
+
+//stateRestorer is implemented by encodings that rebuild derived state after being read from JSON
+type stateRestorer interface {
+	restoreState() error
+}
+
+//TableEncoder learns each column's encoding from a table once and applies the same
+//mappings and statistics to new rows, e.g. at prediction time
+type TableEncoder struct {
+	encodings []EncodingMode
+	specs     []ColumnSpec
+	columns   []valueEncoding
+	fitted    bool
+
+	//folds is the fold of each row FitTransformWithTargets encoded out-of-fold
+	folds []int
+
+	logger Logger
+}
+
+//NewTableEncoder x
+func NewTableEncoder(encodings []EncodingMode) (*TableEncoder, error) {
+	if len(encodings) == 0 {
+		return nil, errors.New("no column encodings")
+	}
+
+	te := &TableEncoder{
+		encodings: append([]EncodingMode(nil), encodings...),
+		specs:     make([]ColumnSpec, len(encodings)),
+	}
+	for i, encoding := range encodings {
+		te.specs[i].Encoding = encoding
+	}
+	if err := te.reset(); err != nil {
+		return nil, err
+	}
+	return te, nil
+}
+
+//NewTableEncoderFromSpecs encodes the columns in spec order with each spec's encoding and
+//options, the roles are ignored
+func NewTableEncoderFromSpecs(columns []ColumnSpec) (*TableEncoder, error) {
+	encodings := make([]EncodingMode, len(columns))
+	for i, c := range columns {
+		encodings[i] = c.Encoding
+	}
+
+	te, err := NewTableEncoder(encodings)
+	if err != nil {
+		return nil, err
+	}
+	copy(te.specs, columns)
+	if err := te.reset(); err != nil {
+		return nil, err
+	}
+	return te, nil
+}
+
+func (te *TableEncoder) reset() error {
+	te.columns = make([]valueEncoding, len(te.encodings))
+	for i, spec := range te.specs {
+		ve, err := newColumnEncoding(spec)
+		if err != nil {
+			return errors.Wrapf(err, "column %d", i)
+		}
+		te.columns[i] = ve
+	}
+	te.fitted = false
+	te.folds = nil
+	return nil
+}
+
+//SetLogger replaces DefaultLogger for fitting and transforming
+func (te *TableEncoder) SetLogger(logger Logger) {
+	te.logger = logger
+}
+
+func (te *TableEncoder) log() Logger {
+	return loggerOrDefault(te.logger)
+}
+
+//Encodings x
+func (te *TableEncoder) Encodings() []EncodingMode {
+	return append([]EncodingMode(nil), te.encodings...)
+}
+
+func (te *TableEncoder) checkRow(r int, row []string) error {
+	if len(row) != len(te.encodings) {
+		msg := "row %d has %d columns, expected %d"
+		return errors.Errorf(msg, r, len(row), len(te.encodings))
+	}
+	return nil
+}
+
+//Fit learns every column's encoding from the table, forgetting anything learnt before
+func (te *TableEncoder) Fit(table [][]string) error {
 	rowCount := len(table)
 	if rowCount == 0 {
-		return nil, errors.New("no rows in table")
+		return errors.New("no rows in table")
 	}
 
-	columnCount := len(table[0])
 	if len(table[0]) == 0 {
-		return nil, errors.New("no columns in table")
+		return errors.New("no columns in table")
 	}
 
-	log.Printf("Create encoding instance for all %d columns", columnCount)
-	columnEncodings := make([]valueEncoding, columnCount)
-	for i, encoding := range encodings {
-		switch encoding {
-		case BooleanEncodingMode:
-			columnEncodings[i] = &booleanEncoding{}
-		case OrdinalEncodingMode:
-			columnEncodings[i] = &ordinalEncoding{}
-		case OneHotEncodingMode:
-			columnEncodings[i] = &oneHotEncoding{}
-		case BinaryEncodingMode:
-			columnEncodings[i] = &binaryEncoding{}
-		case HeatMapEncodingMode:
-			columnEncodings[i] = &heatMapEncoding{}
-		case StringArrayEncodingMode:
-			columnEncodings[i] = &stringArrayEncoding{}
-		case NormalizedEncodingMode:
-			columnEncodings[i] = &normalizedEncoding{}
-		case IntRangeEncodingMode:
-			columnEncodings[i] = &intRangeEncoding{}
-		default:
-			msgFormat := "can't find valid encoding '%s' for column %d"
-			msg := fmt.Sprintf(msgFormat, encoding, i)
-			return nil, errors.New(msg)
-		}
-	}
-
-	log.Printf("Learn encodings from %d rows of examples.", rowCount)
-
-	for _, row := range table {
+	if err := te.reset(); err != nil {
+		return err
+	}
+
+	te.log().Debug("fitting encodings", "columns", len(te.encodings), "rows", rowCount)
+	for r, row := range table {
+		if err := te.checkRow(r, row); err != nil {
+			return err
+		}
+
+		for c, col := range row {
+			if err := te.columns[c].Learn(col); err != nil {
+				return errors.Wrapf(err, "can't learn from column %d row %d", c, r)
+			}
+		}
+	}
+	return te.finishFit()
+}
+
+//finishFit completes learning once every row has been seen
+func (te *TableEncoder) finishFit() error {
+	for c, ce := range te.columns {
+		if f, ok := ce.(learningFinisher); ok {
+			if err := f.finishLearning(); err != nil {
+				return errors.Wrapf(err, "can't learn from column %d", c)
+			}
+		}
+	}
+	te.fitted = true
+	return nil
+}
+
+//Transform encodes rows with what Fit learnt
+func (te *TableEncoder) Transform(rows [][]string) ([][]float32, error) {
+	encodedRows := make([][]float32, len(rows))
+	for r, row := range rows {
+		encodedRow, widths, err := te.transformRow(r, row)
+		if err != nil {
+			return nil, err
+		}
+
+		encodedRows[r] = encodedRow
+		if r == 0 {
+			for c, w := range widths {
+				te.log().Debug("column encoded", "column", c, "encoding", te.encodings[c], "width", w)
+			}
+			te.log().Debug("rows encoded", "rows", len(rows), "width", len(encodedRow))
+		}
+	}
+	return encodedRows, nil
+}
+
+//TransformRow encodes a single row, it is safe for concurrent use once fitted
+func (te *TableEncoder) TransformRow(row []string) ([]float32, error) {
+	encoded, _, err := te.transformRow(0, row)
+	return encoded, err
+}
+
+func (te *TableEncoder) transformRow(r int, row []string) ([]float32, []int, error) {
+	if !te.fitted {
+		return nil, nil, errors.New("table encoder isn't fitted")
+	}
+	if err := te.checkRow(r, row); err != nil {
+		return nil, nil, err
+	}
+
+	encodedRow := []float32{}
+	widths := make([]int, len(row))
+	for c, col := range row {
+		encoded, err := te.encodeColumn(c, col)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "column %d row %d", c, r)
+		}
+		widths[c] = len(encoded)
+		encodedRow = append(encodedRow, encoded...)
+	}
+	return encodedRow, widths, nil
+}
+
+//encodeColumn encodes one value of column c, rejecting encodings that can't be trained on
+func (te *TableEncoder) encodeColumn(c int, value string) ([]float32, error) {
+	encoded, err := te.columns[c].Encode(value)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't encode")
+	}
+	return encoded, checkEncoded(value, encoded)
+}
+
+func checkEncoded(value string, encoded []float32) error {
+	if len(encoded) <= 0 {
+		return errors.New("empty encoding")
+	}
+
+	for _, x := range encoded {
+		if math.IsInf(x, 0) {
+			return errors.Errorf("'%s' is encoding as infinity, bad news", value)
+		}
+
+		if math.IsNaN(x) {
+			return errors.Errorf("'%s' is encoding as NaN, bad news", value)
+		}
+	}
+	return nil
+}
+
+//FitTransform x
+func (te *TableEncoder) FitTransform(table [][]string) ([][]float32, error) {
+	if err := te.Fit(table); err != nil {
+		return nil, err
+	}
+	return te.Transform(table)
+}
+
+//DefaultTargetFolds is how many folds training rows are split into for out-of-fold target encoding
+const DefaultTargetFolds = 4
+
+//FitTransformWithTargets is FitTransform for tables with TargetEncodingMode columns, targets
+//being each row's encoded outputs. Rows are split at random by r into balanced folds, so the
+//same seed gives the same folds, and each row's target encodings only use the targets of the
+//other folds, so train on buckets matching Folds, e.g. with DataToFoldBuckets, to avoid
+//leaking targets into test buckets. Rows encoded later with Transform use every target learnt.
+func (te *TableEncoder) FitTransformWithTargets(table [][]string, targets [][]float32, folds int, r *rand.Rand) ([][]float32, error) {
+	rowCount := len(table)
+	if rowCount == 0 {
+		return nil, errors.New("no rows in table")
+	}
+	if len(targets) != rowCount {
+		return nil, errors.Errorf("%d rows of targets for %d rows", len(targets), rowCount)
+	}
+	if folds < 2 {
+		return nil, errors.Errorf("need at least 2 folds, not %d", folds)
+	}
+	if rowCount < folds {
+		return nil, errors.Errorf("%d rows can't be split into %d folds", rowCount, folds)
+	}
+	if r == nil {
+		return nil, errors.New("need a random source to assign folds")
+	}
+
+	if err := te.reset(); err != nil {
+		return nil, err
+	}
+	te.folds = make([]int, rowCount)
+	for i, row := range r.Perm(rowCount) {
+		te.folds[row] = i % folds
+	}
+
+	for r, row := range table {
+		if err := te.checkRow(r, row); err != nil {
+			return nil, err
+		}
+
 		for c, col := range row {
-			ce := columnEncodings[c]
-			err := ce.Learn(col)
+			var err error
+			if tl, ok := te.columns[c].(targetLearner); ok {
+				err = tl.learnTarget(col, targets[r], te.folds[r])
+			} else {
+				err = te.columns[c].Learn(col)
+			}
 			if err != nil {
-				log.Fatal(err, "can't learn from column")
+				return nil, errors.Wrapf(err, "can't learn from column %d row %d", c, r)
 			}
 		}
 	}
+	if err := te.finishFit(); err != nil {
+		return nil, err
+	}
 
-	log.Print("Start encoding.")
 	encodedRows := make([][]float32, rowCount)
 	for r, row := range table {
-		firstRow := r == 0
 		encodedRow := []float32{}
 		for c, col := range row {
-			ce := columnEncodings[c]
+			tl, ok := te.columns[c].(targetLearner)
+			if !ok {
+				encoded, err := te.encodeColumn(c, col)
+				if err != nil {
+					return nil, errors.Wrapf(err, "column %d row %d", c, r)
+				}
+				encodedRow = append(encodedRow, encoded...)
+				continue
+			}
 
-			encoded, err := ce.Encode(col)
+			encoded, err := tl.encodeOutOfFold(col, te.folds[r])
+			if err == nil {
+				err = checkEncoded(col, encoded)
+			}
 			if err != nil {
-				return nil, errors.Wrap(err, "can't encode column")
+				return nil, errors.Wrapf(err, "column %d row %d", c, r)
 			}
-			encodedLength := len(encoded)
+			encodedRow = append(encodedRow, encoded...)
+		}
+		encodedRows[r] = encodedRow
+	}
 
-			if encodedLength <= 0 {
-				return nil, errors.New("empty encoding")
-			}
+	for _, ce := range te.columns {
+		if tl, ok := ce.(targetLearner); ok {
+			tl.forgetFolds()
+		}
+	}
+	return encodedRows, nil
+}
 
-			if firstRow {
-				log.Printf("Column %d will use %d floats.", c, encodedLength)
-			}
+//Folds is the fold of each row the last FitTransformWithTargets encoded, nil otherwise
+func (te *TableEncoder) Folds() []int {
+	if te.folds == nil {
+		return nil
+	}
+	return append([]int(nil), te.folds...)
+}
 
-			for _, x := range encoded {
-				if math.IsInf(x, 0) {
-					msg := "%s is encoding as infinity, bad news <%s:%s>"
-					return nil, errors.Wrapf(err, msg, col, c, r)
-				}
+//needsTargets is whether any column has to be fitted with FitTransformWithTargets
+func (te *TableEncoder) needsTargets() bool {
+	for _, ce := range te.columns {
+		if _, ok := ce.(targetLearner); ok {
+			return true
+		}
+	}
+	return false
+}
 
-				if math.IsNaN(x) {
-					msg := "%s is encoding as NaN, bad news <%s:%s>"
-					return nil, errors.Wrapf(err, msg, col, c, r)
-				}
-			}
+//Widths is how many floats each column encodes to
+func (te *TableEncoder) Widths() []int {
+	widths := make([]int, len(te.columns))
+	for i, ce := range te.columns {
+		widths[i] = ce.Width()
+	}
+	return widths
+}
 
-			encodedRow = append(encodedRow, encoded...)
+//InverseTransform decodes encoded rows, e.g. network outputs, back to one string per column
+func (te *TableEncoder) InverseTransform(rows [][]float32) ([][]string, error) {
+	decodedRows := make([][]string, len(rows))
+	for r, row := range rows {
+		decoded, err := te.inverseTransformRow(r, row)
+		if err != nil {
+			return nil, err
 		}
+		decodedRows[r] = decoded
+	}
+	return decodedRows, nil
+}
 
-		encodedRows[r] = encodedRow
-		if r == 0 {
-			log.Printf("Each row will have %d floats.", len(encodedRow))
+//InverseTransformRow decodes a single row, it is safe for concurrent use once fitted
+func (te *TableEncoder) InverseTransformRow(row []float32) ([]string, error) {
+	return te.inverseTransformRow(0, row)
+}
+
+func (te *TableEncoder) inverseTransformRow(r int, row []float32) ([]string, error) {
+	if !te.fitted {
+		return nil, errors.New("table encoder isn't fitted")
+	}
+
+	widths := te.Widths()
+	total := 0
+	for _, w := range widths {
+		total += w
+	}
+	if len(row) != total {
+		msg := "row %d has %d floats, expected %d"
+		return nil, errors.Errorf(msg, r, len(row), total)
+	}
+
+	decoded := make([]string, len(widths))
+	start := 0
+	for c, w := range widths {
+		d, err := te.columns[c].Decode(row[start : start+w])
+		if err != nil {
+			return nil, errors.Wrapf(err, "can't decode column %d row %d", c, r)
 		}
+		decoded[c] = d
+		start += w
 	}
-	return encodedRows, nil
+	return decoded, nil
+}
+
+type tableEncoderColumnJSON struct {
+	ColumnSpec
+	State json.RawMessage `json:"state"`
+}
+
+type tableEncoderJSON struct {
+	Columns []tableEncoderColumnJSON `json:"columns"`
+}
+
+//MarshalJSON saves what Fit learnt so it can be stored alongside a model
+func (te *TableEncoder) MarshalJSON() ([]byte, error) {
+	if !te.fitted {
+		return nil, errors.New("can't save table encoder that isn't fitted")
+	}
+
+	tej := tableEncoderJSON{
+		Columns: make([]tableEncoderColumnJSON, len(te.columns)),
+	}
+	for i, ce := range te.columns {
+		state, err := json.Marshal(ce)
+		if err != nil {
+			return nil, errors.Wrapf(err, "can't save column %d", i)
+		}
+		tej.Columns[i] = tableEncoderColumnJSON{
+			ColumnSpec: te.specs[i],
+			State:      state,
+		}
+	}
+	return json.Marshal(tej)
+}
+
+//UnmarshalJSON restores a fitted encoder saved with MarshalJSON
+func (te *TableEncoder) UnmarshalJSON(buf []byte) error {
+	tej := tableEncoderJSON{}
+	if err := json.Unmarshal(buf, &tej); err != nil {
+		return errors.Wrap(err, "can't read table encoder")
+	}
+
+	te.encodings = make([]EncodingMode, len(tej.Columns))
+	te.specs = make([]ColumnSpec, len(tej.Columns))
+	for i, c := range tej.Columns {
+		te.encodings[i] = c.Encoding
+		te.specs[i] = c.ColumnSpec
+	}
+	if err := te.reset(); err != nil {
+		return err
+	}
+
+	for i, c := range tej.Columns {
+		ce := te.columns[i]
+		if err := json.Unmarshal(c.State, ce); err != nil {
+			return errors.Wrapf(err, "can't read column %d", i)
+		}
+		if r, ok := ce.(stateRestorer); ok {
+			if err := r.restoreState(); err != nil {
+				return errors.Wrapf(err, "can't restore column %d", i)
+			}
+		}
+	}
+	te.fitted = true
+	return nil
+}
+
+//GobEncode lets NeuralNetwork.Marshal encode the encoders in a network's metadata
+func (te *TableEncoder) GobEncode() ([]byte, error) {
+	return te.MarshalJSON()
+}
+
+//GobDecode x
+func (te *TableEncoder) GobDecode(buf []byte) error {
+	return te.UnmarshalJSON(buf)
 }
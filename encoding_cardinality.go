@@ -0,0 +1,381 @@
+package cogent
+
+//Encodings for columns with too many categories to one-hot, e.g. ZIP codes or product IDs.
+//Each is a fixed width no matter how many categories are learnt.
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+
+	"github.com/pkg/errors"
+)
+
+//DefaultHashWidth is how many features HashingEncodingMode uses when HashingOptions doesn't say
+const DefaultHashWidth = 16
+
+//HashingOptions configures HashingEncodingMode
+type HashingOptions struct {
+	//Width is how many buckets categories are hashed into
+	Width int `json:"width,omitempty" yaml:"width,omitempty"`
+}
+
+//hashingEncoding hashes each category to one of Width features, the sign of the 1 is also
+//taken from the hash so collisions tend to cancel out rather than add up
+type hashingEncoding struct {
+	width int
+}
+
+func newHashingEncoding(options HashingOptions) (*hashingEncoding, error) {
+	if options.Width < 0 {
+		return nil, errors.Errorf("hashing width %d can't be negative", options.Width)
+	}
+	if options.Width == 0 {
+		options.Width = DefaultHashWidth
+	}
+	return &hashingEncoding{width: options.Width}, nil
+}
+
+func (h *hashingEncoding) Learn(categories ...string) error {
+	return nil
+}
+
+func (h *hashingEncoding) Encode(category string) ([]float32, error) {
+	encoded := make([]float32, h.width)
+	i, sign := hashFeature(category, h.width)
+	encoded[i] = sign
+	return encoded, nil
+}
+
+//hashFeature picks value's feature out of width with FNV-1a, the top bit of the hash is the sign
+func hashFeature(value string, width int) (int, float32) {
+	hash := fnv.New64a()
+	hash.Write([]byte(value))
+	sum := hash.Sum64()
+
+	sign := float32(1)
+	if sum>>63 == 1 {
+		sign = -1
+	}
+	return int((sum & (1<<63 - 1)) % uint64(width)), sign
+}
+
+func (h *hashingEncoding) Width() int {
+	return h.width
+}
+
+func (h *hashingEncoding) FeatureNames(column string) []string {
+	names := make([]string, h.Width())
+	for i := range names {
+		names[i] = fmt.Sprintf("%s_hash%d", column, i)
+	}
+	return names
+}
+
+//Decode can't work as hashing forgets the categories
+func (h *hashingEncoding) Decode(encoded []float32) (string, error) {
+	return "", errors.New("hashed categories can't be decoded")
+}
+
+func (h *hashingEncoding) MarshalJSON() ([]byte, error) {
+	return json.Marshal(HashingOptions{h.width})
+}
+
+func (h *hashingEncoding) UnmarshalJSON(buf []byte) error {
+	options := HashingOptions{}
+	if err := json.Unmarshal(buf, &options); err != nil {
+		return err
+	}
+	h.width = options.Width
+	return nil
+}
+
+//frequencyEncoding replaces each category with the fraction of learnt rows it was in,
+//unknown categories are 0
+type frequencyEncoding struct {
+	counts map[string]int
+	total  int
+}
+
+func (f *frequencyEncoding) Learn(categories ...string) error {
+	if f.counts == nil {
+		f.counts = map[string]int{}
+	}
+	for _, c := range categories {
+		f.counts[c]++
+		f.total++
+	}
+	return nil
+}
+
+func (f *frequencyEncoding) Encode(category string) ([]float32, error) {
+	if f.total == 0 {
+		return nil, errors.New("no mappings, did you Learn examples first?")
+	}
+	return []float32{float32(f.counts[category]) / float32(f.total)}, nil
+}
+
+func (f *frequencyEncoding) Width() int {
+	return 1
+}
+
+func (f *frequencyEncoding) FeatureNames(column string) []string {
+	return []string{column + "_frequency"}
+}
+
+//Decode returns the category with the closest frequency, categories seen equally often are ambiguous
+func (f *frequencyEncoding) Decode(encoded []float32) (string, error) {
+	if err := checkDecodeWidth(f, encoded); err != nil {
+		return "", err
+	}
+	categories := make([]string, 0, len(f.counts))
+	for c := range f.counts {
+		categories = append(categories, c)
+	}
+	return nearestCategory(f, categories, encoded)
+}
+
+type frequencyEncodingJSON struct {
+	Counts map[string]int `json:"counts"`
+	Total  int            `json:"total"`
+}
+
+func (f *frequencyEncoding) MarshalJSON() ([]byte, error) {
+	return json.Marshal(frequencyEncodingJSON{f.counts, f.total})
+}
+
+func (f *frequencyEncoding) UnmarshalJSON(buf []byte) error {
+	fj := frequencyEncodingJSON{}
+	if err := json.Unmarshal(buf, &fj); err != nil {
+		return err
+	}
+	f.counts, f.total = fj.Counts, fj.Total
+	return nil
+}
+
+//DefaultTargetSmoothing is how many rows of the overall mean each category's mean is blended with
+const DefaultTargetSmoothing = 10
+
+//TargetEncodingOptions configures TargetEncodingMode
+type TargetEncodingOptions struct {
+	//Smoothing pulls rare categories towards the overall mean, defaults to DefaultTargetSmoothing
+	Smoothing float32 `json:"smoothing,omitempty" yaml:"smoothing,omitempty"`
+}
+
+//targetLearner is implemented by encodings that learn from the encoded output columns,
+//see TableEncoder.FitTransformWithTargets
+type targetLearner interface {
+	learnTarget(category string, target []float32, fold int) error
+	//encodeOutOfFold encodes a training row with what was learnt from every other fold
+	encodeOutOfFold(category string, fold int) ([]float32, error)
+	//forgetFolds drops the per fold statistics once the training rows are encoded
+	forgetFolds()
+}
+
+//targetStats are the sums of the targets of the rows in a category
+type targetStats struct {
+	Count int       `json:"count"`
+	Sums  []float32 `json:"sums"`
+}
+
+func (s *targetStats) add(target []float32) {
+	if s.Sums == nil {
+		s.Sums = make([]float32, len(target))
+	}
+	s.Count++
+	for i, x := range target {
+		s.Sums[i] += x
+	}
+}
+
+//targetEncoding replaces each category with the smoothed mean of the encoded outputs of its
+//rows, (sum + smoothing*prior) / (count + smoothing). Training rows are encoded out-of-fold
+//so a row's own target never leaks into its inputs.
+type targetEncoding struct {
+	TargetEncodingOptions
+	width      int
+	prior      []float32
+	categories map[string]*targetStats
+
+	//total, folds and foldTotals are only kept while fitting
+	total      targetStats
+	folds      []map[string]*targetStats
+	foldTotals []targetStats
+}
+
+func newTargetEncoding(options TargetEncodingOptions) (*targetEncoding, error) {
+	if options.Smoothing < 0 {
+		return nil, errors.Errorf("target smoothing %g can't be negative", options.Smoothing)
+	}
+	if options.Smoothing == 0 {
+		options.Smoothing = DefaultTargetSmoothing
+	}
+	return &targetEncoding{
+		TargetEncodingOptions: options,
+		categories:            map[string]*targetStats{},
+	}, nil
+}
+
+func (e *targetEncoding) Learn(categories ...string) error {
+	return errors.New("target encoding learns from the output columns, use FitTransformWithTargets")
+}
+
+func (e *targetEncoding) learnTarget(category string, target []float32, fold int) error {
+	if len(target) == 0 {
+		return errors.New("no target values")
+	}
+	if e.width == 0 {
+		e.width = len(target)
+	}
+	if len(target) != e.width {
+		return errors.Errorf("%d target values, expected %d", len(target), e.width)
+	}
+	if fold < 0 {
+		return errors.Errorf("invalid fold %d", fold)
+	}
+
+	for len(e.folds) <= fold {
+		e.folds = append(e.folds, map[string]*targetStats{})
+		e.foldTotals = append(e.foldTotals, targetStats{})
+	}
+	e.total.add(target)
+	addTargetStats(e.categories, category, target)
+	addTargetStats(e.folds[fold], category, target)
+	e.foldTotals[fold].add(target)
+	return nil
+}
+
+func addTargetStats(stats map[string]*targetStats, category string, target []float32) {
+	s, ok := stats[category]
+	if !ok {
+		s = &targetStats{}
+		stats[category] = s
+	}
+	s.add(target)
+}
+
+func (e *targetEncoding) finishLearning() error {
+	if e.width == 0 {
+		return errors.New("no targets learnt")
+	}
+
+	e.prior = e.total.mean()
+	return nil
+}
+
+func (s *targetStats) merge(other *targetStats, sign int) {
+	if s.Sums == nil {
+		s.Sums = make([]float32, len(other.Sums))
+	}
+	s.Count += sign * other.Count
+	for i, x := range other.Sums {
+		s.Sums[i] += float32(sign) * x
+	}
+}
+
+func (s *targetStats) mean() []float32 {
+	mean := make([]float32, len(s.Sums))
+	if s.Count == 0 {
+		return mean
+	}
+	for i, x := range s.Sums {
+		mean[i] = x / float32(s.Count)
+	}
+	return mean
+}
+
+//smoothed blends the category's mean with the prior, unknown categories are the prior
+func (e *targetEncoding) smoothed(s targetStats, prior []float32) []float32 {
+	encoded := make([]float32, e.width)
+	for i := range encoded {
+		var sum float32
+		if s.Sums != nil {
+			sum = s.Sums[i]
+		}
+		encoded[i] = (sum + e.Smoothing*prior[i]) / (float32(s.Count) + e.Smoothing)
+	}
+	return encoded
+}
+
+func (e *targetEncoding) Encode(category string) ([]float32, error) {
+	if e.prior == nil {
+		return nil, errors.New("no targets, did you FitTransformWithTargets first?")
+	}
+	s := targetStats{}
+	if c, ok := e.categories[category]; ok {
+		s = *c
+	}
+	return e.smoothed(s, e.prior), nil
+}
+
+func (e *targetEncoding) encodeOutOfFold(category string, fold int) ([]float32, error) {
+	if fold < 0 || fold >= len(e.folds) {
+		return nil, errors.Errorf("no targets learnt for fold %d", fold)
+	}
+
+	//everything learnt minus this fold
+	total := targetStats{}
+	total.merge(&e.total, 1)
+	total.merge(&e.foldTotals[fold], -1)
+
+	s := targetStats{}
+	if c, ok := e.categories[category]; ok {
+		s.merge(c, 1)
+		if inFold, ok := e.folds[fold][category]; ok {
+			s.merge(inFold, -1)
+		}
+	}
+	return e.smoothed(s, total.mean()), nil
+}
+
+func (e *targetEncoding) forgetFolds() {
+	e.total, e.folds, e.foldTotals = targetStats{}, nil, nil
+}
+
+func (e *targetEncoding) Width() int {
+	return e.width
+}
+
+func (e *targetEncoding) FeatureNames(column string) []string {
+	if e.width == 1 {
+		return []string{column + "_target"}
+	}
+	names := make([]string, e.width)
+	for i := range names {
+		names[i] = fmt.Sprintf("%s_target%d", column, i)
+	}
+	return names
+}
+
+//Decode returns the category whose smoothed mean is closest
+func (e *targetEncoding) Decode(encoded []float32) (string, error) {
+	if err := checkDecodeWidth(e, encoded); err != nil {
+		return "", err
+	}
+	categories := make([]string, 0, len(e.categories))
+	for c := range e.categories {
+		categories = append(categories, c)
+	}
+	return nearestCategory(e, categories, encoded)
+}
+
+type targetEncodingJSON struct {
+	Width      int                     `json:"width"`
+	Prior      []float32               `json:"prior"`
+	Categories map[string]*targetStats `json:"categories"`
+}
+
+func (e *targetEncoding) MarshalJSON() ([]byte, error) {
+	return json.Marshal(targetEncodingJSON{e.width, e.prior, e.categories})
+}
+
+func (e *targetEncoding) UnmarshalJSON(buf []byte) error {
+	ej := targetEncodingJSON{}
+	if err := json.Unmarshal(buf, &ej); err != nil {
+		return err
+	}
+	e.width, e.prior, e.categories = ej.Width, ej.Prior, ej.Categories
+	if e.categories == nil {
+		e.categories = map[string]*targetStats{}
+	}
+	return nil
+}
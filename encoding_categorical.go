@@ -4,7 +4,9 @@ package cogent
 //shows most helpful to clearest algorithms to be Ordinal, OneHot and Binary.
 //Heatmap is from my own research on using time based categories in non-recurrent neural networks.
 import (
+	"encoding/json"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -39,6 +41,24 @@ func (b *booleanEncoding) Encode(category string) ([]float32, error) {
 	return []float32{1}, nil
 }
 
+func (b *booleanEncoding) Width() int {
+	return 1
+}
+
+func (b *booleanEncoding) FeatureNames(column string) []string {
+	return []string{column}
+}
+
+func (b *booleanEncoding) Decode(encoded []float32) (string, error) {
+	if err := checkDecodeWidth(b, encoded); err != nil {
+		return "", err
+	}
+	if encoded[0] >= 0.5 {
+		return "true", nil
+	}
+	return "false", nil
+}
+
 type ordinalEncoding struct {
 	mapping map[string]float32
 	nextID  float32
@@ -67,6 +87,43 @@ func (o *ordinalEncoding) Encode(category string) ([]float32, error) {
 	return []float32{value}, nil
 }
 
+func (o *ordinalEncoding) Width() int {
+	return 1
+}
+
+func (o *ordinalEncoding) FeatureNames(column string) []string {
+	return []string{column + "_ordinal"}
+}
+
+func (o *ordinalEncoding) Decode(encoded []float32) (string, error) {
+	if err := checkDecodeWidth(o, encoded); err != nil {
+		return "", err
+	}
+	categories := make([]string, 0, len(o.mapping))
+	for c := range o.mapping {
+		categories = append(categories, c)
+	}
+	return nearestCategory(o, categories, encoded)
+}
+
+type ordinalEncodingJSON struct {
+	Mapping map[string]float32 `json:"mapping"`
+	NextID  float32            `json:"nextID"`
+}
+
+func (o *ordinalEncoding) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ordinalEncodingJSON{o.mapping, o.nextID})
+}
+
+func (o *ordinalEncoding) UnmarshalJSON(buf []byte) error {
+	oj := ordinalEncodingJSON{}
+	if err := json.Unmarshal(buf, &oj); err != nil {
+		return err
+	}
+	o.mapping, o.nextID = oj.Mapping, oj.NextID
+	return nil
+}
+
 type oneHotEncoding struct {
 	mapping map[string]int
 	nextID  int
@@ -100,6 +157,52 @@ func (o *oneHotEncoding) Encode(category string) ([]float32, error) {
 	return oneHot, nil
 }
 
+func (o *oneHotEncoding) Width() int {
+	return len(o.mapping)
+}
+
+func (o *oneHotEncoding) FeatureNames(column string) []string {
+	return categoryFeatureNames(column, o.categories())
+}
+
+func (o *oneHotEncoding) Decode(encoded []float32) (string, error) {
+	if err := checkDecodeWidth(o, encoded); err != nil {
+		return "", err
+	}
+	i := argmax(encoded)
+	if i < 0 {
+		return "", errors.New("can't decode, no value is larger than the rest")
+	}
+	return o.categories()[i], nil
+}
+
+//categories orders the learnt categories by column
+func (o *oneHotEncoding) categories() []string {
+	categories := make([]string, len(o.mapping))
+	for c, i := range o.mapping {
+		categories[i] = c
+	}
+	return categories
+}
+
+type oneHotEncodingJSON struct {
+	Mapping map[string]int `json:"mapping"`
+	NextID  int            `json:"nextID"`
+}
+
+func (o *oneHotEncoding) MarshalJSON() ([]byte, error) {
+	return json.Marshal(oneHotEncodingJSON{o.mapping, o.nextID})
+}
+
+func (o *oneHotEncoding) UnmarshalJSON(buf []byte) error {
+	oj := oneHotEncodingJSON{}
+	if err := json.Unmarshal(buf, &oj); err != nil {
+		return err
+	}
+	o.mapping, o.nextID = oj.Mapping, oj.NextID
+	return nil
+}
+
 type binaryEncoding struct {
 	mapping map[string]int64
 	nextID  int64
@@ -151,6 +254,50 @@ func (b *binaryEncoding) Encode(category string) ([]float32, error) {
 	return binary, nil
 }
 
+func (b *binaryEncoding) Width() int {
+	if len(b.mapping) <= 1 {
+		return 1
+	}
+	return int(math.Ceil(math.Log2(float32(len(b.mapping)))))
+}
+
+func (b *binaryEncoding) FeatureNames(column string) []string {
+	names := make([]string, b.Width())
+	for i := range names {
+		names[i] = fmt.Sprintf("%s_bit%d", column, len(names)-1-i)
+	}
+	return names
+}
+
+func (b *binaryEncoding) Decode(encoded []float32) (string, error) {
+	if err := checkDecodeWidth(b, encoded); err != nil {
+		return "", err
+	}
+	categories := make([]string, 0, len(b.mapping))
+	for c := range b.mapping {
+		categories = append(categories, c)
+	}
+	return nearestCategory(b, categories, encoded)
+}
+
+type binaryEncodingJSON struct {
+	Mapping map[string]int64 `json:"mapping"`
+	NextID  int64            `json:"nextID"`
+}
+
+func (b *binaryEncoding) MarshalJSON() ([]byte, error) {
+	return json.Marshal(binaryEncodingJSON{b.mapping, b.nextID})
+}
+
+func (b *binaryEncoding) UnmarshalJSON(buf []byte) error {
+	bj := binaryEncodingJSON{}
+	if err := json.Unmarshal(buf, &bj); err != nil {
+		return err
+	}
+	b.mapping, b.nextID = bj.Mapping, bj.NextID
+	return nil
+}
+
 type stringArrayEncoding struct {
 	ohe oneHotEncoding
 }
@@ -183,6 +330,37 @@ func (bsa *stringArrayEncoding) Encode(arr string) ([]float32, error) {
 	return response, nil
 }
 
+func (bsa *stringArrayEncoding) Width() int {
+	return bsa.ohe.Width()
+}
+
+func (bsa *stringArrayEncoding) FeatureNames(column string) []string {
+	return bsa.ohe.FeatureNames(column)
+}
+
+//Decode joins every category whose column is at least 0.5
+func (bsa *stringArrayEncoding) Decode(encoded []float32) (string, error) {
+	if err := checkDecodeWidth(bsa, encoded); err != nil {
+		return "", err
+	}
+
+	var picked []string
+	for i, c := range bsa.ohe.categories() {
+		if encoded[i] >= 0.5 {
+			picked = append(picked, c)
+		}
+	}
+	return strings.Join(picked, ","), nil
+}
+
+func (bsa *stringArrayEncoding) MarshalJSON() ([]byte, error) {
+	return bsa.ohe.MarshalJSON()
+}
+
+func (bsa *stringArrayEncoding) UnmarshalJSON(buf []byte) error {
+	return bsa.ohe.UnmarshalJSON(buf)
+}
+
 type heatMapEncoding struct {
 	oneHot oneHotEncoding
 }
@@ -225,6 +403,48 @@ func (hm *heatMapEncoding) Encode(csvArr string) ([]float32, error) {
 	return combined, nil
 }
 
+func (hm *heatMapEncoding) Width() int {
+	return hm.oneHot.Width()
+}
+
+func (hm *heatMapEncoding) FeatureNames(column string) []string {
+	return hm.oneHot.FeatureNames(column)
+}
+
+//Decode orders the categories from hottest to coldest, dropping columns colder than
+//half the smallest weight a single category can have
+func (hm *heatMapEncoding) Decode(encoded []float32) (string, error) {
+	if err := checkDecodeWidth(hm, encoded); err != nil {
+		return "", err
+	}
+
+	threshold := math.Pow(0.5, float32(len(encoded)+1))
+	categories := hm.oneHot.categories()
+	var picked []int
+	for i, x := range encoded {
+		if x >= threshold {
+			picked = append(picked, i)
+		}
+	}
+	sort.SliceStable(picked, func(i, j int) bool {
+		return encoded[picked[i]] > encoded[picked[j]]
+	})
+
+	parts := make([]string, len(picked))
+	for i, p := range picked {
+		parts[i] = categories[p]
+	}
+	return strings.Join(parts, ","), nil
+}
+
+func (hm *heatMapEncoding) MarshalJSON() ([]byte, error) {
+	return hm.oneHot.MarshalJSON()
+}
+
+func (hm *heatMapEncoding) UnmarshalJSON(buf []byte) error {
+	return hm.oneHot.UnmarshalJSON(buf)
+}
+
 func (hm *heatMapEncoding) EncodeAll(categories []string, ascendingPriority bool) ([]float32, error) {
 	ordered := categories
 	if ascendingPriority {
@@ -255,3 +475,40 @@ func reverseStrings(input []string) []string {
 	}
 	return append(reverseStrings(input[1:]), input[0])
 }
+
+//nearestCategory finds the category whose encoding is closest to encoded
+func nearestCategory(ve valueEncoding, categories []string, encoded []float32) (string, error) {
+	if len(categories) == 0 {
+		return "", errors.New("no mappings, did you Learn examples first?")
+	}
+	sort.Strings(categories)
+
+	nearest, nearestDistance := "", float32(math.MaxFloat32)
+	for _, c := range categories {
+		code, err := ve.Encode(c)
+		if err != nil {
+			return "", errors.Wrapf(err, "can't encode '%s'", c)
+		}
+
+		var distance float32
+		for i, x := range code {
+			diff := x - encoded[i]
+			distance += diff * diff
+		}
+		if distance < nearestDistance {
+			nearest, nearestDistance = c, distance
+		}
+	}
+	return nearest, nil
+}
+
+func checkDecodeWidth(ve valueEncoding, encoded []float32) error {
+	w := ve.Width()
+	if w == 0 {
+		return errors.New("no mappings, did you Learn examples first?")
+	}
+	if len(encoded) != w {
+		return errors.Errorf("can't decode %d values, expected %d", len(encoded), w)
+	}
+	return nil
+}
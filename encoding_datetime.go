@@ -0,0 +1,192 @@
+package cogent
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+//DateTimeOptions configures DateTimeEncodingMode
+type DateTimeOptions struct {
+	//Layouts are tried in order with time.Parse, the first is also used to Decode
+	Layouts []string `json:"layouts,omitempty" yaml:"layouts,omitempty"`
+	//Elapsed adds the time since the earliest learnt time, scaled so the latest is 1
+	Elapsed bool `json:"elapsed,omitempty" yaml:"elapsed,omitempty"`
+	//Weekend adds a feature that is 1 on Saturdays and Sundays
+	Weekend bool `json:"weekend,omitempty" yaml:"weekend,omitempty"`
+	//Holidays are dates, as 2006-01-02, flagged by an extra feature
+	Holidays []string `json:"holidays,omitempty" yaml:"holidays,omitempty"`
+}
+
+//DefaultDateTimeOptions parses the layouts InferEncodings recognises as dates, a copy of them so
+//changing these doesn't change what InferEncodings recognises
+var DefaultDateTimeOptions = DateTimeOptions{
+	Layouts: append([]string(nil), dateLayouts...),
+}
+
+const holidayLayout = "2006-01-02"
+
+//dateTimeEncoding emits sin/cos pairs for the hour of day, day of week and month so the
+//ends of each cycle sit next to each other, e.g. 23:00 is close to 00:00
+type dateTimeEncoding struct {
+	DateTimeOptions
+	holidays map[string]bool
+
+	learnt   bool
+	min, max int64
+}
+
+func newDateTimeEncoding(options DateTimeOptions) (*dateTimeEncoding, error) {
+	if len(options.Layouts) == 0 {
+		options.Layouts = DefaultDateTimeOptions.Layouts
+	}
+
+	d := &dateTimeEncoding{
+		DateTimeOptions: options,
+		holidays:        map[string]bool{},
+	}
+	for _, h := range options.Holidays {
+		t, err := time.Parse(holidayLayout, strings.TrimSpace(h))
+		if err != nil {
+			return nil, errors.Wrapf(err, "can't parse holiday '%s'", h)
+		}
+		d.holidays[t.Format(holidayLayout)] = true
+	}
+	return d, nil
+}
+
+func (d *dateTimeEncoding) parse(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range d.Layouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.Errorf("can't parse '%s' as a date with any of %d layouts", value, len(d.Layouts))
+}
+
+func (d *dateTimeEncoding) Learn(values ...string) error {
+	for _, v := range values {
+		t, err := d.parse(v)
+		if err != nil {
+			return err
+		}
+
+		unix := t.Unix()
+		if !d.learnt || unix < d.min {
+			d.min = unix
+		}
+		if !d.learnt || unix > d.max {
+			d.max = unix
+		}
+		d.learnt = true
+	}
+	return nil
+}
+
+func cyclical(x, period float64) (float32, float32) {
+	angle := 2 * math.Pi * x / period
+	return float32(math.Sin(angle)), float32(math.Cos(angle))
+}
+
+func (d *dateTimeEncoding) Encode(value string) ([]float32, error) {
+	t, err := d.parse(value)
+	if err != nil {
+		return nil, err
+	}
+
+	hour := float64(t.Hour()) + float64(t.Minute())/60 + float64(t.Second())/3600
+	hourSin, hourCos := cyclical(hour, 24)
+	weekdaySin, weekdayCos := cyclical(float64(t.Weekday()), 7)
+	monthSin, monthCos := cyclical(float64(t.Month()-1), 12)
+	encoded := []float32{hourSin, hourCos, weekdaySin, weekdayCos, monthSin, monthCos}
+
+	if d.Elapsed {
+		var elapsed float32
+		if d.max > d.min {
+			elapsed = float32(t.Unix()-d.min) / float32(d.max-d.min)
+		}
+		encoded = append(encoded, elapsed)
+	}
+	if d.Weekend {
+		var weekend float32
+		if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+			weekend = 1
+		}
+		encoded = append(encoded, weekend)
+	}
+	if len(d.holidays) > 0 {
+		var holiday float32
+		if d.holidays[t.Format(holidayLayout)] {
+			holiday = 1
+		}
+		encoded = append(encoded, holiday)
+	}
+	return encoded, nil
+}
+
+func (d *dateTimeEncoding) Width() int {
+	width := 6
+	if d.Elapsed {
+		width++
+	}
+	if d.Weekend {
+		width++
+	}
+	if len(d.holidays) > 0 {
+		width++
+	}
+	return width
+}
+
+//Decode needs the elapsed feature, the cycles alone can't tell which year it is
+func (d *dateTimeEncoding) Decode(encoded []float32) (string, error) {
+	if err := checkDecodeWidth(d, encoded); err != nil {
+		return "", err
+	}
+	if !d.Elapsed {
+		return "", errors.New("can't decode dates without the elapsed feature")
+	}
+
+	elapsed := float64(encoded[6])
+	unix := d.min + int64(math.Round(elapsed*float64(d.max-d.min)))
+	return time.Unix(unix, 0).UTC().Format(d.Layouts[0]), nil
+}
+
+func (d *dateTimeEncoding) FeatureNames(column string) []string {
+	names := []string{}
+	for _, cycle := range []string{"hour", "weekday", "month"} {
+		names = append(names, column+"_"+cycle+"_sin", column+"_"+cycle+"_cos")
+	}
+	if d.Elapsed {
+		names = append(names, column+"_elapsed")
+	}
+	if d.Weekend {
+		names = append(names, column+"_weekend")
+	}
+	if len(d.holidays) > 0 {
+		names = append(names, column+"_holiday")
+	}
+	return names
+}
+
+type dateTimeEncodingJSON struct {
+	Min int64 `json:"min"`
+	Max int64 `json:"max"`
+}
+
+func (d *dateTimeEncoding) MarshalJSON() ([]byte, error) {
+	return json.Marshal(dateTimeEncodingJSON{d.min, d.max})
+}
+
+func (d *dateTimeEncoding) UnmarshalJSON(buf []byte) error {
+	dj := dateTimeEncodingJSON{}
+	if err := json.Unmarshal(buf, &dj); err != nil {
+		return err
+	}
+	d.min, d.max, d.learnt = dj.Min, dj.Max, true
+	return nil
+}
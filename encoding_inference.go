@@ -0,0 +1,286 @@
+package cogent
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	math "github.com/chewxy/math32"
+
+	"github.com/pkg/errors"
+)
+
+//ColumnKind is what InferEncodings decided a column's values look like
+type ColumnKind int
+
+//ColumnKinds
+const (
+	EmptyKind ColumnKind = iota
+	BooleanKind
+	NumericKind
+	IntegerKind
+	DateKind
+	ListKind
+	CategoricalKind
+	IdentifierKind
+)
+
+var columnKindNames = []string{
+	"empty",
+	"boolean",
+	"numeric",
+	"integer",
+	"date",
+	"list",
+	"categorical",
+	"identifier",
+}
+
+//String x
+func (k ColumnKind) String() string {
+	return modeName(columnKindNames, int(k))
+}
+
+//MarshalText x
+func (k ColumnKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+//InferenceOptions tunes the thresholds InferEncodings uses, zero values use the defaults
+type InferenceOptions struct {
+	//MaxOneHotCardinality is the most categories one-hot encoded, columns with more use binary
+	MaxOneHotCardinality int
+	//MaxIntRange is the widest integer range encoded one column per value, wider ranges are normalized
+	MaxIntRange int
+}
+
+//DefaultInferenceOptions x
+var DefaultInferenceOptions = InferenceOptions{
+	MaxOneHotCardinality: 16,
+	MaxIntRange:          12,
+}
+
+//ColumnProfile summarises a column and explains the encoding proposed for it
+type ColumnProfile struct {
+	Name     string       `json:"name"`
+	Kind     ColumnKind   `json:"kind"`
+	Rows     int          `json:"rows"`
+	Missing  int          `json:"missing"`
+	Distinct int          `json:"distinct"`
+	Min      float32      `json:"min,omitempty"`
+	Max      float32      `json:"max,omitempty"`
+	Examples []string     `json:"examples"`
+	Encoding EncodingMode `json:"encoding"`
+	Role     ColumnRole   `json:"role"`
+	Reason   string       `json:"reason"`
+
+	//MissingValues is the policy proposed for the blank cells, if there are any
+	MissingValues *MissingValues `json:"missingValues,omitempty"`
+}
+
+//InferenceReport is the profile of every column, in table order
+type InferenceReport struct {
+	Columns []ColumnProfile `json:"columns"`
+}
+
+//String formats the report as a table for review
+func (r InferenceReport) String() string {
+	buf := &bytes.Buffer{}
+	w := tabwriter.NewWriter(buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "column\tkind\tmissing\tdistinct\tencoding\trole\treason")
+	for _, c := range r.Columns {
+		encoding := c.Encoding.String()
+		if c.Role == IgnoredRole {
+			encoding = "-"
+		}
+		missing := fmt.Sprintf("%d/%d", c.Missing, c.Rows)
+		if c.MissingValues != nil {
+			missing += " " + c.MissingValues.Policy.String()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\t%s\n", c.Name, c.Kind, missing, c.Distinct, encoding, c.Role, c.Reason)
+	}
+	w.Flush()
+	return buf.String()
+}
+
+//dateLayouts are tried in order when looking for date columns
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+	"01/02/2006",
+	"02-Jan-2006",
+}
+
+func isDate(value string) bool {
+	for _, layout := range dateLayouts {
+		if _, err := time.Parse(layout, value); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func isBoolean(value string) bool {
+	switch strings.ToLower(value) {
+	case "true", "false", "t", "f", "0", "1":
+		return true
+	}
+	return false
+}
+
+//InferEncodings profiles each column of the table and proposes an encoding for it. Every
+//column is proposed as an input, so review the spec and mark the outputs before training.
+//names default to column_0, column_1 and so on when nil.
+func InferEncodings(table [][]string, names []string, opts InferenceOptions) ([]ColumnSpec, InferenceReport, error) {
+	if len(table) == 0 || len(table[0]) == 0 {
+		return nil, InferenceReport{}, errors.New("no rows or columns in table")
+	}
+	columnCount := len(table[0])
+	if names != nil && len(names) != columnCount {
+		msg := "%d column names for %d columns"
+		return nil, InferenceReport{}, errors.Errorf(msg, len(names), columnCount)
+	}
+	for r, row := range table {
+		if len(row) != columnCount {
+			msg := "row %d has %d columns, expected %d"
+			return nil, InferenceReport{}, errors.Errorf(msg, r, len(row), columnCount)
+		}
+	}
+
+	if opts.MaxOneHotCardinality <= 0 {
+		opts.MaxOneHotCardinality = DefaultInferenceOptions.MaxOneHotCardinality
+	}
+	if opts.MaxIntRange <= 0 {
+		opts.MaxIntRange = DefaultInferenceOptions.MaxIntRange
+	}
+
+	report := InferenceReport{
+		Columns: make([]ColumnProfile, columnCount),
+	}
+	specs := make([]ColumnSpec, columnCount)
+	for c := range specs {
+		name := fmt.Sprintf("column_%d", c)
+		if names != nil {
+			name = names[c]
+		}
+
+		values := make([]string, len(table))
+		for r, row := range table {
+			values[r] = row[c]
+		}
+
+		p := profileColumn(name, values, opts)
+		report.Columns[c] = p
+		specs[c] = ColumnSpec{
+			Name:     p.Name,
+			Encoding: p.Encoding,
+			Role:     p.Role,
+			Missing:  p.MissingValues,
+		}
+	}
+	return specs, report, nil
+}
+
+func profileColumn(name string, values []string, opts InferenceOptions) ColumnProfile {
+	p := ColumnProfile{
+		Name: name,
+		Rows: len(values),
+		Role: InputRole,
+	}
+
+	distinct := map[string]bool{}
+	items := map[string]bool{}
+	allBoolean, allNumeric, allInteger, allDates, anyList := true, true, true, true, false
+	p.Min, p.Max = math.MaxFloat32, -math.MaxFloat32
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			p.Missing++
+			continue
+		}
+
+		if !distinct[v] {
+			distinct[v] = true
+			if len(p.Examples) < 5 {
+				p.Examples = append(p.Examples, v)
+			}
+		}
+		if strings.Contains(v, ",") {
+			anyList = true
+		}
+		for _, item := range strings.Split(v, ",") {
+			items[strings.TrimSpace(item)] = true
+		}
+
+		allBoolean = allBoolean && isBoolean(v)
+		//ParseFloat reads NaN and Inf, which no numeric encoding can scale
+		f, err := strconv.ParseFloat(v, 32)
+		isNumeric := err == nil && !math.IsNaN(float32(f)) && !math.IsInf(float32(f), 0)
+		if isNumeric {
+			p.Min = math.Min(p.Min, float32(f))
+			p.Max = math.Max(p.Max, float32(f))
+		}
+		_, err = strconv.Atoi(v)
+		allInteger = allInteger && err == nil
+		allNumeric = allNumeric && isNumeric
+		allDates = allDates && !isNumeric && isDate(v)
+	}
+	p.Distinct = len(distinct)
+	if !allNumeric {
+		p.Min, p.Max = 0, 0
+	}
+
+	present := p.Rows - p.Missing
+	switch {
+	case present == 0:
+		p.Kind, p.Role = EmptyKind, IgnoredRole
+		p.Reason = "every value is missing"
+	case allBoolean && p.Distinct <= 2:
+		p.Kind, p.Encoding = BooleanKind, BooleanEncodingMode
+		p.Reason = "values are true/false or 1/0"
+	case allInteger && p.Missing == 0 && p.Max-p.Min+1 <= float32(opts.MaxIntRange):
+		p.Kind, p.Encoding = IntegerKind, IntRangeEncodingMode
+		p.Reason = fmt.Sprintf("integers from %g to %g", p.Min, p.Max)
+	case allNumeric:
+		p.Kind, p.Encoding = NumericKind, NormalizedEncodingMode
+		if allInteger {
+			p.Kind = IntegerKind
+		}
+		p.Reason = fmt.Sprintf("numbers from %g to %g", p.Min, p.Max)
+	case allDates:
+		p.Kind, p.Encoding = DateKind, DateTimeEncodingMode
+		p.Reason = "dates, encoded as hour, weekday and month cycles"
+	case anyList && len(items) <= p.Distinct:
+		p.Kind, p.Encoding = ListKind, StringArrayEncodingMode
+		p.Reason = fmt.Sprintf("comma separated lists of %d items", len(items))
+	case p.Distinct == present && present > opts.MaxOneHotCardinality:
+		p.Kind, p.Encoding, p.Role = IdentifierKind, BinaryEncodingMode, IgnoredRole
+		p.Reason = "every value is unique, looks like an identifier"
+	case p.Distinct <= opts.MaxOneHotCardinality:
+		p.Kind, p.Encoding = CategoricalKind, OneHotEncodingMode
+		p.Reason = fmt.Sprintf("%d categories", p.Distinct)
+	default:
+		p.Kind, p.Encoding = CategoricalKind, BinaryEncodingMode
+		p.Reason = fmt.Sprintf("%d categories is too many for one-hot", p.Distinct)
+	}
+
+	if p.Missing > 0 && p.Role != IgnoredRole {
+		switch p.Encoding {
+		case NormalizedEncodingMode:
+			p.MissingValues = &MissingValues{Policy: MissingMean, Indicator: true}
+		case OneHotEncodingMode, BinaryEncodingMode, StringArrayEncodingMode:
+			p.MissingValues = &MissingValues{Policy: MissingCategory}
+		case DateTimeEncodingMode, BooleanEncodingMode:
+			//dates can't parse a blank and booleans would quietly read it as false
+			p.MissingValues = &MissingValues{Policy: MissingMode, Indicator: true}
+		}
+	}
+	sort.Strings(p.Examples)
+	return p
+}
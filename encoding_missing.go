@@ -0,0 +1,311 @@
+package cogent
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	math "github.com/chewxy/math32"
+
+	"github.com/pkg/errors"
+)
+
+//MissingPolicy decides what a column does with blank cells
+type MissingPolicy int
+
+//MissingPolicies
+const (
+	//MissingPassthrough hands blanks to the encoding as is, each encoding treats them differently
+	MissingPassthrough MissingPolicy = iota
+	//MissingError fails to learn or encode a blank
+	MissingError
+	//MissingConstant replaces blanks with MissingValues.Constant
+	MissingConstant
+	//MissingMean replaces blanks with the mean of the learnt numbers
+	MissingMean
+	//MissingMedian replaces blanks with the median of the learnt numbers
+	MissingMedian
+	//MissingMode replaces blanks with the most common learnt value
+	MissingMode
+	//MissingCategory encodes blanks as their own MissingCategoryName category
+	MissingCategory
+)
+
+var missingPolicyNames = []string{
+	"passthrough",
+	"error",
+	"constant",
+	"mean",
+	"median",
+	"mode",
+	"category",
+}
+
+//MissingCategoryName is the category blanks become with MissingCategory
+const MissingCategoryName = "<missing>"
+
+//String x
+func (m MissingPolicy) String() string {
+	return modeName(missingPolicyNames, int(m))
+}
+
+//MarshalText x
+func (m MissingPolicy) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+//UnmarshalText x
+func (m *MissingPolicy) UnmarshalText(text []byte) error {
+	i, err := parseModeName(missingPolicyNames, "missing policy", string(text))
+	if err != nil {
+		return err
+	}
+	*m = MissingPolicy(i)
+	return nil
+}
+
+//MissingValues configures how a column handles blank cells
+type MissingValues struct {
+	Policy MissingPolicy `json:"policy" yaml:"policy"`
+	//Constant is the replacement used by MissingConstant
+	Constant string `json:"constant,omitempty" yaml:"constant"`
+	//Indicator appends a feature that is 1 when the cell was blank and 0 otherwise
+	Indicator bool `json:"indicator,omitempty" yaml:"indicator"`
+}
+
+func isMissing(value string) bool {
+	return strings.TrimSpace(value) == ""
+}
+
+//missingValueEncoding applies a MissingValues policy in front of any other encoding
+type missingValueEncoding struct {
+	MissingValues
+	encoding EncodingMode
+	inner    valueEncoding
+
+	//learnt is every non blank value seen, only kept until finishLearning
+	learnt []string
+	fill   string
+}
+
+func newMissingValueEncoding(encoding EncodingMode, inner valueEncoding, mv MissingValues) (*missingValueEncoding, error) {
+	if mv.Policy < 0 || int(mv.Policy) >= len(missingPolicyNames) {
+		return nil, errors.Errorf("invalid missing policy '%s'", mv.Policy)
+	}
+	if mv.Policy == MissingConstant && isMissing(mv.Constant) {
+		return nil, errors.New("missing policy constant needs a non blank constant")
+	}
+
+	return &missingValueEncoding{
+		MissingValues: mv,
+		encoding:      encoding,
+		inner:         inner,
+	}, nil
+}
+
+func (m *missingValueEncoding) Learn(values ...string) error {
+	for _, v := range values {
+		if isMissing(v) {
+			switch m.Policy {
+			case MissingError:
+				return errors.New("missing value")
+			case MissingPassthrough:
+				if err := m.inner.Learn(v); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if err := m.inner.Learn(v); err != nil {
+			return err
+		}
+		switch m.Policy {
+		case MissingMean, MissingMedian, MissingMode:
+			m.learnt = append(m.learnt, strings.TrimSpace(v))
+		}
+	}
+	return nil
+}
+
+func (m *missingValueEncoding) finishLearning() error {
+	switch m.Policy {
+	case MissingConstant:
+		m.fill = m.Constant
+	case MissingCategory:
+		m.fill = MissingCategoryName
+	case MissingMean, MissingMedian:
+		fill, err := m.numericFill()
+		if err != nil {
+			return err
+		}
+		m.fill = fill
+	case MissingMode:
+		fill, err := m.modeFill()
+		if err != nil {
+			return err
+		}
+		m.fill = fill
+	}
+	m.learnt = nil
+
+	//the replacement has to be a category the encoding knows
+	switch m.Policy {
+	case MissingConstant, MissingCategory:
+		if err := m.inner.Learn(m.fill); err != nil {
+			return errors.Wrapf(err, "can't learn missing value replacement '%s'", m.fill)
+		}
+	}
+
+	if f, ok := m.inner.(learningFinisher); ok {
+		return f.finishLearning()
+	}
+	return nil
+}
+
+func (m *missingValueEncoding) numericFill() (string, error) {
+	if len(m.learnt) == 0 {
+		return "", errors.Errorf("can't impute the %s of a column without values", m.Policy)
+	}
+
+	numbers := make([]float32, len(m.learnt))
+	for i, v := range m.learnt {
+		f, err := strconv.ParseFloat(v, 32)
+		if err != nil {
+			return "", errors.Wrapf(err, "can't impute the %s of non numeric '%s'", m.Policy, v)
+		}
+		numbers[i] = float32(f)
+	}
+
+	var fill float32
+	if m.Policy == MissingMean {
+		for _, x := range numbers {
+			fill += x
+		}
+		fill /= float32(len(numbers))
+	} else {
+		sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+		middle := len(numbers) / 2
+		fill = numbers[middle]
+		if len(numbers)%2 == 0 {
+			fill = (numbers[middle-1] + numbers[middle]) / 2
+		}
+	}
+
+	//integer ranges only know whole numbers
+	if m.encoding == IntRangeEncodingMode {
+		return strconv.Itoa(int(math.Round(fill))), nil
+	}
+	return strconv.FormatFloat(float64(fill), 'g', -1, 32), nil
+}
+
+func (m *missingValueEncoding) modeFill() (string, error) {
+	if len(m.learnt) == 0 {
+		return "", errors.New("can't impute the mode of a column without values")
+	}
+
+	counts := map[string]int{}
+	for _, v := range m.learnt {
+		counts[v]++
+	}
+	mode := ""
+	for v, c := range counts {
+		if c > counts[mode] || (c == counts[mode] && v < mode) {
+			mode = v
+		}
+	}
+	return mode, nil
+}
+
+func (m *missingValueEncoding) Encode(value string) ([]float32, error) {
+	missing := isMissing(value)
+	if missing {
+		switch m.Policy {
+		case MissingError:
+			return nil, errors.New("missing value")
+		case MissingPassthrough:
+		default:
+			value = m.fill
+		}
+	}
+
+	encoded, err := m.inner.Encode(value)
+	if err != nil || !m.Indicator {
+		return encoded, err
+	}
+
+	var indicator float32
+	if missing {
+		indicator = 1
+	}
+	return append(encoded, indicator), nil
+}
+
+func (m *missingValueEncoding) Width() int {
+	if m.Indicator {
+		return m.inner.Width() + 1
+	}
+	return m.inner.Width()
+}
+
+//Decode returns a blank when the indicator, or the missing category, wins
+func (m *missingValueEncoding) Decode(encoded []float32) (string, error) {
+	if err := checkDecodeWidth(m, encoded); err != nil {
+		return "", err
+	}
+
+	if m.Indicator {
+		last := len(encoded) - 1
+		if encoded[last] >= 0.5 {
+			return "", nil
+		}
+		encoded = encoded[:last]
+	}
+
+	decoded, err := m.inner.Decode(encoded)
+	if err != nil {
+		return "", err
+	}
+	if m.Policy == MissingCategory && decoded == MissingCategoryName {
+		return "", nil
+	}
+	return decoded, nil
+}
+
+func (m *missingValueEncoding) FeatureNames(column string) []string {
+	names := m.inner.FeatureNames(column)
+	if m.Indicator {
+		names = append(names, column+"_missing")
+	}
+	return names
+}
+
+type missingValueEncodingJSON struct {
+	Fill  string          `json:"fill,omitempty"`
+	State json.RawMessage `json:"state"`
+}
+
+func (m *missingValueEncoding) MarshalJSON() ([]byte, error) {
+	state, err := json.Marshal(m.inner)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(missingValueEncodingJSON{m.fill, state})
+}
+
+func (m *missingValueEncoding) UnmarshalJSON(buf []byte) error {
+	mj := missingValueEncodingJSON{}
+	if err := json.Unmarshal(buf, &mj); err != nil {
+		return err
+	}
+	m.fill = mj.Fill
+	return json.Unmarshal(mj.State, m.inner)
+}
+
+func (m *missingValueEncoding) restoreState() error {
+	if r, ok := m.inner.(stateRestorer); ok {
+		return r.restoreState()
+	}
+	return nil
+}
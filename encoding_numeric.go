@@ -1,8 +1,8 @@
 package cogent
 
 import (
+	"encoding/json"
 	"fmt"
-	"runtime"
 	"strconv"
 	strings "strings"
 
@@ -12,44 +12,53 @@ import (
 )
 
 type normalizedEncoding struct {
-	values            []float32
+	//count, runningMean and m2 are Welford's running statistics, linear in the rows and without
+	//the cancellation sum of squares minus squared sum suffers on large values like timestamps
+	count           int
+	runningMean, m2 float64
+	fitted          bool
+
 	mean              float32
 	standardDeviation float32
 }
 
 func (n *normalizedEncoding) Learn(valueStrings ...string) error {
-	var x float32
 	for _, v := range valueStrings {
-
+		var x float64
 		if trimmed := strings.TrimSpace(v); len(trimmed) > 0 {
 			f, err := strconv.ParseFloat(trimmed, 32)
 			if err != nil {
 				return errors.Wrap(err, "can't convert to float")
 			}
-			x = float32(f)
+			x = f
 		}
 
-		n.values = append(n.values, x)
+		n.count++
+		delta := x - n.runningMean
+		n.runningMean += delta / float64(n.count)
+		n.m2 += delta * (x - n.runningMean)
 	}
+	n.fitted = false
+	return nil
+}
 
-	floatCount := float32(len(n.values))
-	n.mean = 0
-	for _, v := range n.values {
-		n.mean += v
+func (n *normalizedEncoding) finishLearning() error {
+	n.mean, n.standardDeviation = 0, 0
+	if n.count > 0 {
+		n.mean = float32(n.runningMean)
+		n.standardDeviation = math.Sqrt(float32(n.m2 / float64(n.count)))
 	}
-	n.mean /= floatCount
-	n.standardDeviation = 0
-	for _, x := range n.values {
-		diff := x - n.mean
-		n.standardDeviation += diff * diff
-	}
-	n.standardDeviation /= floatCount
-	n.standardDeviation = math.Sqrt(n.standardDeviation)
-
+	n.fitted = true
 	return nil
 }
 
 func (n *normalizedEncoding) Encode(valueString string) ([]float32, error) {
+	if !n.fitted {
+		if err := n.finishLearning(); err != nil {
+			return nil, err
+		}
+	}
+
 	var value float32
 
 	if trimmed := strings.TrimSpace(valueString); len(trimmed) > 0 {
@@ -66,6 +75,52 @@ func (n *normalizedEncoding) Encode(valueString string) ([]float32, error) {
 	return []float32{x}, nil
 }
 
+func (n *normalizedEncoding) Width() int {
+	return 1
+}
+
+func (n *normalizedEncoding) FeatureNames(column string) []string {
+	return []string{column + "_z"}
+}
+
+//Decode undoes the standardisation
+func (n *normalizedEncoding) Decode(encoded []float32) (string, error) {
+	if err := checkDecodeWidth(n, encoded); err != nil {
+		return "", err
+	}
+	if !n.fitted {
+		if err := n.finishLearning(); err != nil {
+			return "", err
+		}
+	}
+
+	x := encoded[0]
+	if n.standardDeviation != 0 {
+		x *= n.standardDeviation
+	}
+	x += n.mean
+	return strconv.FormatFloat(float64(x), 'g', -1, 32), nil
+}
+
+type normalizedEncodingJSON struct {
+	Mean              float32 `json:"mean"`
+	StandardDeviation float32 `json:"standardDeviation"`
+}
+
+func (n *normalizedEncoding) MarshalJSON() ([]byte, error) {
+	return json.Marshal(normalizedEncodingJSON{n.mean, n.standardDeviation})
+}
+
+func (n *normalizedEncoding) UnmarshalJSON(buf []byte) error {
+	nj := normalizedEncodingJSON{}
+	if err := json.Unmarshal(buf, &nj); err != nil {
+		return err
+	}
+	n.mean, n.standardDeviation = nj.Mean, nj.StandardDeviation
+	n.fitted = true
+	return nil
+}
+
 type intRangeEncoding struct {
 	min, max float32
 	ohe      *oneHotEncoding
@@ -92,14 +147,25 @@ func (ire *intRangeEncoding) Learn(valueStrings ...string) error {
 	return nil
 }
 
+func (ire *intRangeEncoding) finishLearning() error {
+	ire.ohe = &oneHotEncoding{}
+	for i := ire.min; i <= ire.max; i++ {
+		err := ire.ohe.Learn(fmt.Sprint(i))
+		if err != nil {
+			return errors.Wrapf(err, "can't parse %f", i)
+		}
+	}
+	return nil
+}
+
+func (ire *intRangeEncoding) restoreState() error {
+	return ire.finishLearning()
+}
+
 func (ire *intRangeEncoding) Encode(valueString string) ([]float32, error) {
 	if ire.ohe == nil {
-		ire.ohe = &oneHotEncoding{}
-		for i := ire.min; i <= ire.max; i++ {
-			err := ire.ohe.Learn(fmt.Sprint(i))
-			if err != nil {
-				return nil, errors.Wrapf(err, "can't parse %d", i)
-			}
+		if err := ire.finishLearning(); err != nil {
+			return nil, err
 		}
 	}
 
@@ -109,10 +175,55 @@ func (ire *intRangeEncoding) Encode(valueString string) ([]float32, error) {
 	}
 
 	out, err := ire.ohe.Encode(valueString)
+	if err != nil {
+		return nil, err
+	}
+	//one-hot marks unknown categories with -1s
 	for _, o := range out {
 		if o == -1 {
-			runtime.Breakpoint()
+			return nil, errors.Errorf("'%s' is outside the learnt range %g to %g", valueString, ire.min, ire.max)
+		}
+	}
+	return out, nil
+}
+
+func (ire *intRangeEncoding) Width() int {
+	if ire.ohe == nil {
+		if err := ire.finishLearning(); err != nil {
+			return 0
 		}
 	}
-	return out, err
+	return ire.ohe.Width()
+}
+
+func (ire *intRangeEncoding) FeatureNames(column string) []string {
+	if ire.Width() == 0 {
+		return nil
+	}
+	return ire.ohe.FeatureNames(column)
+}
+
+func (ire *intRangeEncoding) Decode(encoded []float32) (string, error) {
+	if err := checkDecodeWidth(ire, encoded); err != nil {
+		return "", err
+	}
+	return ire.ohe.Decode(encoded)
+}
+
+type intRangeEncodingJSON struct {
+	Min float32 `json:"min"`
+	Max float32 `json:"max"`
+}
+
+func (ire *intRangeEncoding) MarshalJSON() ([]byte, error) {
+	return json.Marshal(intRangeEncodingJSON{ire.min, ire.max})
+}
+
+func (ire *intRangeEncoding) UnmarshalJSON(buf []byte) error {
+	ij := intRangeEncodingJSON{}
+	if err := json.Unmarshal(buf, &ij); err != nil {
+		return err
+	}
+	ire.min, ire.max = ij.Min, ij.Max
+	return nil
 }
@@ -0,0 +1,530 @@
+package cogent
+
+import (
+	"encoding/json"
+	"fmt"
+	//the scalers fit float64 columns, which math32 can't take without losing precision
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+//DefaultBinCount is how many buckets the binning encodings split a column into when
+//BinningOptions doesn't say
+const DefaultBinCount = 10
+
+//BinningOptions configures EqualWidthBinningEncodingMode and EqualFrequencyBinningEncodingMode
+type BinningOptions struct {
+	//Count is the most buckets a column is split into, repeated values can leave fewer
+	Count int `json:"count,omitempty" yaml:"count,omitempty"`
+}
+
+//quantileCount is the most reference points the quantile encodings keep
+const quantileCount = 1000
+
+//parseNumber reads a cell the way normalizedEncoding does, blanks are 0
+func parseNumber(value string) (float64, error) {
+	trimmed := strings.TrimSpace(value)
+	if trimmed == "" {
+		return 0, nil
+	}
+	f, err := strconv.ParseFloat(trimmed, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "can't convert to float '%s'", value)
+	}
+	return f, nil
+}
+
+func formatNumber(x float64) string {
+	return strconv.FormatFloat(x, 'g', -1, 32)
+}
+
+//numericScaler maps a number to a single feature and back
+type numericScaler interface {
+	fit(sorted []float64) error
+	transform(x float64) float64
+	inverse(y float64) float64
+}
+
+//scaledEncoding learns every number in a column and scales it with a numericScaler
+type scaledEncoding struct {
+	scaler numericScaler
+	suffix string
+	values []float64
+	fitted bool
+}
+
+func (s *scaledEncoding) Learn(valueStrings ...string) error {
+	for _, v := range valueStrings {
+		x, err := parseNumber(v)
+		if err != nil {
+			return err
+		}
+		s.values = append(s.values, x)
+	}
+	s.fitted = false
+	return nil
+}
+
+func (s *scaledEncoding) finishLearning() error {
+	if len(s.values) == 0 {
+		return errors.New("no values, did you Learn examples first?")
+	}
+	sort.Float64s(s.values)
+	if err := s.scaler.fit(s.values); err != nil {
+		return err
+	}
+	s.values = nil
+	s.fitted = true
+	return nil
+}
+
+func (s *scaledEncoding) Encode(valueString string) ([]float32, error) {
+	if !s.fitted {
+		if err := s.finishLearning(); err != nil {
+			return nil, err
+		}
+	}
+
+	x, err := parseNumber(valueString)
+	if err != nil {
+		return nil, err
+	}
+	return []float32{float32(s.scaler.transform(x))}, nil
+}
+
+func (s *scaledEncoding) Width() int {
+	return 1
+}
+
+func (s *scaledEncoding) Decode(encoded []float32) (string, error) {
+	if err := checkDecodeWidth(s, encoded); err != nil {
+		return "", err
+	}
+
+	x := s.scaler.inverse(float64(encoded[0]))
+	if math.IsNaN(x) || math.IsInf(x, 0) {
+		return "", errors.Errorf("%f is outside what the scaling can undo", encoded[0])
+	}
+	return formatNumber(x), nil
+}
+
+func (s *scaledEncoding) FeatureNames(column string) []string {
+	return []string{column + "_" + s.suffix}
+}
+
+func (s *scaledEncoding) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.scaler)
+}
+
+func (s *scaledEncoding) UnmarshalJSON(buf []byte) error {
+	if err := json.Unmarshal(buf, s.scaler); err != nil {
+		return err
+	}
+	s.fitted = true
+	return nil
+}
+
+//minMaxScaler maps the learnt range onto [Low, High]
+type minMaxScaler struct {
+	Min  float64 `json:"min"`
+	Max  float64 `json:"max"`
+	Low  float64 `json:"low"`
+	High float64 `json:"high"`
+}
+
+func (m *minMaxScaler) fit(sorted []float64) error {
+	m.Min, m.Max = sorted[0], sorted[len(sorted)-1]
+	return nil
+}
+
+func (m *minMaxScaler) transform(x float64) float64 {
+	if m.Max == m.Min {
+		return m.Low
+	}
+	return m.Low + (x-m.Min)/(m.Max-m.Min)*(m.High-m.Low)
+}
+
+func (m *minMaxScaler) inverse(y float64) float64 {
+	return m.Min + (y-m.Low)/(m.High-m.Low)*(m.Max-m.Min)
+}
+
+//robustScaler centres on the median and scales by the interquartile range, so outliers
+//don't squash everything else
+type robustScaler struct {
+	Median float64 `json:"median"`
+	IQR    float64 `json:"iqr"`
+}
+
+func (r *robustScaler) fit(sorted []float64) error {
+	r.Median = percentile(sorted, 0.5)
+	r.IQR = percentile(sorted, 0.75) - percentile(sorted, 0.25)
+	if r.IQR == 0 {
+		r.IQR = 1
+	}
+	return nil
+}
+
+func (r *robustScaler) transform(x float64) float64 {
+	return (x - r.Median) / r.IQR
+}
+
+func (r *robustScaler) inverse(y float64) float64 {
+	return y*r.IQR + r.Median
+}
+
+//logScaler is log1p of the magnitude keeping the sign, so negative numbers are allowed
+type logScaler struct{}
+
+func (logScaler) fit(sorted []float64) error {
+	return nil
+}
+
+func (logScaler) transform(x float64) float64 {
+	return math.Copysign(math.Log1p(math.Abs(x)), x)
+}
+
+func (logScaler) inverse(y float64) float64 {
+	return math.Copysign(math.Expm1(math.Abs(y)), y)
+}
+
+//powerScaler finds the Box-Cox or Yeo-Johnson lambda that makes the column most normal by
+//maximum likelihood, then standardises the result
+type powerScaler struct {
+	YeoJohnson bool    `json:"yeoJohnson"`
+	Lambda     float64 `json:"lambda"`
+	Mean       float64 `json:"mean"`
+	Std        float64 `json:"std"`
+}
+
+func (p *powerScaler) power(x, lambda float64) float64 {
+	if !p.YeoJohnson {
+		if lambda == 0 {
+			return math.Log(x)
+		}
+		return (math.Pow(x, lambda) - 1) / lambda
+	}
+
+	if x >= 0 {
+		if lambda == 0 {
+			return math.Log1p(x)
+		}
+		return (math.Pow(x+1, lambda) - 1) / lambda
+	}
+	if lambda == 2 {
+		return -math.Log1p(-x)
+	}
+	return -(math.Pow(1-x, 2-lambda) - 1) / (2 - lambda)
+}
+
+func (p *powerScaler) inversePower(y, lambda float64) float64 {
+	if !p.YeoJohnson {
+		if lambda == 0 {
+			return math.Exp(y)
+		}
+		return math.Pow(lambda*y+1, 1/lambda)
+	}
+
+	if y >= 0 {
+		if lambda == 0 {
+			return math.Expm1(y)
+		}
+		return math.Pow(lambda*y+1, 1/lambda) - 1
+	}
+	if lambda == 2 {
+		return -math.Expm1(-y)
+	}
+	return 1 - math.Pow(1-(2-lambda)*y, 1/(2-lambda))
+}
+
+//logLikelihood of the transformed values being normal, up to a constant
+func (p *powerScaler) logLikelihood(sorted []float64, lambda float64) float64 {
+	n := float64(len(sorted))
+	mean, variance := 0.0, 0.0
+	jacobian := 0.0
+	for _, x := range sorted {
+		mean += p.power(x, lambda)
+		if p.YeoJohnson {
+			jacobian += math.Copysign(math.Log1p(math.Abs(x)), x)
+		} else {
+			jacobian += math.Log(x)
+		}
+	}
+	mean /= n
+	for _, x := range sorted {
+		d := p.power(x, lambda) - mean
+		variance += d * d
+	}
+	variance /= n
+	if variance == 0 {
+		return math.Inf(-1)
+	}
+	return -n/2*math.Log(variance) + (lambda-1)*jacobian
+}
+
+func (p *powerScaler) fit(sorted []float64) error {
+	if !p.YeoJohnson && sorted[0] <= 0 {
+		return errors.Errorf("Box-Cox needs positive values, learnt %f", sorted[0])
+	}
+
+	//golden section search for the most likely lambda
+	lo, hi := -5.0, 5.0
+	ratio := (math.Sqrt(5) - 1) / 2
+	a, b := hi-ratio*(hi-lo), lo+ratio*(hi-lo)
+	fa, fb := p.logLikelihood(sorted, a), p.logLikelihood(sorted, b)
+	for hi-lo > 1e-6 {
+		if fa < fb {
+			lo, a, fa = a, b, fb
+			b = lo + ratio*(hi-lo)
+			fb = p.logLikelihood(sorted, b)
+		} else {
+			hi, b, fb = b, a, fa
+			a = hi - ratio*(hi-lo)
+			fa = p.logLikelihood(sorted, a)
+		}
+	}
+	p.Lambda = (lo + hi) / 2
+
+	p.Mean, p.Std = 0, 0
+	for _, x := range sorted {
+		p.Mean += p.power(x, p.Lambda)
+	}
+	p.Mean /= float64(len(sorted))
+	for _, x := range sorted {
+		d := p.power(x, p.Lambda) - p.Mean
+		p.Std += d * d
+	}
+	p.Std = math.Sqrt(p.Std / float64(len(sorted)))
+	if p.Std == 0 {
+		p.Std = 1
+	}
+	return nil
+}
+
+func (p *powerScaler) transform(x float64) float64 {
+	if !p.YeoJohnson && x <= 0 {
+		//Box-Cox isn't defined here, clamp to the smallest positive number
+		x = math.SmallestNonzeroFloat32
+	}
+	return (p.power(x, p.Lambda) - p.Mean) / p.Std
+}
+
+func (p *powerScaler) inverse(y float64) float64 {
+	return p.inversePower(y*p.Std+p.Mean, p.Lambda)
+}
+
+//quantileScaler maps values through the learnt distribution onto a uniform [0,1], or a
+//standard normal when Normal is set
+type quantileScaler struct {
+	Normal bool `json:"normal"`
+	//References are the values at evenly spaced quantiles from 0 to 1
+	References []float64 `json:"references"`
+}
+
+//normalClip keeps the normal quantiles finite
+const normalClip = 1e-7
+
+func (q *quantileScaler) fit(sorted []float64) error {
+	count := quantileCount
+	if len(sorted) < count {
+		count = len(sorted)
+	}
+	q.References = make([]float64, count)
+	for i := range q.References {
+		p := 0.0
+		if count > 1 {
+			p = float64(i) / float64(count-1)
+		}
+		q.References[i] = percentile(sorted, p)
+	}
+	return nil
+}
+
+func (q *quantileScaler) transform(x float64) float64 {
+	refs := q.References
+	last := len(refs) - 1
+	var p float64
+	switch {
+	case last == 0 || x <= refs[0]:
+		p = 0
+	case x >= refs[last]:
+		p = 1
+	default:
+		//ties spread over a range of quantiles, use the middle of it
+		lo := sort.SearchFloat64s(refs, x)
+		hi := sort.Search(len(refs), func(i int) bool { return refs[i] > x })
+		if lo == hi {
+			lo--
+			p = (float64(lo) + (x-refs[lo])/(refs[hi]-refs[lo])) / float64(last)
+		} else {
+			p = float64(lo+hi-1) / 2 / float64(last)
+		}
+	}
+
+	if !q.Normal {
+		return p
+	}
+	p = math.Max(normalClip, math.Min(1-normalClip, p))
+	return math.Sqrt2 * math.Erfinv(2*p-1)
+}
+
+func (q *quantileScaler) inverse(y float64) float64 {
+	p := y
+	if q.Normal {
+		p = (1 + math.Erf(y/math.Sqrt2)) / 2
+	}
+	p = math.Max(0, math.Min(1, p))
+
+	refs := q.References
+	position := p * float64(len(refs)-1)
+	i := int(position)
+	if i >= len(refs)-1 {
+		return refs[len(refs)-1]
+	}
+	return refs[i] + (position-float64(i))*(refs[i+1]-refs[i])
+}
+
+//percentile linearly interpolates between the closest ranks of sorted
+func percentile(sorted []float64, p float64) float64 {
+	position := p * float64(len(sorted)-1)
+	i := int(position)
+	if i >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[i] + (position-float64(i))*(sorted[i+1]-sorted[i])
+}
+
+//binnedEncoding one-hot encodes which bucket a number falls in, the buckets are either
+//equally wide or hold roughly as many learnt values each
+type binnedEncoding struct {
+	equalFrequency bool
+	//count is how many buckets to aim for, 0 uses DefaultBinCount
+	count  int
+	values []float64
+	//edges are the bucket boundaries, bucket i is [edges[i], edges[i+1])
+	edges []float64
+}
+
+func isBinningEncoding(encoding EncodingMode) bool {
+	return encoding == EqualWidthBinningEncodingMode || encoding == EqualFrequencyBinningEncodingMode
+}
+
+func newBinnedEncoding(encoding EncodingMode, options BinningOptions) (*binnedEncoding, error) {
+	if options.Count < 0 {
+		return nil, errors.Errorf("bin count %d can't be negative", options.Count)
+	}
+	return &binnedEncoding{
+		equalFrequency: encoding == EqualFrequencyBinningEncodingMode,
+		count:          options.Count,
+	}, nil
+}
+
+func (b *binnedEncoding) Learn(valueStrings ...string) error {
+	for _, v := range valueStrings {
+		x, err := parseNumber(v)
+		if err != nil {
+			return err
+		}
+		b.values = append(b.values, x)
+	}
+	b.edges = nil
+	return nil
+}
+
+func (b *binnedEncoding) finishLearning() error {
+	if len(b.values) == 0 {
+		return errors.New("no values, did you Learn examples first?")
+	}
+	sort.Float64s(b.values)
+	min, max := b.values[0], b.values[len(b.values)-1]
+
+	count := b.count
+	if count == 0 {
+		count = DefaultBinCount
+	}
+	edges := []float64{min}
+	for i := 1; i < count; i++ {
+		var edge float64
+		if b.equalFrequency {
+			edge = percentile(b.values, float64(i)/float64(count))
+		} else {
+			edge = min + (max-min)*float64(i)/float64(count)
+		}
+		//repeated values would make empty buckets
+		if edge > edges[len(edges)-1] {
+			edges = append(edges, edge)
+		}
+	}
+	if max > edges[len(edges)-1] || len(edges) == 1 {
+		edges = append(edges, max)
+	}
+
+	b.edges = edges
+	b.values = nil
+	return nil
+}
+
+func (b *binnedEncoding) bucket(x float64) int {
+	i := sort.Search(len(b.edges), func(i int) bool { return b.edges[i] > x }) - 1
+	if i < 0 {
+		return 0
+	}
+	if last := len(b.edges) - 2; i > last {
+		return last
+	}
+	return i
+}
+
+func (b *binnedEncoding) Encode(valueString string) ([]float32, error) {
+	if b.edges == nil {
+		if err := b.finishLearning(); err != nil {
+			return nil, err
+		}
+	}
+
+	x, err := parseNumber(valueString)
+	if err != nil {
+		return nil, err
+	}
+	oneHot := make([]float32, b.Width())
+	oneHot[b.bucket(x)] = 1
+	return oneHot, nil
+}
+
+func (b *binnedEncoding) Width() int {
+	if len(b.edges) < 2 {
+		return 0
+	}
+	return len(b.edges) - 1
+}
+
+//Decode returns the middle of the most likely bucket
+func (b *binnedEncoding) Decode(encoded []float32) (string, error) {
+	if err := checkDecodeWidth(b, encoded); err != nil {
+		return "", err
+	}
+	i := argmax(encoded)
+	if i < 0 {
+		return "", errors.New("can't decode, no value is larger than the rest")
+	}
+	return formatNumber((b.edges[i] + b.edges[i+1]) / 2), nil
+}
+
+func (b *binnedEncoding) FeatureNames(column string) []string {
+	names := make([]string, b.Width())
+	for i := range names {
+		names[i] = fmt.Sprintf("%s=[%s,%s)", column, formatNumber(b.edges[i]), formatNumber(b.edges[i+1]))
+	}
+	return names
+}
+
+func (b *binnedEncoding) MarshalJSON() ([]byte, error) {
+	return json.Marshal(b.edges)
+}
+
+func (b *binnedEncoding) UnmarshalJSON(buf []byte) error {
+	return json.Unmarshal(buf, &b.edges)
+}
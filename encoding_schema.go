@@ -0,0 +1,115 @@
+package cogent
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+//EncodedColumn describes where a source column ended up in an encoded row
+type EncodedColumn struct {
+	Name     string       `json:"name"`
+	Encoding EncodingMode `json:"encoding"`
+
+	//Start and End are the half open range of feature indices the column occupies
+	Start int `json:"start"`
+	End   int `json:"end"`
+
+	//Features names each float, e.g. color=red or age_z
+	Features []string `json:"features"`
+}
+
+//Width is how many floats the column encodes to
+func (c EncodedColumn) Width() int {
+	return c.End - c.Start
+}
+
+//Values picks the column's floats out of an encoded row
+func (c EncodedColumn) Values(row []float32) []float32 {
+	return row[c.Start:c.End]
+}
+
+//EncodedSchema maps the columns of a table to the features of its encoded rows
+type EncodedSchema struct {
+	Columns []EncodedColumn `json:"columns"`
+	Width   int             `json:"width"`
+}
+
+//FeatureNames lists the name of every float in an encoded row
+func (s EncodedSchema) FeatureNames() []string {
+	names := make([]string, 0, s.Width)
+	for _, c := range s.Columns {
+		names = append(names, c.Features...)
+	}
+	return names
+}
+
+//Column finds a column by name
+func (s EncodedSchema) Column(name string) (EncodedColumn, bool) {
+	for _, c := range s.Columns {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return EncodedColumn{}, false
+}
+
+//FeatureColumn finds which column a feature index belongs to
+func (s EncodedSchema) FeatureColumn(feature int) (EncodedColumn, bool) {
+	for _, c := range s.Columns {
+		if feature >= c.Start && feature < c.End {
+			return c, true
+		}
+	}
+	return EncodedColumn{}, false
+}
+
+//Schema describes the rows Transform produces, names are the source column names and
+//default to the spec names, or column_0, column_1 and so on when nil
+func (te *TableEncoder) Schema(names []string) (EncodedSchema, error) {
+	if !te.fitted {
+		return EncodedSchema{}, errors.New("table encoder isn't fitted")
+	}
+	if names != nil && len(names) != len(te.columns) {
+		msg := "%d column names for %d columns"
+		return EncodedSchema{}, errors.Errorf(msg, len(names), len(te.columns))
+	}
+
+	s := EncodedSchema{
+		Columns: make([]EncodedColumn, len(te.columns)),
+	}
+	for i, ce := range te.columns {
+		name := te.specs[i].Name
+		if names != nil {
+			name = names[i]
+		}
+		if name == "" {
+			name = fmt.Sprintf("column_%d", i)
+		}
+
+		features := ce.FeatureNames(name)
+		if len(features) != ce.Width() {
+			msg := "column %d has %d feature names for %d floats"
+			return EncodedSchema{}, errors.Errorf(msg, i, len(features), ce.Width())
+		}
+
+		s.Columns[i] = EncodedColumn{
+			Name:     name,
+			Encoding: te.encodings[i],
+			Start:    s.Width,
+			End:      s.Width + len(features),
+			Features: features,
+		}
+		s.Width += len(features)
+	}
+	return s, nil
+}
+
+//categoryFeatureNames names one feature per category, as column=category
+func categoryFeatureNames(column string, categories []string) []string {
+	names := make([]string, len(categories))
+	for i, c := range categories {
+		names[i] = column + "=" + c
+	}
+	return names
+}
@@ -1,8 +1,14 @@
 package cogent
 
 import (
+	"encoding/json"
 	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -132,11 +138,13 @@ func Test_Normalization(t *testing.T) {
 	for _, t := range test {
 		ne.Learn(t)
 	}
+	assert.Nil(t, ne.finishLearning())
 	assert.Equal(t, 36333.333333333336, ne.mean)
 	assert.Equal(t, 15891.99658808029, ne.standardDeviation)
 
 	ne = normalizedEncoding{}
 	ne.Learn(test...)
+	assert.Nil(t, ne.finishLearning())
 
 	assert.Equal(t, 36333.333333333336, ne.mean)
 	assert.Equal(t, 15891.99658808029, ne.standardDeviation)
@@ -164,6 +172,32 @@ func Test_Normalization(t *testing.T) {
 	assert.Equal(t, []float64{0}, zero)
 }
 
+func Test_NormalizationLargeValues(t *testing.T) {
+	// unix timestamps, where a sum of squares loses every digit of the spread
+	ne := normalizedEncoding{}
+	values := make([]float64, 1000)
+	for i := range values {
+		f, err := strconv.ParseFloat(fmt.Sprint(1600000000+(i*7919)%360), 32)
+		assert.Nil(t, err)
+		values[i] = f
+		assert.Nil(t, ne.Learn(fmt.Sprint(1600000000+(i*7919)%360)))
+	}
+	assert.Nil(t, ne.finishLearning())
+
+	var mean, variance float64
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	variance /= float64(len(values))
+	assert.InDelta(t, mean, float64(ne.mean), 256)
+	assert.InDelta(t, math.Sqrt(variance), float64(ne.standardDeviation), 0.01)
+	assert.True(t, ne.standardDeviation > 50)
+}
+
 func Test_Combine(t *testing.T) {
 	encodings := []EncodingMode{
 		BooleanEncodingMode,
@@ -232,3 +266,879 @@ func Test_Combine(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, expected, encoded)
 }
+
+func Test_TableEncoder(t *testing.T) {
+	encodings := []EncodingMode{
+		BooleanEncodingMode,
+		OrdinalEncodingMode,
+		NormalizedEncodingMode,
+		OneHotEncodingMode,
+		BinaryEncodingMode,
+		StringArrayEncodingMode,
+		HeatMapEncodingMode,
+		IntRangeEncodingMode,
+	}
+	table := [][]string{
+		{"true", "male", "60000", "suburban", "republican", "a,b", "x,y", "1"},
+		{"false", "female", "24000", "city", "democrat", "b", "y", "3"},
+		{"true", "male", "30000", "rural", "libertarian", "c,a", "z", "2"},
+		{"false", "female", "18000", "city", "other", "a", "x", "1"},
+	}
+
+	te, err := NewTableEncoder(encodings)
+	assert.Nil(t, err)
+	_, err = te.Transform(table)
+	assert.NotNil(t, err, "not fitted yet")
+
+	fitted, err := te.FitTransform(table)
+	assert.Nil(t, err)
+	oneShot, err := TableEncoding(encodings, table)
+	assert.Nil(t, err)
+	assert.Equal(t, oneShot, fitted)
+
+	// new rows reuse the learnt mappings and statistics instead of relearning them
+	newRows := [][]string{{"f", "female", "36000", "rural", "democrat", "c", "y,x", "2"}}
+	transformed, err := te.Transform(newRows)
+	assert.Nil(t, err)
+	row, err := te.TransformRow(newRows[0])
+	assert.Nil(t, err)
+	assert.Equal(t, transformed[0], row)
+	assert.Equal(t, len(fitted[0]), len(row))
+
+	saved, err := json.Marshal(te)
+	assert.Nil(t, err)
+	restored := &TableEncoder{}
+	assert.Nil(t, json.Unmarshal(saved, restored))
+	assert.Equal(t, encodings, restored.Encodings())
+	restoredRows, err := restored.Transform(append(table, newRows...))
+	assert.Nil(t, err)
+	assert.Equal(t, append(fitted, transformed...), restoredRows)
+
+	_, err = te.TransformRow([]string{"true"})
+	assert.NotNil(t, err)
+	assert.NotNil(t, te.Fit([][]string{{"true", "male"}}))
+
+	_, err = NewTableEncoder([]EncodingMode{EncodingMode(99)})
+	assert.NotNil(t, err)
+	_, err = json.Marshal(&TableEncoder{encodings: encodings})
+	assert.NotNil(t, err)
+}
+
+func Test_TableEncoderInverseTransform(t *testing.T) {
+	encodings := []EncodingMode{
+		BooleanEncodingMode,
+		OrdinalEncodingMode,
+		NormalizedEncodingMode,
+		OneHotEncodingMode,
+		BinaryEncodingMode,
+		StringArrayEncodingMode,
+		HeatMapEncodingMode,
+		IntRangeEncodingMode,
+	}
+	table := [][]string{
+		{"true", "male", "60000", "suburban", "republican", "a,b", "x,y", "1"},
+		{"false", "female", "24000", "city", "democrat", "b", "y", "3"},
+		{"true", "other", "30000", "rural", "libertarian", "c,a", "z,y,x", "2"},
+		{"false", "female", "18000", "city", "other", "a", "x", "1"},
+	}
+
+	te, err := NewTableEncoder(encodings)
+	assert.Nil(t, err)
+	encoded, err := te.FitTransform(table)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{1, 1, 1, 3, 2, 3, 3, 3}, te.Widths())
+
+	decoded, err := te.InverseTransform(encoded)
+	assert.Nil(t, err)
+	for r, row := range decoded {
+		for c, x := range row {
+			switch c {
+			case 2:
+				expected, _ := strconv.ParseFloat(table[r][c], 32)
+				actual, err := strconv.ParseFloat(x, 32)
+				assert.Nil(t, err)
+				assert.InDelta(t, expected, actual, 0.1)
+			case 5:
+				// string arrays come back in learnt order
+				expected := strings.Split(table[r][c], ",")
+				assert.ElementsMatch(t, expected, strings.Split(x, ","))
+			default:
+				assert.Equal(t, table[r][c], x, "row %d column %d", r, c)
+			}
+		}
+	}
+
+	// noisy network outputs snap to the nearest category
+	noisy := append([]float32(nil), encoded[1]...)
+	for i := range noisy {
+		noisy[i] = noisy[i]*0.9 + 0.03
+	}
+	row, err := te.InverseTransformRow(noisy)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"false", "female", "3"}, []string{row[0], row[1], row[7]})
+	assert.Equal(t, []string{"city", "democrat", "b", "y"}, row[3:7])
+
+	_, err = te.InverseTransformRow(noisy[1:])
+	assert.NotNil(t, err)
+	unfitted, err := NewTableEncoder(encodings)
+	assert.Nil(t, err)
+	_, err = unfitted.InverseTransformRow(noisy)
+	assert.NotNil(t, err)
+}
+
+func Test_EncodedSchema(t *testing.T) {
+	encodings := []EncodingMode{
+		BooleanEncodingMode,
+		OneHotEncodingMode,
+		NormalizedEncodingMode,
+		BinaryEncodingMode,
+		OrdinalEncodingMode,
+	}
+	table := [][]string{
+		{"true", "red", "31", "a", "low"},
+		{"false", "green", "45", "b", "high"},
+		{"true", "blue", "27", "c", "mid"},
+	}
+
+	te, err := NewTableEncoder(encodings)
+	assert.Nil(t, err)
+	_, err = te.Schema(nil)
+	assert.NotNil(t, err, "not fitted yet")
+
+	encoded, err := te.FitTransform(table)
+	assert.Nil(t, err)
+
+	names := []string{"member", "color", "age", "grade", "risk"}
+	s, err := te.Schema(names)
+	assert.Nil(t, err)
+	assert.Equal(t, len(encoded[0]), s.Width)
+	assert.Equal(t, []string{
+		"member",
+		"color=red", "color=green", "color=blue",
+		"age_z",
+		"grade_bit1", "grade_bit0",
+		"risk_ordinal",
+	}, s.FeatureNames())
+
+	color, ok := s.Column("color")
+	assert.True(t, ok)
+	assert.Equal(t, OneHotEncodingMode, color.Encoding)
+	assert.Equal(t, 1, color.Start)
+	assert.Equal(t, 3, color.Width())
+	assert.Equal(t, []float32{0, 1, 0}, color.Values(encoded[1]))
+
+	grade, ok := s.FeatureColumn(6)
+	assert.True(t, ok)
+	assert.Equal(t, "grade", grade.Name)
+	_, ok = s.FeatureColumn(s.Width)
+	assert.False(t, ok)
+	_, ok = s.Column("missing")
+	assert.False(t, ok)
+
+	defaults, err := te.Schema(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "column_2", defaults.Columns[2].Name)
+	_, err = te.Schema(names[1:])
+	assert.NotNil(t, err)
+}
+
+func Test_InferEncodings(t *testing.T) {
+	names := []string{"age", "rating", "member", "tags", "joined", "city", "id", "notes"}
+	table := [][]string{}
+	cities := []string{"Paris", "Rome", "Oslo"}
+	tags := []string{"a,b", "b", "c,a", ""}
+	for i := 0; i < 20; i++ {
+		table = append(table, []string{
+			fmt.Sprint(20.5 + float32(i)),
+			fmt.Sprint(1 + i%5),
+			fmt.Sprint(i%2 == 0),
+			tags[i%len(tags)],
+			fmt.Sprintf("2020-01-%02d", 1+i),
+			cities[i%len(cities)],
+			fmt.Sprintf("user-%d", i),
+			"",
+		})
+	}
+
+	specs, report, err := InferEncodings(table, names, InferenceOptions{})
+	assert.Nil(t, err)
+	expected := []ColumnSpec{
+		{Name: "age", Encoding: NormalizedEncodingMode, Role: InputRole},
+		{Name: "rating", Encoding: IntRangeEncodingMode, Role: InputRole},
+		{Name: "member", Encoding: BooleanEncodingMode, Role: InputRole},
+		{Name: "tags", Encoding: StringArrayEncodingMode, Role: InputRole, Missing: &MissingValues{Policy: MissingCategory}},
+		{Name: "joined", Encoding: DateTimeEncodingMode, Role: InputRole},
+		{Name: "city", Encoding: OneHotEncodingMode, Role: InputRole},
+		{Name: "id", Encoding: BinaryEncodingMode, Role: IgnoredRole},
+		{Name: "notes", Role: IgnoredRole},
+	}
+	assert.Equal(t, expected, specs)
+
+	kinds := []ColumnKind{}
+	for _, c := range report.Columns {
+		kinds = append(kinds, c.Kind)
+	}
+	assert.Equal(t, []ColumnKind{NumericKind, IntegerKind, BooleanKind, ListKind, DateKind, CategoricalKind, IdentifierKind, EmptyKind}, kinds)
+	assert.Equal(t, 5, report.Columns[3].Missing)
+	assert.Equal(t, 3, report.Columns[5].Distinct)
+	assert.Equal(t, float32(5), report.Columns[1].Max)
+	assert.Contains(t, report.String(), "comma separated lists of 3 items")
+
+	// the proposed spec encodes the table
+	usedSpecs := []ColumnSpec{}
+	used := make([][]string, len(table))
+	for c, s := range specs {
+		if s.Role == IgnoredRole {
+			continue
+		}
+		usedSpecs = append(usedSpecs, s)
+		for r, row := range table {
+			used[r] = append(used[r], row[c])
+		}
+	}
+	te, err := NewTableEncoderFromSpecs(usedSpecs)
+	assert.Nil(t, err)
+	_, err = te.FitTransform(used)
+	assert.Nil(t, err)
+
+	// a lower cardinality limit switches to binary
+	specs, _, err = InferEncodings(table, names, InferenceOptions{MaxOneHotCardinality: 2})
+	assert.Nil(t, err)
+	assert.Equal(t, BinaryEncodingMode, specs[5].Encoding)
+
+	// NaN and Inf parse as floats but aren't numbers any encoding can scale
+	notFinite := [][]string{{"1.5", "1"}, {"NaN", "2"}, {"2.5", "Inf"}, {"3.5", "-Inf"}}
+	specs, report, err = InferEncodings(notFinite, []string{"x", "y"}, InferenceOptions{})
+	assert.Nil(t, err)
+	for i, s := range specs {
+		assert.NotEqual(t, NormalizedEncodingMode, s.Encoding, s.Name)
+		assert.Equal(t, CategoricalKind, report.Columns[i].Kind, s.Name)
+	}
+	assert.NotContains(t, report.String(), "NaN to")
+	te, err = NewTableEncoderFromSpecs(specs)
+	assert.Nil(t, err)
+	_, err = te.FitTransform(notFinite)
+	assert.Nil(t, err)
+
+	// every column with blanks gets a policy its encoding can fit and transform with
+	blanks := [][]string{}
+	for i := 0; i < 20; i++ {
+		row := []string{
+			fmt.Sprint(20.5 + float32(i)),
+			fmt.Sprint(i%2 == 0),
+			fmt.Sprintf("2020-01-%02d", 1+i),
+			cities[i%len(cities)],
+			tags[i%len(tags)],
+		}
+		if i%5 == 0 {
+			row = []string{"", "", "", "", ""}
+		}
+		blanks = append(blanks, row)
+	}
+	blankNames := []string{"age", "member", "joined", "city", "tags"}
+	specs, _, err = InferEncodings(blanks, blankNames, InferenceOptions{})
+	assert.Nil(t, err)
+	policies := []MissingValues{
+		{Policy: MissingMean, Indicator: true},
+		{Policy: MissingMode, Indicator: true},
+		{Policy: MissingMode, Indicator: true},
+		{Policy: MissingCategory},
+		{Policy: MissingCategory},
+	}
+	for i, s := range specs {
+		assert.Equal(t, InputRole, s.Role, s.Name)
+		if assert.NotNil(t, s.Missing, s.Name) {
+			assert.Equal(t, policies[i], *s.Missing, s.Name)
+		}
+	}
+	te, err = NewTableEncoderFromSpecs(specs)
+	assert.Nil(t, err)
+	encoded, err := te.FitTransform(blanks)
+	assert.Nil(t, err)
+	assert.Len(t, encoded, len(blanks))
+
+	_, _, err = InferEncodings(table, names[1:], InferenceOptions{})
+	assert.NotNil(t, err)
+	_, _, err = InferEncodings(nil, nil, InferenceOptions{})
+	assert.NotNil(t, err)
+}
+
+func Test_MissingValues(t *testing.T) {
+	table := [][]string{
+		{"0", "red", "1", "a"},
+		{"", "", "", "b"},
+		{"30", "blue", "3", ""},
+		{"60", "red", "3", "b"},
+	}
+
+	tests := []struct {
+		name     string
+		missing  []*MissingValues
+		expected [][]float32
+	}{
+		{
+			"mean, category, mode and constant",
+			[]*MissingValues{
+				{Policy: MissingMean, Indicator: true},
+				{Policy: MissingCategory},
+				{Policy: MissingMode},
+				{Policy: MissingConstant, Constant: "a"},
+			},
+			[][]float32{
+				{-1.2247449, 0, 1, 0, 0, 1, 0, 0, 1, 0},
+				{0, 1, 0, 0, 1, 0, 0, 1, 0, 1},
+				{0, 0, 0, 1, 0, 0, 0, 1, 1, 0},
+				{1.2247449, 0, 1, 0, 0, 0, 0, 1, 0, 1},
+			},
+		},
+		{
+			"median",
+			[]*MissingValues{
+				{Policy: MissingMedian},
+				{Policy: MissingMode, Indicator: true},
+				{Policy: MissingMedian},
+				{Policy: MissingMode},
+			},
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		specs := make([]ColumnSpec, len(tt.missing))
+		for i, mv := range tt.missing {
+			specs[i] = ColumnSpec{Name: fmt.Sprint("c", i), Encoding: OneHotEncodingMode, Missing: mv}
+		}
+		specs[0].Encoding = NormalizedEncodingMode
+		specs[2].Encoding = IntRangeEncodingMode
+
+		te, err := NewTableEncoderFromSpecs(specs)
+		assert.Nil(t, err, tt.name)
+		encoded, err := te.FitTransform(table)
+		if !assert.Nil(t, err, tt.name) {
+			continue
+		}
+		if tt.expected != nil {
+			// the blank isn't learnt so the mean is 30
+			assert.InDeltaSlice(t, flatten(tt.expected), flatten(encoded), 1e-6, tt.name)
+		}
+
+		decoded, err := te.InverseTransform(encoded)
+		assert.Nil(t, err, tt.name)
+		s, err := te.Schema([]string{"c0", "c1", "c2", "c3"})
+		assert.Nil(t, err, tt.name)
+		assert.Equal(t, len(encoded[0]), s.Width, tt.name)
+
+		saved, err := json.Marshal(te)
+		assert.Nil(t, err, tt.name)
+		restored := &TableEncoder{}
+		assert.Nil(t, json.Unmarshal(saved, restored), tt.name)
+		restoredRows, err := restored.Transform(table)
+		assert.Nil(t, err, tt.name)
+		assert.Equal(t, encoded, restoredRows, tt.name)
+
+		if tt.expected != nil {
+			assert.Equal(t, []string{"", "", "3", "b"}, decoded[1], tt.name)
+			assert.Equal(t, []string{"30", "blue", "3", "a"}, decoded[2], tt.name)
+			assert.Equal(t, []string{"c0_z", "c0_missing"}, s.Columns[0].Features, tt.name)
+		} else {
+			assert.Equal(t, []string{"30", "", "3", "b"}, decoded[1], tt.name)
+		}
+	}
+
+	te, err := NewTableEncoderFromSpecs([]ColumnSpec{{Missing: &MissingValues{Policy: MissingError}}})
+	assert.Nil(t, err)
+	assert.NotNil(t, te.Fit([][]string{{"true"}, {""}}))
+	assert.Nil(t, te.Fit([][]string{{"true"}, {"false"}}))
+	_, err = te.TransformRow([]string{" "})
+	assert.NotNil(t, err)
+
+	_, err = NewTableEncoderFromSpecs([]ColumnSpec{{Missing: &MissingValues{Policy: MissingConstant}}})
+	assert.NotNil(t, err, "constant needs a value")
+	te, err = NewTableEncoderFromSpecs([]ColumnSpec{{Encoding: NormalizedEncodingMode, Missing: &MissingValues{Policy: MissingMean}}})
+	assert.Nil(t, err)
+	assert.NotNil(t, te.Fit([][]string{{""}}), "nothing to average")
+}
+
+func flatten(rows [][]float32) []float32 {
+	flat := []float32{}
+	for _, row := range rows {
+		flat = append(flat, row...)
+	}
+	return flat
+}
+
+func Test_NumericScalers(t *testing.T) {
+	values := []string{"1", "2", "2", "3", "4", "8", "16", "50"}
+
+	tests := []struct {
+		mode     EncodingMode
+		expected []float32
+	}{
+		{MinMaxEncodingMode, []float32{0, 0.020408163, 0.020408163, 0.040816326, 0.06122449, 0.14285715, 0.30612245, 1}},
+		{SymmetricMinMaxEncodingMode, []float32{-1, -0.9591837, -0.9591837, -0.9183673, -0.877551, -0.71428573, -0.3877551, 1}},
+		{RobustEncodingMode, []float32{-0.3125, -0.1875, -0.1875, -0.0625, 0.0625, 0.5625, 1.5625, 5.8125}},
+		{LogEncodingMode, []float32{0.6931472, 1.0986123, 1.0986123, 1.3862944, 1.609438, 2.1972246, 2.8332133, 3.9318256}},
+		{QuantileUniformEncodingMode, []float32{0, 0.2142857, 0.2142857, 0.42857143, 0.5714286, 0.71428573, 0.85714287, 1}},
+	}
+
+	for _, tt := range tests {
+		ve, err := newValueEncoding(tt.mode)
+		assert.Nil(t, err, tt.mode.String())
+		assert.Nil(t, ve.Learn(values...), tt.mode.String())
+		assert.Nil(t, ve.(learningFinisher).finishLearning(), tt.mode.String())
+
+		actual := []float32{}
+		for _, v := range values {
+			encoded, err := ve.Encode(v)
+			assert.Nil(t, err, tt.mode.String())
+			actual = append(actual, encoded...)
+		}
+		assert.InDeltaSlice(t, tt.expected, actual, 1e-5, tt.mode.String())
+	}
+
+	// every scaler undoes itself and survives being saved
+	modes := []EncodingMode{
+		MinMaxEncodingMode,
+		SymmetricMinMaxEncodingMode,
+		RobustEncodingMode,
+		LogEncodingMode,
+		BoxCoxEncodingMode,
+		YeoJohnsonEncodingMode,
+		QuantileUniformEncodingMode,
+		QuantileNormalEncodingMode,
+	}
+	table := make([][]string, len(values))
+	for r, v := range values {
+		table[r] = make([]string, len(modes))
+		for c := range modes {
+			table[r][c] = v
+		}
+	}
+
+	te, err := NewTableEncoder(modes)
+	assert.Nil(t, err)
+	encoded, err := te.FitTransform(table)
+	assert.Nil(t, err)
+
+	decoded, err := te.InverseTransform(encoded)
+	assert.Nil(t, err)
+	for r, row := range decoded {
+		for c, d := range row {
+			expected, _ := strconv.ParseFloat(values[r], 64)
+			actual, err := strconv.ParseFloat(d, 64)
+			assert.Nil(t, err)
+			assert.InDelta(t, expected, actual, 1e-3*expected, "%s %s", modes[c], values[r])
+		}
+	}
+
+	saved, err := json.Marshal(te)
+	assert.Nil(t, err)
+	restored := &TableEncoder{}
+	assert.Nil(t, json.Unmarshal(saved, restored))
+	restoredRows, err := restored.Transform(table)
+	assert.Nil(t, err)
+	assert.Equal(t, encoded, restoredRows)
+
+	// the power transforms leave the skewed column standardised
+	for _, c := range []int{4, 5} {
+		var mean, variance float32
+		for _, row := range encoded {
+			mean += row[c]
+		}
+		mean /= float32(len(encoded))
+		for _, row := range encoded {
+			variance += (row[c] - mean) * (row[c] - mean)
+		}
+		assert.InDelta(t, 0, mean, 1e-3, modes[c].String())
+		assert.InDelta(t, 1, variance/float32(len(encoded)), 1e-3, modes[c].String())
+	}
+
+	boxCox, err := NewTableEncoder([]EncodingMode{BoxCoxEncodingMode})
+	assert.Nil(t, err)
+	assert.NotNil(t, boxCox.Fit([][]string{{"1"}, {"-1"}}), "Box-Cox needs positive values")
+}
+
+func Test_BinningEncodings(t *testing.T) {
+	values := []string{}
+	for i := 0; i < 20; i++ {
+		values = append(values, fmt.Sprint(i*i))
+	}
+
+	width := &binnedEncoding{}
+	assert.Nil(t, width.Learn(values...))
+	assert.Nil(t, width.finishLearning())
+	assert.Equal(t, DefaultBinCount, width.Width())
+	encoded, err := width.Encode("100")
+	assert.Nil(t, err)
+	// 361 / 10 wide buckets puts 100 in the third
+	assert.Equal(t, []float32{0, 0, 1, 0, 0, 0, 0, 0, 0, 0}, encoded)
+	decoded, err := width.Decode([]float32{0.1, 0, 0.7, 0.2, 0, 0, 0, 0, 0, 0})
+	assert.Nil(t, err)
+	assert.Equal(t, "90.25", decoded)
+
+	frequency := &binnedEncoding{equalFrequency: true}
+	assert.Nil(t, frequency.Learn(values...))
+	assert.Nil(t, frequency.finishLearning())
+	counts := make([]int, frequency.Width())
+	for _, v := range values {
+		encoded, err := frequency.Encode(v)
+		assert.Nil(t, err)
+		counts[argmax(encoded)]++
+	}
+	assert.Equal(t, []int{2, 2, 2, 2, 2, 2, 2, 2, 2, 2}, counts)
+
+	// out of range values go to the end buckets
+	low, err := frequency.Encode("-5")
+	assert.Nil(t, err)
+	assert.Equal(t, float32(1), low[0])
+	high, err := frequency.Encode("1000")
+	assert.Nil(t, err)
+	assert.Equal(t, float32(1), high[DefaultBinCount-1])
+	assert.Equal(t, "c=[0,3.7)", frequency.FeatureNames("c")[0])
+
+	// repeated values don't make empty buckets
+	constant := &binnedEncoding{equalFrequency: true}
+	assert.Nil(t, constant.Learn("3", "3", "3"))
+	assert.Nil(t, constant.finishLearning())
+	assert.Equal(t, 1, constant.Width())
+
+	// the spec can ask for another number of buckets
+	te, err := NewTableEncoderFromSpecs([]ColumnSpec{
+		{Name: "width", Encoding: EqualWidthBinningEncodingMode, Binning: &BinningOptions{Count: 4}},
+		{Name: "frequency", Encoding: EqualFrequencyBinningEncodingMode, Binning: &BinningOptions{Count: 5}},
+		{Name: "default", Encoding: EqualWidthBinningEncodingMode},
+	})
+	assert.Nil(t, err)
+	table := make([][]string, len(values))
+	for i, v := range values {
+		table[i] = []string{v, v, v}
+	}
+	assert.Nil(t, te.Fit(table))
+	assert.Equal(t, []int{4, 5, DefaultBinCount}, te.Widths())
+	_, err = NewTableEncoderFromSpecs([]ColumnSpec{{Encoding: EqualWidthBinningEncodingMode, Binning: &BinningOptions{Count: -1}}})
+	assert.NotNil(t, err)
+}
+
+func Test_DateTimeEncoding(t *testing.T) {
+	table := [][]string{
+		{"2021-01-01 00:00:00"},
+		{"2021-07-03 18:00:00"},
+		{"2021-12-25 06:00:00"},
+	}
+
+	te, err := NewTableEncoderFromSpecs([]ColumnSpec{{
+		Name:     "when",
+		Encoding: DateTimeEncodingMode,
+		DateTime: &DateTimeOptions{
+			Layouts:  []string{"2006-01-02 15:04:05", "02/01/2006"},
+			Elapsed:  true,
+			Weekend:  true,
+			Holidays: []string{"2021-12-25", "2021-01-01"},
+		},
+	}})
+	assert.Nil(t, err)
+	encoded, err := te.FitTransform(table)
+	assert.Nil(t, err)
+
+	s, err := te.Schema(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"when_hour_sin", "when_hour_cos",
+		"when_weekday_sin", "when_weekday_cos",
+		"when_month_sin", "when_month_cos",
+		"when_elapsed", "when_weekend", "when_holiday",
+	}, s.FeatureNames())
+
+	// new year's day 2021 was a friday at midnight in january
+	friday := 2 * math.Pi * 5 / 7
+	assert.InDeltaSlice(t, []float32{
+		0, 1,
+		float32(math.Sin(friday)), float32(math.Cos(friday)),
+		0, 1,
+		0, 0, 1,
+	}, encoded[0], 1e-6)
+	// 18:00 is three quarters round the clock, in july, on a saturday
+	assert.InDeltaSlice(t, []float32{-1, 0}, encoded[1][:2], 1e-6)
+	assert.InDeltaSlice(t, []float32{0, -1}, encoded[1][4:6], 1e-6)
+	assert.Equal(t, []float32{1, 0}, encoded[1][7:])
+	assert.Equal(t, float32(1), encoded[2][6])
+
+	// the second layout parses too
+	row, err := te.TransformRow([]string{"25/12/2021"})
+	assert.Nil(t, err)
+	assert.Equal(t, float32(1), row[8])
+	_, err = te.TransformRow([]string{"yesterday"})
+	assert.NotNil(t, err)
+
+	// float32 elapsed values only resolve a year to a few seconds
+	decoded, err := te.InverseTransform(encoded)
+	assert.Nil(t, err)
+	for r, row := range decoded {
+		expected, _ := time.Parse("2006-01-02 15:04:05", table[r][0])
+		actual, err := time.Parse("2006-01-02 15:04:05", row[0])
+		assert.Nil(t, err)
+		assert.InDelta(t, 0, actual.Sub(expected).Seconds(), 5)
+	}
+
+	saved, err := json.Marshal(te)
+	assert.Nil(t, err)
+	restored := &TableEncoder{}
+	assert.Nil(t, json.Unmarshal(saved, restored))
+	restoredRows, err := restored.Transform(table)
+	assert.Nil(t, err)
+	assert.Equal(t, encoded, restoredRows)
+
+	// defaults have just the cycles and can't be decoded
+	defaults, err := NewTableEncoder([]EncodingMode{DateTimeEncodingMode})
+	assert.Nil(t, err)
+	encoded, err = defaults.FitTransform([][]string{{"2021-01-01"}, {"2021-01-02T10:00:00Z"}})
+	assert.Nil(t, err)
+	assert.Len(t, encoded[0], 6)
+	_, err = defaults.InverseTransform(encoded)
+	assert.NotNil(t, err)
+
+	_, err = NewTableEncoderFromSpecs([]ColumnSpec{{Encoding: DateTimeEncodingMode, DateTime: &DateTimeOptions{Holidays: []string{"xmas"}}}})
+	assert.NotNil(t, err)
+
+	// the default layouts are a copy of the ones InferEncodings looks for
+	layout := DefaultDateTimeOptions.Layouts[0]
+	DefaultDateTimeOptions.Layouts[0] = "nope"
+	assert.Equal(t, layout, dateLayouts[0])
+	DefaultDateTimeOptions.Layouts[0] = layout
+}
+
+func Test_HashingAndFrequencyEncodings(t *testing.T) {
+	te, err := NewTableEncoderFromSpecs([]ColumnSpec{
+		{Name: "zip", Encoding: HashingEncodingMode, Hashing: &HashingOptions{Width: 8}},
+		{Name: "product", Encoding: FrequencyEncodingMode},
+	})
+	assert.Nil(t, err)
+	encoded, err := te.FitTransform([][]string{
+		{"90210", "a"},
+		{"10001", "a"},
+		{"90210", "a"},
+		{"60601", "b"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []int{8, 1}, te.Widths())
+
+	// the same category always hashes to the same single +/-1
+	assert.Equal(t, encoded[0], encoded[2])
+	var nonZero int
+	for _, x := range encoded[0][:8] {
+		if x != 0 {
+			nonZero++
+			assert.Equal(t, float32(1), x*x)
+		}
+	}
+	assert.Equal(t, 1, nonZero)
+	assert.Equal(t, float32(0.75), encoded[0][8])
+	assert.Equal(t, float32(0.25), encoded[3][8])
+
+	// unseen categories still hash and are 0 frequency
+	row, err := te.TransformRow([]string{"02134", "z"})
+	assert.Nil(t, err)
+	assert.Len(t, row, 9)
+	assert.Equal(t, float32(0), row[8])
+
+	s, err := te.Schema(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "zip_hash7", s.FeatureNames()[7])
+	assert.Equal(t, "product_frequency", s.FeatureNames()[8])
+
+	frequency := te.columns[1]
+	decoded, err := frequency.Decode([]float32{0.7})
+	assert.Nil(t, err)
+	assert.Equal(t, "a", decoded)
+	_, err = te.columns[0].Decode(encoded[0][:8])
+	assert.NotNil(t, err)
+
+	saved, err := json.Marshal(te)
+	assert.Nil(t, err)
+	restored := &TableEncoder{}
+	assert.Nil(t, json.Unmarshal(saved, restored))
+	restoredRow, err := restored.TransformRow([]string{"02134", "z"})
+	assert.Nil(t, err)
+	assert.Equal(t, row, restoredRow)
+
+	_, err = NewTableEncoderFromSpecs([]ColumnSpec{{Encoding: HashingEncodingMode, Hashing: &HashingOptions{Width: -1}}})
+	assert.NotNil(t, err)
+}
+
+func Test_TargetEncoding(t *testing.T) {
+	table := [][]string{}
+	targets := [][]float32{}
+	for i := 0; i < 40; i++ {
+		category, target := "low", float32(0)
+		if i%2 == 0 {
+			category, target = "high", 1
+		}
+		table = append(table, []string{category})
+		targets = append(targets, []float32{target})
+	}
+
+	te, err := NewTableEncoderFromSpecs([]ColumnSpec{{
+		Name:     "city",
+		Encoding: TargetEncodingMode,
+		Target:   &TargetEncodingOptions{Smoothing: 2},
+	}})
+	assert.Nil(t, err)
+
+	// targets come from the outputs so Fit can't learn it
+	_, err = te.FitTransform(table)
+	assert.NotNil(t, err)
+
+	encoded, err := te.FitTransformWithTargets(table, targets, 4, rand.New(rand.NewSource(1)))
+	assert.Nil(t, err)
+	folds := te.Folds()
+	assert.Len(t, folds, len(table))
+	foldSizes := make([]int, 4)
+	for _, f := range folds {
+		foldSizes[f]++
+	}
+	assert.Equal(t, []int{10, 10, 10, 10}, foldSizes)
+
+	// the same seed gives the same folds and encodings, another seed different folds
+	again, err := te.FitTransformWithTargets(table, targets, 4, rand.New(rand.NewSource(1)))
+	assert.Nil(t, err)
+	assert.Equal(t, folds, te.Folds())
+	assert.Equal(t, encoded, again)
+	_, err = te.FitTransformWithTargets(table, targets, 4, rand.New(rand.NewSource(2)))
+	assert.Nil(t, err)
+	assert.NotEqual(t, folds, te.Folds())
+
+	// every row is encoded from the other three folds only
+	for r, row := range encoded {
+		var sum, count, totalSum, totalCount float32
+		for o := range table {
+			if folds[o] == folds[r] {
+				continue
+			}
+			totalSum += targets[o][0]
+			totalCount++
+			if table[o][0] == table[r][0] {
+				sum += targets[o][0]
+				count++
+			}
+		}
+		prior := totalSum / totalCount
+		assert.InDelta(t, (sum+2*prior)/(count+2), row[0], 1e-5)
+	}
+
+	// new rows use every target, unknown categories are the overall mean
+	high, err := te.TransformRow([]string{"high"})
+	assert.Nil(t, err)
+	assert.InDelta(t, (20+2*0.5)/(20+2), high[0], 1e-6)
+	unknown, err := te.TransformRow([]string{"elsewhere"})
+	assert.Nil(t, err)
+	assert.InDelta(t, 0.5, unknown[0], 1e-6)
+
+	decoded, err := te.InverseTransform([][]float32{{0.9}, {0.1}})
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{"high"}, {"low"}}, decoded)
+
+	s, err := te.Schema(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"city_target"}, s.FeatureNames())
+
+	saved, err := json.Marshal(te)
+	assert.Nil(t, err)
+	restored := &TableEncoder{}
+	assert.Nil(t, json.Unmarshal(saved, restored))
+	restoredRow, err := restored.TransformRow([]string{"high"})
+	assert.Nil(t, err)
+	assert.Equal(t, high, restoredRow)
+
+	_, err = te.FitTransformWithTargets(table, targets[1:], 4, rand.New(rand.NewSource(1)))
+	assert.NotNil(t, err)
+	_, err = te.FitTransformWithTargets(table, targets, 1, rand.New(rand.NewSource(1)))
+	assert.NotNil(t, err)
+	_, err = te.FitTransformWithTargets(table, targets, 4, nil)
+	assert.NotNil(t, err)
+	_, err = NewTableEncoderFromSpecs([]ColumnSpec{{Encoding: TargetEncodingMode, Missing: &MissingValues{Policy: MissingCategory}}})
+	assert.NotNil(t, err)
+}
+
+func Test_TextEncodings(t *testing.T) {
+	table := [][]string{
+		{"The cat sat on the mat"},
+		{"the dog ate the cat's dinner"},
+		{"A dog, a cat; a MAT!"},
+	}
+	options := &TextOptions{StopWords: EnglishStopWords, MaxVocabulary: 4}
+
+	bow, err := NewTableEncoderFromSpecs([]ColumnSpec{{Name: "review", Encoding: BagOfWordsEncodingMode, Text: options}})
+	assert.Nil(t, err)
+	encoded, err := bow.FitTransform(table)
+	assert.Nil(t, err)
+
+	// most common first, the rest are capped, stop words and case are dropped
+	s, err := bow.Schema(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"review=cat", "review=dog", "review=mat", "review=ate"}, s.FeatureNames())
+	assert.Equal(t, []float32{1, 0, 1, 0}, encoded[0])
+	assert.Equal(t, []float32{1, 1, 0, 1}, encoded[1])
+	row, err := bow.TransformRow([]string{"cat cat unknown"})
+	assert.Nil(t, err)
+	assert.Equal(t, []float32{2, 0, 0, 0}, row)
+	decoded, err := bow.InverseTransformRow([]float32{0.9, 0, 0.6, 0.1})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"cat mat"}, decoded)
+
+	tfidf, err := NewTableEncoderFromSpecs([]ColumnSpec{{Name: "review", Encoding: TFIDFEncodingMode, Text: options}})
+	assert.Nil(t, err)
+	encoded, err = tfidf.FitTransform(table)
+	assert.Nil(t, err)
+	// cat is in every row so weighs less than mat
+	assert.True(t, encoded[0][2] > encoded[0][0])
+	var length float32
+	for _, x := range encoded[0] {
+		length += x * x
+	}
+	assert.InDelta(t, 1, length, 1e-5)
+	decoded, err = tfidf.InverseTransformRow(encoded[0])
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"mat cat"}, decoded)
+
+	saved, err := json.Marshal(tfidf)
+	assert.Nil(t, err)
+	restored := &TableEncoder{}
+	assert.Nil(t, json.Unmarshal(saved, restored))
+	restoredRows, err := restored.Transform(table)
+	assert.Nil(t, err)
+	assert.Equal(t, encoded, restoredRows)
+
+	// custom separators keep punctuation in the tokens
+	separated, err := NewTableEncoderFromSpecs([]ColumnSpec{{Encoding: BagOfWordsEncodingMode, Text: &TextOptions{Separators: " ", CaseSensitive: true}}})
+	assert.Nil(t, err)
+	assert.Nil(t, separated.Fit([][]string{{"Dog, dog"}}))
+	s, err = separated.Schema(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"column_0=Dog,", "column_0=dog"}, s.FeatureNames())
+
+	ngrams, err := NewTableEncoderFromSpecs([]ColumnSpec{{Name: "review", Encoding: CharNGramEncodingMode, Text: &TextOptions{Width: 32, MinNGram: 3, MaxNGram: 3}}})
+	assert.Nil(t, err)
+	encoded, err = ngrams.FitTransform(table)
+	assert.Nil(t, err)
+	assert.Len(t, encoded[0], 32)
+	// misspellings share most of their n-grams
+	similar, err := ngrams.TransformRow([]string{"The cat sat on the matt"})
+	assert.Nil(t, err)
+	different, err := ngrams.TransformRow([]string{"quick brown fox"})
+	assert.Nil(t, err)
+	var simDot, diffDot float32
+	for i, x := range encoded[0] {
+		simDot += x * similar[i]
+		diffDot += x * different[i]
+	}
+	assert.True(t, simDot > 0.8)
+	assert.True(t, simDot > diffDot)
+	_, err = ngrams.InverseTransform(encoded)
+	assert.NotNil(t, err)
+
+	_, err = NewTableEncoderFromSpecs([]ColumnSpec{{Encoding: CharNGramEncodingMode, Text: &TextOptions{MinNGram: 5, MaxNGram: 3}}})
+	assert.NotNil(t, err)
+	empty, err := NewTableEncoder([]EncodingMode{TFIDFEncodingMode})
+	assert.Nil(t, err)
+	assert.NotNil(t, empty.Fit([][]string{{"..."}}))
+}
@@ -0,0 +1,369 @@
+package cogent
+
+//Encodings for free text columns, e.g. descriptions or reviews
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	math "github.com/chewxy/math32"
+
+	"github.com/pkg/errors"
+)
+
+//DefaultMaxVocabulary is how many tokens bag-of-words and TF-IDF keep when TextOptions doesn't say
+const DefaultMaxVocabulary = 1000
+
+//DefaultNGramWidth is how many features character n-grams are hashed into when TextOptions doesn't say
+const DefaultNGramWidth = 64
+
+//TextOptions configures BagOfWordsEncodingMode, TFIDFEncodingMode and CharNGramEncodingMode
+type TextOptions struct {
+	//Separators are the characters text is split into tokens on, blank splits on anything
+	//that isn't a letter or number
+	Separators string `json:"separators,omitempty" yaml:"separators,omitempty"`
+	//CaseSensitive keeps the case of tokens, otherwise they are lowercased
+	CaseSensitive bool `json:"caseSensitive,omitempty" yaml:"caseSensitive,omitempty"`
+	//StopWords are dropped from the tokens, e.g. EnglishStopWords
+	StopWords []string `json:"stopWords,omitempty" yaml:"stopWords,omitempty"`
+	//MaxVocabulary keeps the tokens in the most rows, defaults to DefaultMaxVocabulary
+	MaxVocabulary int `json:"maxVocabulary,omitempty" yaml:"maxVocabulary,omitempty"`
+
+	//MinNGram and MaxNGram are the lengths of character n-grams, default to 2 and 4
+	MinNGram int `json:"minNGram,omitempty" yaml:"minNGram,omitempty"`
+	MaxNGram int `json:"maxNGram,omitempty" yaml:"maxNGram,omitempty"`
+	//Width is how many features character n-grams are hashed into, defaults to DefaultNGramWidth
+	Width int `json:"width,omitempty" yaml:"width,omitempty"`
+}
+
+//EnglishStopWords are common English words that say little about a text on their own
+var EnglishStopWords = []string{
+	"a", "an", "and", "are", "as", "at", "be", "but", "by", "for", "from", "has", "have",
+	"he", "her", "his", "i", "in", "is", "it", "its", "of", "on", "or", "our", "she", "so",
+	"that", "the", "their", "them", "there", "they", "this", "to", "was", "we", "were",
+	"what", "when", "which", "who", "will", "with", "you", "your",
+}
+
+func (o TextOptions) withDefaults() (TextOptions, error) {
+	if o.MaxVocabulary < 0 || o.Width < 0 || o.MinNGram < 0 || o.MaxNGram < 0 {
+		return o, errors.New("text options can't be negative")
+	}
+	if o.MaxVocabulary == 0 {
+		o.MaxVocabulary = DefaultMaxVocabulary
+	}
+	if o.Width == 0 {
+		o.Width = DefaultNGramWidth
+	}
+	if o.MinNGram == 0 {
+		o.MinNGram = 2
+	}
+	if o.MaxNGram == 0 {
+		o.MaxNGram = 4
+	}
+	if o.MinNGram > o.MaxNGram {
+		return o, errors.Errorf("min n-gram %d is longer than max n-gram %d", o.MinNGram, o.MaxNGram)
+	}
+	return o, nil
+}
+
+//tokenizer splits text into tokens the same way when learning and encoding
+type tokenizer struct {
+	separators    string
+	caseSensitive bool
+	stopWords     map[string]bool
+}
+
+func newTokenizer(options TextOptions) tokenizer {
+	tk := tokenizer{
+		separators:    options.Separators,
+		caseSensitive: options.CaseSensitive,
+		stopWords:     map[string]bool{},
+	}
+	for _, w := range options.StopWords {
+		tk.stopWords[tk.fold(strings.TrimSpace(w))] = true
+	}
+	return tk
+}
+
+func (tk tokenizer) fold(token string) string {
+	if tk.caseSensitive {
+		return token
+	}
+	return strings.ToLower(token)
+}
+
+func (tk tokenizer) tokens(text string) []string {
+	split := func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}
+	if tk.separators != "" {
+		split = func(r rune) bool {
+			return strings.ContainsRune(tk.separators, r)
+		}
+	}
+
+	var tokens []string
+	for _, token := range strings.FieldsFunc(text, split) {
+		token = tk.fold(strings.TrimSpace(token))
+		if token == "" || tk.stopWords[token] {
+			continue
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+//normalize scales values to unit length so long and short texts are comparable
+func normalize(values []float32) {
+	var sum float32
+	for _, x := range values {
+		sum += x * x
+	}
+	if sum == 0 {
+		return
+	}
+	length := math.Sqrt(sum)
+	for i := range values {
+		values[i] /= length
+	}
+}
+
+//vocabularyEncoding counts the tokens in the learnt vocabulary, optionally weighting them by
+//inverse document frequency so tokens in every row count for less
+type vocabularyEncoding struct {
+	options   TextOptions
+	tokenizer tokenizer
+	tfidf     bool
+
+	//rows and documentFrequency are only kept until finishLearning
+	rows              int
+	documentFrequency map[string]int
+
+	vocabulary []string
+	index      map[string]int
+	idf        []float32
+}
+
+func (v *vocabularyEncoding) Learn(texts ...string) error {
+	if v.documentFrequency == nil {
+		v.documentFrequency = map[string]int{}
+	}
+	for _, text := range texts {
+		v.rows++
+		seen := map[string]bool{}
+		for _, token := range v.tokenizer.tokens(text) {
+			if !seen[token] {
+				seen[token] = true
+				v.documentFrequency[token]++
+			}
+		}
+	}
+	return nil
+}
+
+func (v *vocabularyEncoding) finishLearning() error {
+	tokens := make([]string, 0, len(v.documentFrequency))
+	for token := range v.documentFrequency {
+		tokens = append(tokens, token)
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		fi, fj := v.documentFrequency[tokens[i]], v.documentFrequency[tokens[j]]
+		if fi != fj {
+			return fi > fj
+		}
+		return tokens[i] < tokens[j]
+	})
+	if len(tokens) > v.options.MaxVocabulary {
+		tokens = tokens[:v.options.MaxVocabulary]
+	}
+	if len(tokens) == 0 {
+		return errors.New("no tokens learnt")
+	}
+
+	v.vocabulary = tokens
+	v.idf = make([]float32, len(tokens))
+	for i, token := range tokens {
+		//smoothed as if one more row had every token
+		df := float32(v.documentFrequency[token])
+		v.idf[i] = math.Log((1+float32(v.rows))/(1+df)) + 1
+	}
+	v.rows, v.documentFrequency = 0, nil
+	return v.restoreState()
+}
+
+func (v *vocabularyEncoding) restoreState() error {
+	v.index = make(map[string]int, len(v.vocabulary))
+	for i, token := range v.vocabulary {
+		v.index[token] = i
+	}
+	return nil
+}
+
+func (v *vocabularyEncoding) Encode(text string) ([]float32, error) {
+	if len(v.vocabulary) == 0 {
+		return nil, errors.New("no vocabulary, did you Learn examples first?")
+	}
+
+	encoded := make([]float32, len(v.vocabulary))
+	for _, token := range v.tokenizer.tokens(text) {
+		if i, ok := v.index[token]; ok {
+			encoded[i]++
+		}
+	}
+	if v.tfidf {
+		for i := range encoded {
+			encoded[i] *= v.idf[i]
+		}
+		normalize(encoded)
+	}
+	return encoded, nil
+}
+
+func (v *vocabularyEncoding) Width() int {
+	return len(v.vocabulary)
+}
+
+func (v *vocabularyEncoding) FeatureNames(column string) []string {
+	return categoryFeatureNames(column, v.vocabulary)
+}
+
+//Decode can only recover which tokens were there, most weighted first, not their order
+func (v *vocabularyEncoding) Decode(encoded []float32) (string, error) {
+	if err := checkDecodeWidth(v, encoded); err != nil {
+		return "", err
+	}
+
+	//bag-of-words are counts, TF-IDF is unit length so up to 100 equally weighted tokens are each over 0.1
+	threshold := float32(0.5)
+	if v.tfidf {
+		threshold = 0.1
+	}
+	var picked []int
+	for i, x := range encoded {
+		if x >= threshold {
+			picked = append(picked, i)
+		}
+	}
+	sort.SliceStable(picked, func(i, j int) bool {
+		return encoded[picked[i]] > encoded[picked[j]]
+	})
+
+	tokens := make([]string, len(picked))
+	for i, p := range picked {
+		tokens[i] = v.vocabulary[p]
+	}
+	return strings.Join(tokens, " "), nil
+}
+
+type vocabularyEncodingJSON struct {
+	Vocabulary []string  `json:"vocabulary"`
+	IDF        []float32 `json:"idf,omitempty"`
+}
+
+func (v *vocabularyEncoding) MarshalJSON() ([]byte, error) {
+	vj := vocabularyEncodingJSON{Vocabulary: v.vocabulary}
+	if v.tfidf {
+		vj.IDF = v.idf
+	}
+	return json.Marshal(vj)
+}
+
+func (v *vocabularyEncoding) UnmarshalJSON(buf []byte) error {
+	vj := vocabularyEncodingJSON{}
+	if err := json.Unmarshal(buf, &vj); err != nil {
+		return err
+	}
+	if v.tfidf && len(vj.IDF) != len(vj.Vocabulary) {
+		return errors.Errorf("%d idf weights for %d tokens", len(vj.IDF), len(vj.Vocabulary))
+	}
+	v.vocabulary, v.idf = vj.Vocabulary, vj.IDF
+	return nil
+}
+
+//charNGramEncoding hashes the character n-grams of each token, padded with a space either
+//side so n-grams at the start and end of words differ from those in the middle. It needs
+//no vocabulary so handles misspellings and unseen words.
+type charNGramEncoding struct {
+	options   TextOptions
+	tokenizer tokenizer
+}
+
+func (c *charNGramEncoding) Learn(texts ...string) error {
+	return nil
+}
+
+func (c *charNGramEncoding) Encode(text string) ([]float32, error) {
+	encoded := make([]float32, c.options.Width)
+	for _, token := range c.tokenizer.tokens(text) {
+		runes := []rune(" " + token + " ")
+		for n := c.options.MinNGram; n <= c.options.MaxNGram; n++ {
+			for start := 0; start+n <= len(runes); start++ {
+				i, sign := hashFeature(string(runes[start:start+n]), c.options.Width)
+				encoded[i] += sign
+			}
+		}
+	}
+	normalize(encoded)
+	return encoded, nil
+}
+
+func (c *charNGramEncoding) Width() int {
+	return c.options.Width
+}
+
+func (c *charNGramEncoding) FeatureNames(column string) []string {
+	names := make([]string, c.Width())
+	for i := range names {
+		names[i] = fmt.Sprintf("%s_ngram%d", column, i)
+	}
+	return names
+}
+
+//Decode can't work as hashing forgets the n-grams
+func (c *charNGramEncoding) Decode(encoded []float32) (string, error) {
+	return "", errors.New("hashed character n-grams can't be decoded")
+}
+
+func (c *charNGramEncoding) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.options)
+}
+
+func (c *charNGramEncoding) UnmarshalJSON(buf []byte) error {
+	options := TextOptions{}
+	if err := json.Unmarshal(buf, &options); err != nil {
+		return err
+	}
+	if options.Width != c.options.Width || options.MinNGram != c.options.MinNGram || options.MaxNGram != c.options.MaxNGram {
+		return errors.New("saved n-gram options don't match the column spec")
+	}
+	return nil
+}
+
+func isTextEncoding(encoding EncodingMode) bool {
+	switch encoding {
+	case BagOfWordsEncodingMode, TFIDFEncodingMode, CharNGramEncodingMode:
+		return true
+	}
+	return false
+}
+
+func newTextEncoding(encoding EncodingMode, options TextOptions) (valueEncoding, error) {
+	options, err := options.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+
+	tk := newTokenizer(options)
+	switch encoding {
+	case BagOfWordsEncodingMode:
+		return &vocabularyEncoding{options: options, tokenizer: tk}, nil
+	case TFIDFEncodingMode:
+		return &vocabularyEncoding{options: options, tokenizer: tk, tfidf: true}, nil
+	case CharNGramEncodingMode:
+		return &charNGramEncoding{options: options, tokenizer: tk}, nil
+	default:
+		return nil, errors.Errorf("'%s' isn't a text encoding", encoding)
+	}
+}
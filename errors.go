@@ -0,0 +1,83 @@
+package cogent
+
+import (
+	"github.com/pkg/errors"
+)
+
+//Errors returned, wrapped with the details, by the public API. Compare them with
+//errors.Cause(err) == ErrInvalidConfig, or errors.Is.
+var (
+	//ErrInvalidConfig is a network, swarm or training configuration that can't be used
+	ErrInvalidConfig = errors.New("invalid configuration")
+	//ErrShapeMismatch is data whose rows or columns don't line up with each other or the network
+	ErrShapeMismatch = errors.New("shape mismatch")
+	//ErrNoBestNetwork is asking a swarm for its best network before it has found one
+	ErrNoBestNetwork = errors.New("no best network found, train first")
+)
+
+func invalidConfig(format string, args ...interface{}) error {
+	return errors.Wrapf(ErrInvalidConfig, format, args...)
+}
+
+func shapeMismatch(format string, args ...interface{}) error {
+	return errors.Wrapf(ErrShapeMismatch, format, args...)
+}
+
+//outputCount is how many floats the last layer outputs
+func (c NeuralNetworkConfiguration) outputCount() int {
+	return c.LayerConfigs[len(c.LayerConfigs)-1].NodeCount
+}
+
+func (c TrainingConfiguration) validate() error {
+	if c.MaxIterations <= 0 {
+		return invalidConfig("max iterations %d", c.MaxIterations)
+	}
+	if c.WeightRange <= 0 {
+		return invalidConfig("weight range %g", c.WeightRange)
+	}
+	if c.ProbablityOfDeath < 0 || c.ProbablityOfDeath > 1 {
+		return invalidConfig("probability of death %g isn't 0-1", c.ProbablityOfDeath)
+	}
+	if c.RidgeRegressionWeight < 0 {
+		return invalidConfig("ridge regression weight %g", c.RidgeRegressionWeight)
+	}
+	if c.Parallelism < 0 {
+		return invalidConfig("parallelism %d", c.Parallelism)
+	}
+	if c.BatchSize < 0 || c.ReevaluationSize < 0 {
+		return invalidConfig("batch size %d, reevaluation size %d", c.BatchSize, c.ReevaluationSize)
+	}
+	if c.ReevaluationSize > 0 && c.ReevaluationSize < c.BatchSize {
+		return invalidConfig("reevaluation size %d is smaller than batch size %d", c.ReevaluationSize, c.BatchSize)
+	}
+	return nil
+}
+
+//validateBuckets checks every bucket has rows with the bias column and the outputs the network expects
+func (c NeuralNetworkConfiguration) validateBuckets(buckets DataBuckets) error {
+	if len(buckets) == 0 {
+		return shapeMismatch("no buckets")
+	}
+
+	inputCount, outputCount := c.InputCount+1, c.outputCount()
+	for i, b := range buckets {
+		if b == nil || b.Inputs == nil || b.Outputs == nil {
+			return shapeMismatch("bucket %d is empty", i)
+		}
+		is, os := b.Inputs.Shape(), b.Outputs.Shape()
+		if len(is) != 2 || len(os) != 2 {
+			return shapeMismatch("bucket %d isn't rows and columns", i)
+		}
+		if is[0] == 0 || is[0] != os[0] {
+			return shapeMismatch("bucket %d has %d input and %d output rows", i, is[0], os[0])
+		}
+		if is[1] != inputCount {
+			msg := "bucket %d has %d input columns, the network expects %d including the bias"
+			return shapeMismatch(msg, i, is[1], inputCount)
+		}
+		if os[1] != outputCount {
+			return shapeMismatch("bucket %d has %d output columns, the network has %d", i, os[1], outputCount)
+		}
+	}
+	return nil
+}
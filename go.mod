@@ -1,3 +1,12 @@
 module github.com/delaneyj/cogent
 
-require github.com/stretchr/testify v1.2.1
+go 1.27.1
+
+require (
+	github.com/chewxy/math32 v1.11.1
+	github.com/pkg/errors v0.9.1
+	github.com/stretchr/testify v1.2.1
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require gopkg.in/check.v1 v0.0.0-20161208181325-20d25e280405 // indirect
@@ -0,0 +1,203 @@
+package cogent
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+//MigrationTopology is which islands an island's migrants travel to
+type MigrationTopology int
+
+//MigrationTopologies
+const (
+	//RingTopology sends each island's migrants to the next island, the last to the first
+	RingTopology MigrationTopology = iota
+	//RandomTopology sends each island's migrants to another island picked every migration
+	RandomTopology
+	//FullyConnectedTopology sends each island's migrants to every other island
+	FullyConnectedTopology
+)
+
+var migrationTopologyNames = []string{
+	"RingTopology",
+	"RandomTopology",
+	"FullyConnectedTopology",
+}
+
+//String x
+func (m MigrationTopology) String() string {
+	return modeName(migrationTopologyNames, int(m))
+}
+
+//MarshalText x
+func (m MigrationTopology) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+//UnmarshalText x
+func (m *MigrationTopology) UnmarshalText(text []byte) error {
+	i, err := parseModeName(migrationTopologyNames, "migration topology", string(text))
+	if err != nil {
+		return err
+	}
+	*m = MigrationTopology(i)
+	return nil
+}
+
+//IslandConfiguration runs every swarm as an island. Islands ignore the global best, so they
+//explore independently, and every MigrationInterval iterations each sends copies of its
+//Migrants best particles along the Topology to replace the receiving islands' worst. Only the
+//swarms in one MultiSwarm migrate, swarms in other processes sharing a Blackboard don't.
+type IslandConfiguration struct {
+	//MigrationInterval is how many iterations islands evolve between migrations, 0 never migrates
+	MigrationInterval int `json:"migrationInterval" yaml:"migrationInterval"`
+	//Migrants is how many of its best particles an island sends each migration
+	Migrants int               `json:"migrants" yaml:"migrants"`
+	Topology MigrationTopology `json:"topology" yaml:"topology"`
+	//Swarms are each island's hyperparameters by swarm index, islands past the end use the TrainingConfiguration's
+	Swarms []IslandHyperparameters `json:"swarms,omitempty" yaml:"swarms,omitempty"`
+}
+
+//IslandHyperparameters override the TrainingConfiguration for one island, zero keeps its value
+type IslandHyperparameters struct {
+	InertialWeight    float32 `json:"inertialWeight,omitempty" yaml:"inertialWeight,omitempty"`
+	CognitiveWeight   float32 `json:"cognitiveWeight,omitempty" yaml:"cognitiveWeight,omitempty"`
+	SocialWeight      float32 `json:"socialWeight,omitempty" yaml:"socialWeight,omitempty"`
+	ProbablityOfDeath float32 `json:"probabilityOfDeath,omitempty" yaml:"probabilityOfDeath,omitempty"`
+}
+
+//islandTrainingInfo is pti with the island's overrides, islands don't follow the global best
+func (ic *IslandConfiguration) islandTrainingInfo(pti particleTrainingInfo, swarmIndex int) particleTrainingInfo {
+	pti.GlobalWeight = 0
+	if swarmIndex >= len(ic.Swarms) {
+		return pti
+	}
+
+	h := ic.Swarms[swarmIndex]
+	override := func(dst *float32, src float32) {
+		if src != 0 {
+			*dst = src
+		}
+	}
+	override(&pti.InertialWeight, h.InertialWeight)
+	override(&pti.CognitiveWeight, h.CognitiveWeight)
+	override(&pti.SocialWeight, h.SocialWeight)
+	override(&pti.DeathRate, h.ProbablityOfDeath)
+	return pti
+}
+
+//shouldMigrate is whether islands migrate after the iteration
+func (ic *IslandConfiguration) shouldMigrate(iteration int) bool {
+	return ic != nil && ic.MigrationInterval > 0 && (iteration+1)%ic.MigrationInterval == 0
+}
+
+//destinations are the indexes of the islands receiving island i's migrants
+func (ic *IslandConfiguration) destinations(r *rand.Rand, i, islandCount int) []int {
+	if islandCount < 2 {
+		return nil
+	}
+
+	switch ic.Topology {
+	case RandomTopology:
+		d := r.Intn(islandCount - 1)
+		if d >= i {
+			d++
+		}
+		return []int{d}
+	case FullyConnectedTopology:
+		ds := make([]int, 0, islandCount-1)
+		for d := 0; d < islandCount; d++ {
+			if d != i {
+				ds = append(ds, d)
+			}
+		}
+		return ds
+	default:
+		return []int{(i + 1) % islandCount}
+	}
+}
+
+//byBestLoss sorts particles from the lowest personal best loss to the highest
+func byBestLoss(particles []*particle) []*particle {
+	sorted := append([]*particle(nil), particles...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].nn.Best.Loss < sorted[j].nn.Best.Loss
+	})
+	return sorted
+}
+
+//migrate copies every island's best particles to its destinations, where they replace the
+//worst. Migrants are all picked before any arrive so the order of the islands doesn't matter.
+//An island keeps at least its own Migrants best particles however many arrive. Returns how
+//many particles were replaced.
+func (ms *MultiSwarm) migrate() (int, error) {
+	ic := ms.islands
+	incoming := make([][]*NeuralNetwork, len(ms.swarms))
+	for i, s := range ms.swarms {
+		sorted := byBestLoss(s.particles)
+		count := ic.Migrants
+		if count > len(sorted) {
+			count = len(sorted)
+		}
+		for _, d := range ic.destinations(ms.r, i, len(ms.swarms)) {
+			for _, p := range sorted[:count] {
+				incoming[d] = append(incoming[d], p.nn.Clone())
+			}
+		}
+	}
+
+	migrated := 0
+	for i, s := range ms.swarms {
+		migrants := incoming[i]
+		sort.SliceStable(migrants, func(a, b int) bool {
+			return migrants[a].Best.Loss < migrants[b].Best.Loss
+		})
+		if max := len(s.particles) - ic.Migrants; len(migrants) > max {
+			migrants = migrants[:max]
+		}
+
+		sorted := byBestLoss(s.particles)
+		for m, migrant := range migrants {
+			p := sorted[len(sorted)-1-m]
+			if err := p.adopt(migrant); err != nil {
+				return migrated, errors.Wrapf(err, "island %d", s.id)
+			}
+			migrated++
+		}
+
+		if len(migrants) > 0 {
+			// a migrant better than anything the island found becomes its best
+			swarmKey := fmt.Sprintf(swarmKeyFormat, s.id)
+			if _, err := ms.blackboard.Offer(swarmKey, migrants[0].Best); err != nil {
+				return migrated, errors.Wrapf(err, "can't offer island %d best", s.id)
+			}
+			ms.logger.Debug("migrants arrived", SwarmKey, s.id, "migrants", len(migrants), LossKey, migrants[0].Best.Loss)
+		}
+	}
+	return migrated, nil
+}
+
+//adopt makes the particle a copy of the migrant, keeping its own random source and starting still
+func (p *particle) adopt(migrant *NeuralNetwork) error {
+	if len(migrant.Layers) != len(p.nn.Layers) || len(migrant.Best.Layers) != len(p.nn.Layers) {
+		return shapeMismatch("migrant has %d layers, particle has %d", len(migrant.Layers), len(p.nn.Layers))
+	}
+
+	best := Position{
+		Loss:   migrant.Best.Loss,
+		Layers: make([]LayerData, len(migrant.Best.Layers)),
+	}
+	for i, l := range migrant.Layers {
+		if err := l.WeightsAndBiases.CopyTo(p.nn.Layers[i].WeightsAndBiases); err != nil {
+			return errors.Wrapf(err, "can't copy layer %d", i)
+		}
+		best.Layers[i] = migrant.Best.Layers[i].Clone()
+		p.layersTrainingInfo[i].Velocities.Zero()
+	}
+	p.nn.Best = best
+	p.nn.CurrentLoss = migrant.CurrentLoss
+	return nil
+}
@@ -0,0 +1,94 @@
+package cogent
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	math "github.com/chewxy/math32"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Islands(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	ring := &IslandConfiguration{Topology: RingTopology}
+	assert.Equal(t, []int{1}, ring.destinations(r, 0, 3))
+	assert.Equal(t, []int{0}, ring.destinations(r, 2, 3))
+	assert.Nil(t, ring.destinations(r, 0, 1))
+	full := &IslandConfiguration{Topology: FullyConnectedTopology}
+	assert.Equal(t, []int{0, 2}, full.destinations(r, 1, 3))
+	random := &IslandConfiguration{Topology: RandomTopology}
+	for i := 0; i < 20; i++ {
+		ds := random.destinations(r, 1, 3)
+		assert.Len(t, ds, 1)
+		assert.NotEqual(t, 1, ds[0])
+	}
+
+	topology := RandomTopology
+	assert.Nil(t, topology.UnmarshalText([]byte("fullyconnectedtopology")))
+	assert.Equal(t, FullyConnectedTopology, topology)
+	assert.NotNil(t, topology.UnmarshalText([]byte("star")))
+
+	data := Data{
+		{Inputs: []float32{0, 0}, Outputs: []float32{0, 1}},
+		{Inputs: []float32{1, 1}, Outputs: []float32{1, 0}},
+	}
+	config := basicMathConfig(data)
+	config.SwarmCount = 3
+	config.Islands = &IslandConfiguration{
+		MigrationInterval: 2,
+		Migrants:          4,
+		Topology:          MigrationTopology(7),
+		Swarms:            make([]IslandHyperparameters, 4),
+	}
+	fields := map[string]DiagnosticSeverity{}
+	for _, d := range config.Validate(nil) {
+		fields[d.Field] = d.Severity
+	}
+	assert.Equal(t, DiagnosticError, fields["Islands.Migrants"])
+	assert.Equal(t, DiagnosticError, fields["Islands.Topology"])
+	assert.Equal(t, DiagnosticError, fields["Islands.Swarms"])
+
+	config.Islands = &IslandConfiguration{
+		MigrationInterval: 2,
+		Migrants:          1,
+		Topology:          FullyConnectedTopology,
+		Swarms:            []IslandHyperparameters{{InertialWeight: 0.5, ProbablityOfDeath: 0.1}},
+	}
+	assert.Empty(t, config.Validate(nil))
+
+	pti := particleTrainingInfo{InertialWeight: 0.7, CognitiveWeight: 1.5, GlobalWeight: 0.3, DeathRate: 0.005}
+	island := config.Islands.islandTrainingInfo(pti, 0)
+	assert.Equal(t, particleTrainingInfo{InertialWeight: 0.5, CognitiveWeight: 1.5, DeathRate: 0.1}, island)
+	assert.Equal(t, float32(0.7), config.Islands.islandTrainingInfo(pti, 2).InertialWeight)
+
+	bucket, err := DataToTensorDataBucket(data, true)
+	assert.Nil(t, err)
+	buckets, err := DataBucketToBuckets(2, bucket)
+	assert.Nil(t, err)
+	tc := DefaultTrainingConfig
+	tc.MaxIterations = 4
+	ms, err := NewMultiSwarm(config, tc)
+	assert.Nil(t, err)
+	ms.SetLogger(NopLogger)
+
+	var migrants []int
+	ms.OnIteration(func(is IterationStats) {
+		migrants = append(migrants, is.Migrants)
+	})
+	assert.Nil(t, ms.Train(buckets, false))
+	// each of 3 islands receives 1 migrant from both of the others every second iteration
+	assert.Equal(t, []int{0, 6, 0, 6}, migrants)
+
+	// after a migration every island holds the best particle of every island
+	best := float32(math.MaxFloat32)
+	for _, s := range ms.swarms {
+		best = math.Min(best, byBestLoss(s.particles)[0].nn.Best.Loss)
+	}
+	_, err = ms.migrate()
+	assert.Nil(t, err)
+	for _, s := range ms.swarms {
+		assert.Equal(t, best, byBestLoss(s.particles)[0].nn.Best.Loss)
+		assert.True(t, ms.bestLoss(fmt.Sprintf(swarmKeyFormat, s.id)) <= best)
+	}
+}
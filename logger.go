@@ -0,0 +1,111 @@
+package cogent
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+)
+
+//Logger is the levelled, structured logger training and encoding write to. args are
+//alternating keys and values. Its methods match log/slog's, so a *slog.Logger can be used as is.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+//LogLevel x, the values match slog.Level's
+type LogLevel int
+
+//LogLevels
+const (
+	DebugLevel LogLevel = -4
+	InfoLevel  LogLevel = 0
+	WarnLevel  LogLevel = 4
+	ErrorLevel LogLevel = 8
+)
+
+//String x
+func (l LogLevel) String() string {
+	switch l {
+	case DebugLevel:
+		return "DEBUG"
+	case InfoLevel:
+		return "INFO"
+	case WarnLevel:
+		return "WARN"
+	case ErrorLevel:
+		return "ERROR"
+	default:
+		return fmt.Sprint(int(l))
+	}
+}
+
+//Log keys used for the structured fields
+const (
+	SwarmKey     = "swarm"
+	ParticleKey  = "particle"
+	IterationKey = "iteration"
+	LossKey      = "loss"
+)
+
+//DefaultLogger is used by swarms and encoders that haven't been given a logger, it writes
+//info and above to the standard logger
+var DefaultLogger Logger = &textLogger{l: log.Default(), level: InfoLevel}
+
+//NopLogger discards everything, e.g. to silence tests
+var NopLogger Logger = nopLogger{}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(msg string, args ...interface{}) {}
+func (nopLogger) Info(msg string, args ...interface{})  {}
+func (nopLogger) Warn(msg string, args ...interface{})  {}
+func (nopLogger) Error(msg string, args ...interface{}) {}
+
+//textLogger writes a line per message at or above its level, e.g. "INFO msg key=value"
+type textLogger struct {
+	l     *log.Logger
+	level LogLevel
+}
+
+//NewLogger writes messages at or above level to w with the standard log flags
+func NewLogger(w io.Writer, level LogLevel) Logger {
+	return &textLogger{
+		l:     log.New(w, "", log.LstdFlags),
+		level: level,
+	}
+}
+
+func (tl *textLogger) Debug(msg string, args ...interface{}) { tl.log(DebugLevel, msg, args) }
+func (tl *textLogger) Info(msg string, args ...interface{})  { tl.log(InfoLevel, msg, args) }
+func (tl *textLogger) Warn(msg string, args ...interface{})  { tl.log(WarnLevel, msg, args) }
+func (tl *textLogger) Error(msg string, args ...interface{}) { tl.log(ErrorLevel, msg, args) }
+
+func (tl *textLogger) log(level LogLevel, msg string, args []interface{}) {
+	if level < tl.level {
+		return
+	}
+
+	sb := strings.Builder{}
+	sb.WriteString(level.String())
+	sb.WriteByte(' ')
+	sb.WriteString(msg)
+	for i := 0; i < len(args); i += 2 {
+		if i+1 == len(args) {
+			fmt.Fprintf(&sb, " !BADKEY=%v", args[i])
+			break
+		}
+		fmt.Fprintf(&sb, " %v=%v", args[i], args[i+1])
+	}
+	tl.l.Print(sb.String())
+}
+
+func loggerOrDefault(l Logger) Logger {
+	if l == nil {
+		return DefaultLogger
+	}
+	return l
+}
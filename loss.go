@@ -1,8 +1,6 @@
 package cogent
 
 import (
-	"runtime"
-
 	math "github.com/chewxy/math32"
 )
 
@@ -51,9 +49,6 @@ func crossLoss(expected, actual [][]float32) float32 {
 			} else {
 				x = -math.Log(1 - p)
 			}
-			if math.IsInf(x, 0) || x < 0 {
-				runtime.Breakpoint()
-			}
 			sum += x
 		}
 		count++
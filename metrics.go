@@ -0,0 +1,251 @@
+package cogent
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	math "github.com/chewxy/math32"
+)
+
+//DefaultMetricsNamespace prefixes every metric name when NewMetrics isn't given one
+const DefaultMetricsNamespace = "cogent"
+
+//DefaultDurationBuckets are the upper bounds, in seconds, of the duration histograms
+var DefaultDurationBuckets = []float64{0.0005, 0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+//Content types Metrics.ServeHTTP negotiates between
+const (
+	PrometheusContentType  = "text/plain; version=0.0.4; charset=utf-8"
+	OpenMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+)
+
+//Metrics collects training and inference metrics and exposes them in the Prometheus and
+//OpenMetrics text formats. Feed it with MultiSwarm.OnIteration(m.ObserveIteration) and
+//PredictorConfiguration{OnPrediction: m.ObservePrediction}, then scrape ServeHTTP.
+type Metrics struct {
+	mu        sync.Mutex
+	namespace string
+
+	trained         bool
+	globalBestLoss  float32
+	swarmBestLosses []float32
+	swarmIDs        []int
+	iterations      uint64
+	particleDeaths  uint64
+	iterationTime   *histogram
+	trainLayerTime  []float64
+
+	predictions      uint64
+	predictedRows    uint64
+	predictedBatches uint64
+	predictionTime   *histogram
+	predictLayerTime []float64
+}
+
+//NewMetrics x
+func NewMetrics(namespace string) *Metrics {
+	if namespace == "" {
+		namespace = DefaultMetricsNamespace
+	}
+	return &Metrics{
+		namespace:      namespace,
+		iterationTime:  newHistogram(DefaultDurationBuckets),
+		predictionTime: newHistogram(DefaultDurationBuckets),
+	}
+}
+
+//ObserveIteration records a training iteration, it is safe to call while scraping
+func (m *Metrics) ObserveIteration(stats IterationStats) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.trained = true
+	m.globalBestLoss = stats.GlobalBestLoss
+	m.swarmBestLosses = append(m.swarmBestLosses[:0], stats.SwarmBestLosses...)
+	m.swarmIDs = append(m.swarmIDs[:0], stats.SwarmIDs...)
+	m.iterations++
+	m.particleDeaths += uint64(stats.ParticleDeaths)
+	m.iterationTime.observe(stats.Duration.Seconds())
+	m.trainLayerTime = addDurations(m.trainLayerTime, stats.LayerDurations)
+}
+
+//ObservePrediction records a Predictor call, it matches PredictorConfiguration.OnPrediction
+func (m *Metrics) ObservePrediction(stats PredictionStats) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.predictions++
+	m.predictedRows += uint64(stats.Rows)
+	m.predictedBatches += uint64(stats.Batches)
+	m.predictionTime.observe(stats.Latency.Seconds())
+	m.predictLayerTime = addDurations(m.predictLayerTime, stats.LayerDurations)
+}
+
+func addDurations(seconds []float64, durations Durations) []float64 {
+	for len(seconds) < len(durations) {
+		seconds = append(seconds, 0)
+	}
+	for i, d := range durations {
+		seconds[i] += d.Seconds()
+	}
+	return seconds
+}
+
+//ServeHTTP writes OpenMetrics when the scraper accepts it and the Prometheus text format otherwise
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	openMetrics := strings.Contains(r.Header.Get("Accept"), "application/openmetrics-text")
+	contentType := PrometheusContentType
+	if openMetrics {
+		contentType = OpenMetricsContentType
+	}
+	w.Header().Set("Content-Type", contentType)
+	if err := m.write(w, openMetrics); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+//WritePrometheus writes every metric in the Prometheus text format
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	return m.write(w, false)
+}
+
+//WriteOpenMetrics writes every metric in the OpenMetrics text format
+func (m *Metrics) WriteOpenMetrics(w io.Writer) error {
+	return m.write(w, true)
+}
+
+func (m *Metrics) write(w io.Writer, openMetrics bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mw := &metricsWriter{
+		w:           bufio.NewWriter(w),
+		namespace:   m.namespace,
+		openMetrics: openMetrics,
+	}
+
+	if m.trained {
+		if m.globalBestLoss != math.MaxFloat32 {
+			mw.family("global_best_loss", "gauge", "Lowest loss found by any particle.")
+			mw.sample("global_best_loss", "", float64(m.globalBestLoss))
+		}
+		mw.family("swarm_best_loss", "gauge", "Lowest loss found by each swarm's particles.")
+		for i, loss := range m.swarmBestLosses {
+			//labelled with the swarm id the logs and blackboard use, stats made by hand may only have an index
+			id := i
+			if i < len(m.swarmIDs) {
+				id = m.swarmIDs[i]
+			}
+			if loss != math.MaxFloat32 {
+				mw.sample("swarm_best_loss", fmt.Sprintf(`swarm="%d"`, id), float64(loss))
+			}
+		}
+	}
+	mw.counter("iterations", "Training iterations completed.", float64(m.iterations))
+	mw.counter("particle_deaths", "Particles reset to random positions.", float64(m.particleDeaths))
+	mw.histogram("iteration_duration_seconds", "Time taken by each training iteration.", m.iterationTime)
+
+	mw.family("layer_activation_seconds", "counter", "Time spent activating each layer.")
+	phases := []string{"train", "predict"}
+	for p, seconds := range [][]float64{m.trainLayerTime, m.predictLayerTime} {
+		for i, s := range seconds {
+			mw.sample("layer_activation_seconds_total", fmt.Sprintf(`phase="%s",layer="%d"`, phases[p], i), s)
+		}
+	}
+
+	mw.counter("predictions", "Predictor calls.", float64(m.predictions))
+	mw.counter("predicted_rows", "Rows predicted.", float64(m.predictedRows))
+	mw.counter("predicted_batches", "Micro-batches predicted.", float64(m.predictedBatches))
+	mw.histogram("prediction_latency_seconds", "Latency of each Predictor call.", m.predictionTime)
+
+	if openMetrics {
+		mw.printf("# EOF\n")
+	}
+	if mw.err != nil {
+		return mw.err
+	}
+	return mw.w.Flush()
+}
+
+//histogram counts observations into buckets, the counts aren't cumulative until written
+type histogram struct {
+	bounds []float64
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{
+		bounds: bounds,
+		counts: make([]uint64, len(bounds)),
+	}
+}
+
+func (h *histogram) observe(x float64) {
+	for i, b := range h.bounds {
+		if x <= b {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += x
+	h.count++
+}
+
+//metricsWriter formats families and samples, keeping the first write error
+type metricsWriter struct {
+	w           *bufio.Writer
+	namespace   string
+	openMetrics bool
+	err         error
+}
+
+func (mw *metricsWriter) printf(format string, args ...interface{}) {
+	if mw.err == nil {
+		_, mw.err = fmt.Fprintf(mw.w, format, args...)
+	}
+}
+
+//family writes the HELP and TYPE lines, OpenMetrics names counter families without _total
+func (mw *metricsWriter) family(name, kind, help string) {
+	if kind == "counter" && !mw.openMetrics {
+		name += "_total"
+	}
+	name = mw.namespace + "_" + name
+	mw.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func (mw *metricsWriter) sample(name, labels string, value float64) {
+	name = mw.namespace + "_" + name
+	if labels != "" {
+		name += "{" + labels + "}"
+	}
+	mw.printf("%s %s\n", name, formatMetricValue(value))
+}
+
+func (mw *metricsWriter) counter(name, help string, value float64) {
+	mw.family(name, "counter", help)
+	mw.sample(name+"_total", "", value)
+}
+
+func (mw *metricsWriter) histogram(name, help string, h *histogram) {
+	mw.family(name, "histogram", help)
+	cumulative := uint64(0)
+	for i, b := range h.bounds {
+		cumulative += h.counts[i]
+		mw.sample(name+"_bucket", fmt.Sprintf(`le="%s"`, formatMetricValue(b)), float64(cumulative))
+	}
+	mw.sample(name+"_bucket", `le="+Inf"`, float64(h.count))
+	mw.sample(name+"_sum", "", h.sum)
+	mw.sample(name+"_count", "", float64(h.count))
+}
+
+func formatMetricValue(x float64) string {
+	return strconv.FormatFloat(x, 'g', -1, 64)
+}
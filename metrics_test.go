@@ -0,0 +1,85 @@
+package cogent
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Metrics(t *testing.T) {
+	m := NewMetrics("")
+	buf := &bytes.Buffer{}
+	assert.Nil(t, m.WritePrometheus(buf))
+	assert.NotContains(t, buf.String(), "cogent_global_best_loss")
+	assert.Contains(t, buf.String(), "# TYPE cogent_iterations_total counter\ncogent_iterations_total 0\n")
+
+	data := Data{
+		{Inputs: []float32{0, 0}, Outputs: []float32{0, 1}},
+		{Inputs: []float32{1, 1}, Outputs: []float32{1, 0}},
+	}
+	bucket, err := DataToTensorDataBucket(data, true)
+	assert.Nil(t, err)
+	buckets, err := DataBucketToBuckets(2, bucket)
+	assert.Nil(t, err)
+	tc := DefaultTrainingConfig
+	tc.MaxIterations = 3
+	config := basicMathConfig(data)
+	config.FirstSwarmID = 4
+	s, err := NewMultiSwarm(config, tc)
+	assert.Nil(t, err)
+	s.SetLogger(NopLogger)
+
+	var stats []IterationStats
+	s.OnIteration(func(is IterationStats) {
+		stats = append(stats, is)
+		m.ObserveIteration(is)
+	})
+	assert.Nil(t, s.Train(buckets, false))
+	assert.Len(t, stats, 3)
+	last := stats[2]
+	assert.Equal(t, 2, last.Iteration)
+	assert.Len(t, last.SwarmBestLosses, 2)
+	assert.Equal(t, []int{4, 5}, last.SwarmIDs)
+	assert.Len(t, last.LayerDurations, 2)
+	assert.True(t, last.LayerDurations[0] > 0)
+
+	nn, err := s.Best()
+	assert.Nil(t, err)
+	p, err := NewPredictor(nn, PredictorConfiguration{MaxBatchSize: 1, OnPrediction: m.ObservePrediction})
+	assert.Nil(t, err)
+	_, err = p.Predict([][]float32{{0, 0}, {1, 1}})
+	assert.Nil(t, err)
+
+	m.ObserveIteration(IterationStats{Iteration: 3, Duration: 2 * time.Second, SwarmIDs: []int{4, 5}, GlobalBestLoss: 0.5, SwarmBestLosses: []float32{0.5, 0.75}})
+	buf.Reset()
+	assert.Nil(t, m.WritePrometheus(buf))
+	text := buf.String()
+	for _, line := range []string{
+		"cogent_global_best_loss 0.5\n",
+		`cogent_swarm_best_loss{swarm="5"} 0.75` + "\n",
+		"cogent_iterations_total 4\n",
+		`cogent_iteration_duration_seconds_bucket{le="1"} 3` + "\n",
+		`cogent_iteration_duration_seconds_bucket{le="+Inf"} 4` + "\n",
+		"cogent_iteration_duration_seconds_count 4\n",
+		`cogent_layer_activation_seconds_total{phase="predict",layer="1"}`,
+		"cogent_predictions_total 1\n",
+		"cogent_predicted_rows_total 2\n",
+		"cogent_predicted_batches_total 2\n",
+		"cogent_prediction_latency_seconds_count 1\n",
+	} {
+		assert.Contains(t, text, line)
+	}
+	assert.False(t, strings.HasSuffix(text, "# EOF\n"))
+
+	r := httptest.NewRequest("GET", "/metrics", nil)
+	r.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	assert.Equal(t, OpenMetricsContentType, w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "# TYPE cogent_iterations counter\ncogent_iterations_total 4\n")
+	assert.True(t, strings.HasSuffix(w.Body.String(), "# EOF\n"))
+}
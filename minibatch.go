@@ -0,0 +1,62 @@
+package cogent
+
+import (
+	"math/rand"
+
+	t "gorgonia.org/tensor"
+)
+
+//sample draws about size rows at random, with replacement, keeping the buckets so the test
+//bucket stays apart from the training ones. Every bucket gives at least one row, in proportion
+//to its share of the rows, so drawing costs the size of the sample rather than of the data.
+func (buckets DataBuckets) sample(r *rand.Rand, size int) DataBuckets {
+	total := 0
+	for _, b := range buckets {
+		total += b.RowCount()
+	}
+
+	sampled := make(DataBuckets, len(buckets))
+	for i, b := range buckets {
+		rowCount := b.RowCount()
+		n := size * rowCount / total
+		if n < 1 {
+			n = 1
+		}
+
+		inputs, outputs := b.Inputs.Data().([]float32), b.Outputs.Data().([]float32)
+		iColCount, oColCount := b.Inputs.Shape()[1], b.OutputColCount()
+		sampledInputs := make([]float32, n*iColCount)
+		sampledOutputs := make([]float32, n*oColCount)
+		for row := 0; row < n; row++ {
+			from := r.Intn(rowCount)
+			copy(sampledInputs[row*iColCount:], inputs[from*iColCount:(from+1)*iColCount])
+			copy(sampledOutputs[row*oColCount:], outputs[from*oColCount:(from+1)*oColCount])
+		}
+		sampled[i] = &DataBucket{
+			Inputs:  t.New(t.Of(Float), t.WithShape(n, iColCount), t.WithBacking(sampledInputs)),
+			Outputs: t.New(t.Of(Float), t.WithShape(n, oColCount), t.WithBacking(sampledOutputs)),
+		}
+	}
+	return sampled
+}
+
+//batch is what a particle step is scored on, a sample of BatchSize rows or every row
+func (p *particle) batch(buckets DataBuckets, pti particleTrainingInfo) DataBuckets {
+	if pti.BatchSize <= 0 {
+		return buckets
+	}
+	return buckets.sample(p.r, pti.BatchSize)
+}
+
+//reevaluate scores the particle's current position again on a fresh sample of
+//ReevaluationSize rows, or every row, so a batch that happened to suit it doesn't make a best
+func (p *particle) reevaluate(buckets DataBuckets, pti particleTrainingInfo) (float32, error) {
+	if pti.ReevaluationSize > 0 {
+		buckets = buckets.sample(p.r, pti.ReevaluationSize)
+	}
+	loss, err := p.calculateMeanLoss(-1, buckets, pti.RidgeRegressionWeight, pti.ParallelBuckets)
+	if err != nil {
+		return 0, err
+	}
+	return loss.total, nil
+}
@@ -0,0 +1,63 @@
+package cogent
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_MiniBatch(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	data := Data{}
+	for i := 0; i < 200; i++ {
+		x, y := r.Float32(), r.Float32()
+		if x+y > 1 {
+			data = append(data, DataRow{Inputs: []float32{x, y}, Outputs: []float32{1, 0}})
+		} else {
+			data = append(data, DataRow{Inputs: []float32{x, y}, Outputs: []float32{0, 1}})
+		}
+	}
+	bucket, err := DataToTensorDataBucket(data, true)
+	assert.Nil(t, err)
+	buckets, err := DataBucketToBuckets(4, bucket)
+	assert.Nil(t, err)
+
+	// every sampled row is a row of its bucket
+	sampled := buckets.sample(r, 20)
+	assert.Len(t, sampled, 4)
+	for i, s := range sampled {
+		assert.Equal(t, 5, s.RowCount())
+		rows := DenseToRows(buckets[i].Inputs)
+		for _, row := range DenseToRows(s.Inputs) {
+			assert.Contains(t, rows, row)
+		}
+	}
+	assert.Equal(t, 1, buckets.sample(r, 1)[0].RowCount())
+
+	tc := DefaultTrainingConfig
+	tc.BatchSize = -1
+	assert.NotNil(t, tc.validate())
+	tc.BatchSize, tc.ReevaluationSize = 20, 10
+	assert.NotNil(t, tc.validate())
+	tc.ReevaluationSize = 0
+	assert.Nil(t, tc.validate())
+
+	tc.MaxIterations = 3
+	ms, err := NewMultiSwarm(basicMathConfig(data), tc)
+	assert.Nil(t, err)
+	ms.SetLogger(NopLogger)
+	assert.Nil(t, ms.Train(buckets, false))
+
+	// bests were scored again on every row, not on the batch that found them
+	for _, s := range ms.swarms {
+		for _, p := range s.particles {
+			for i, l := range p.nn.Best.Layers {
+				assert.Nil(t, l.WeightsAndBiases.CopyTo(p.nn.Layers[i].WeightsAndBiases))
+			}
+			full, err := p.calculateMeanLoss(-1, buckets, tc.RidgeRegressionWeight, false)
+			assert.Nil(t, err)
+			assert.InDelta(t, full.total, p.nn.Best.Loss, 1e-5)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package cogent
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"io"
+	"time"
+
+	math "github.com/chewxy/math32"
+	"github.com/pkg/errors"
+
+	t "gorgonia.org/tensor"
+)
+
+//Model files are laid out as, all integers little endian:
+//
+//	magic    8 bytes  "COGENTNN"
+//	version  uint32   ModelFileVersion
+//	length   uint32   byte length of the metadata
+//	metadata JSON     modelFileMetadata, architecture and ModelMetadata
+//	weights  float32  every layer's weights and biases, row major, in layer order
+//	checksum uint32   CRC-32 (IEEE) of the metadata and weights
+//
+//Each layer's weights are a (rows x cols) matrix where the last row holds the biases
+//and, for every layer but the last, the last column is the bias passthrough node.
+const (
+	modelFileMagic = "COGENTNN"
+
+	//ModelFileVersion is the version written by Save, Load reads this version or older
+	ModelFileVersion = 1
+
+	//maxModelMetadataLength and maxModelLayerWeights bound what Load allocates from a header,
+	//encoders with large vocabularies fit well within them
+	maxModelMetadataLength = 256 << 20
+	maxModelLayerWeights   = 1 << 28
+)
+
+//TrainingMetrics x
+type TrainingMetrics struct {
+	Loss     float32 `json:"loss"`
+	RMSE     float32 `json:"rmse"`
+	Accuracy float32 `json:"accuracy"`
+}
+
+//ModelMetadata describes how a network was built and trained
+type ModelMetadata struct {
+	CreatedAt time.Time       `json:"createdAt"`
+	Metrics   TrainingMetrics `json:"metrics"`
+	Encodings []EncodingMode  `json:"encodings,omitempty"`
+	Columns   []ColumnSpec    `json:"columns,omitempty"`
+
+	//InputEncoder and OutputEncoder are the fitted encoders that produced the training data
+	InputEncoder  *TableEncoder `json:"inputEncoder,omitempty"`
+	OutputEncoder *TableEncoder `json:"outputEncoder,omitempty"`
+}
+
+type modelFileLayer struct {
+	NodeCount  int            `json:"nodeCount"`
+	Activation ActivationMode `json:"activation"`
+	Rows       int            `json:"rows"`
+	Cols       int            `json:"cols"`
+}
+
+type modelFileMetadata struct {
+	Loss       LossMode         `json:"loss"`
+	InputCount int              `json:"inputCount"`
+	Layers     []modelFileLayer `json:"layers"`
+	ModelMetadata
+}
+
+//Save writes the network in the versioned model file format
+func (nn *NeuralNetwork) Save(w io.Writer) error {
+	if len(nn.Layers) == 0 {
+		return errors.New("can't save network without layers")
+	}
+
+	meta := modelFileMetadata{
+		Loss:          nn.Loss,
+		InputCount:    nn.Layers[0].WeightsAndBiases.Shape()[0] - 1,
+		Layers:        make([]modelFileLayer, len(nn.Layers)),
+		ModelMetadata: nn.Metadata,
+	}
+	if meta.CreatedAt.IsZero() {
+		meta.CreatedAt = time.Now().UTC()
+	}
+	for i, l := range nn.Layers {
+		s := l.WeightsAndBiases.Shape()
+		meta.Layers[i] = modelFileLayer{
+			NodeCount:  l.NodeCount,
+			Activation: l.Activation,
+			Rows:       s[0],
+			Cols:       s[1],
+		}
+	}
+
+	metaBytes, err := json.Marshal(meta)
+	if err != nil {
+		return errors.Wrap(err, "can't encode model metadata")
+	}
+
+	bw := bufio.NewWriter(w)
+	checksum := crc32.NewIEEE()
+	body := io.MultiWriter(bw, checksum)
+
+	header := make([]byte, len(modelFileMagic)+8)
+	copy(header, modelFileMagic)
+	binary.LittleEndian.PutUint32(header[len(modelFileMagic):], ModelFileVersion)
+	binary.LittleEndian.PutUint32(header[len(modelFileMagic)+4:], uint32(len(metaBytes)))
+	if _, err := bw.Write(header); err != nil {
+		return errors.Wrap(err, "can't write model header")
+	}
+
+	if _, err := body.Write(metaBytes); err != nil {
+		return errors.Wrap(err, "can't write model metadata")
+	}
+
+	for i, l := range nn.Layers {
+		data := l.WeightsAndBiases.Data().([]float32)
+		if err := binary.Write(body, binary.LittleEndian, data); err != nil {
+			return errors.Wrapf(err, "can't write weights for layer %d", i)
+		}
+	}
+
+	if err := binary.Write(bw, binary.LittleEndian, checksum.Sum32()); err != nil {
+		return errors.Wrap(err, "can't write model checksum")
+	}
+	return errors.Wrap(bw.Flush(), "can't flush model")
+}
+
+//Load reads a network written by Save
+func Load(r io.Reader) (*NeuralNetwork, error) {
+	br := bufio.NewReader(r)
+
+	header := make([]byte, len(modelFileMagic)+8)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, errors.Wrap(err, "can't read model header")
+	}
+	if !bytes.Equal(header[:len(modelFileMagic)], []byte(modelFileMagic)) {
+		return nil, errors.New("not a cogent model file")
+	}
+
+	version := binary.LittleEndian.Uint32(header[len(modelFileMagic):])
+	if version == 0 || version > ModelFileVersion {
+		return nil, errors.Errorf("unsupported model file version %d", version)
+	}
+
+	checksum := crc32.NewIEEE()
+	body := io.TeeReader(br, checksum)
+
+	metaLength := binary.LittleEndian.Uint32(header[len(modelFileMagic)+4:])
+	if metaLength > maxModelMetadataLength {
+		return nil, errors.Errorf("model metadata of %d bytes is too large", metaLength)
+	}
+	metaBytes := make([]byte, metaLength)
+	if _, err := io.ReadFull(body, metaBytes); err != nil {
+		return nil, errors.Wrap(err, "can't read model metadata")
+	}
+
+	meta := modelFileMetadata{}
+	if err := json.Unmarshal(metaBytes, &meta); err != nil {
+		return nil, errors.Wrap(err, "can't decode model metadata")
+	}
+
+	if _, ok := LossFns[meta.Loss]; !ok {
+		return nil, invalidConfig("invalid loss type '%d'", meta.Loss)
+	}
+	if len(meta.Layers) == 0 {
+		return nil, shapeMismatch("model has no layers")
+	}
+	if meta.InputCount <= 0 {
+		return nil, shapeMismatch("model has %d inputs", meta.InputCount)
+	}
+
+	nn := &NeuralNetwork{
+		Loss:        meta.Loss,
+		Layers:      make([]LayerData, len(meta.Layers)),
+		CurrentLoss: meta.Metrics.Loss,
+		Metadata:    meta.ModelMetadata,
+	}
+
+	expectedRows := meta.InputCount + 1
+	lastLayerIndex := len(meta.Layers) - 1
+	for i, ml := range meta.Layers {
+		if _, ok := activations[ml.Activation]; !ok {
+			return nil, invalidConfig("invalid activation '%d' for layer %d", ml.Activation, i)
+		}
+		if ml.Rows != expectedRows || ml.Cols <= 0 {
+			msg := "layer %d has shape %dx%d, expected %d rows"
+			return nil, shapeMismatch(msg, i, ml.Rows, ml.Cols, expectedRows)
+		}
+		// hidden layers carry the bias passthrough column on top of their nodes
+		expectedCols := ml.NodeCount + 1
+		if i == lastLayerIndex {
+			expectedCols = ml.NodeCount
+		}
+		if ml.NodeCount <= 0 || ml.Cols != expectedCols {
+			return nil, shapeMismatch("layer %d has %d nodes and %d columns", i, ml.NodeCount, ml.Cols)
+		}
+		if ml.Cols > maxModelLayerWeights/ml.Rows {
+			return nil, shapeMismatch("layer %d of %dx%d weights is too large", i, ml.Rows, ml.Cols)
+		}
+
+		data := make([]float32, ml.Rows*ml.Cols)
+		if err := binary.Read(body, binary.LittleEndian, data); err != nil {
+			return nil, errors.Wrapf(err, "can't read weights for layer %d", i)
+		}
+		for _, w := range data {
+			if math.IsNaN(w) || math.IsInf(w, 0) {
+				return nil, errors.Errorf("layer %d has non finite weights", i)
+			}
+		}
+
+		nn.Layers[i] = LayerData{
+			NodeCount:  ml.NodeCount,
+			Activation: ml.Activation,
+			WeightsAndBiases: t.New(
+				t.Of(Float),
+				t.WithShape(ml.Rows, ml.Cols),
+				t.WithBacking(data),
+			),
+		}
+		expectedRows = ml.Cols
+	}
+
+	var expectedChecksum uint32
+	if err := binary.Read(br, binary.LittleEndian, &expectedChecksum); err != nil {
+		return nil, errors.Wrap(err, "can't read model checksum")
+	}
+	if expectedChecksum != checksum.Sum32() {
+		return nil, errors.New("model checksum mismatch, file is corrupt")
+	}
+
+	nn.Best = nnToPosition(meta.Metrics.Loss, nn)
+	return nn, nil
+}
@@ -0,0 +1,144 @@
+package cogent
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ModelFileRoundTrip(t *testing.T) {
+	nn := testNeuralNetwork(3,
+		LayerConfig{NodeCount: 5, Activation: LeakyReLU},
+		LayerConfig{NodeCount: 4, Activation: HyperbolicTangent},
+		LayerConfig{NodeCount: 2, Activation: Softmax},
+	)
+	nn.Metadata = ModelMetadata{
+		CreatedAt: time.Date(2018, 7, 1, 12, 0, 0, 0, time.UTC),
+		Metrics: TrainingMetrics{
+			Loss:     0.25,
+			RMSE:     0.5,
+			Accuracy: 0.75,
+		},
+		Encodings: []EncodingMode{NormalizedEncodingMode, OneHotEncodingMode},
+	}
+
+	buf := bytes.Buffer{}
+	assert.Nil(t, nn.Save(&buf))
+
+	loaded, err := Load(bytes.NewReader(buf.Bytes()))
+	assert.Nil(t, err)
+	assert.Equal(t, nn.Loss, loaded.Loss)
+	assert.Equal(t, nn.Metadata, loaded.Metadata)
+	assert.Equal(t, float32(0.25), loaded.Best.Loss)
+	assert.Len(t, loaded.Layers, 3)
+	for i, l := range loaded.Layers {
+		assert.Equal(t, nn.Layers[i].NodeCount, l.NodeCount)
+		assert.Equal(t, nn.Layers[i].Activation, l.Activation)
+		assert.Equal(t, nn.Layers[i].WeightsAndBiases.Shape(), l.WeightsAndBiases.Shape())
+	}
+
+	bucket, err := DataToTensorDataBucket(Data{
+		{Inputs: []float32{0, 1, 2}, Outputs: []float32{1, 0}},
+		{Inputs: []float32{-1, 0.5, 3}, Outputs: []float32{0, 1}},
+	}, true)
+	assert.Nil(t, err)
+	expected, _, err := nn.Activate(bucket.Inputs)
+	assert.Nil(t, err)
+	actual, _, err := loaded.Activate(bucket.Inputs)
+	assert.Nil(t, err)
+	assert.Equal(t, expected.Data(), actual.Data())
+
+	// metadata is plain JSON following the header
+	metaLength := binary.LittleEndian.Uint32(buf.Bytes()[12:16])
+	meta := map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal(buf.Bytes()[16:16+metaLength], &meta))
+	assert.Equal(t, "CrossLoss", meta["loss"])
+	assert.Equal(t, float64(3), meta["inputCount"])
+}
+
+func Test_ModelFileErrors(t *testing.T) {
+	nn := testNeuralNetwork(2,
+		LayerConfig{NodeCount: 2, Activation: Sigmoid},
+	)
+	buf := bytes.Buffer{}
+	assert.Nil(t, nn.Save(&buf))
+	valid := buf.Bytes()
+
+	corrupt := append([]byte(nil), valid...)
+	corrupt[len(corrupt)-6] ^= 0xff
+	_, err := Load(bytes.NewReader(corrupt))
+	assert.NotNil(t, err)
+
+	future := append([]byte(nil), valid...)
+	binary.LittleEndian.PutUint32(future[8:], ModelFileVersion+1)
+	_, err = Load(bytes.NewReader(future))
+	assert.NotNil(t, err)
+
+	_, err = Load(bytes.NewReader([]byte("not a model at all")))
+	assert.NotNil(t, err)
+
+	_, err = Load(bytes.NewReader(valid[:len(valid)-10]))
+	assert.NotNil(t, err)
+
+	huge := append([]byte(nil), valid...)
+	binary.LittleEndian.PutUint32(huge[12:], maxModelMetadataLength+1)
+	_, err = Load(bytes.NewReader(huge))
+	assert.NotNil(t, err)
+
+	for _, c := range []struct {
+		name    string
+		meta    modelFileMetadata
+		weights int
+		cause   error
+	}{
+		{"node count", modelFileMetadata{InputCount: 1, Layers: []modelFileLayer{{NodeCount: 5, Rows: 2, Cols: 1}}}, 2, ErrShapeMismatch},
+		{"hidden node count", modelFileMetadata{InputCount: 1, Layers: []modelFileLayer{{NodeCount: 2, Rows: 2, Cols: 2}, {NodeCount: 1, Rows: 2, Cols: 1}}}, 6, ErrShapeMismatch},
+		{"no inputs", modelFileMetadata{InputCount: -1, Layers: []modelFileLayer{{NodeCount: 1, Rows: 0, Cols: 1}}}, 0, ErrShapeMismatch},
+		{"too large", modelFileMetadata{InputCount: 1, Layers: []modelFileLayer{{NodeCount: 1 << 30, Rows: 2, Cols: 1 << 30}}}, 0, ErrShapeMismatch},
+		{"activation", modelFileMetadata{InputCount: 1, Layers: []modelFileLayer{{NodeCount: 1, Activation: 99, Rows: 2, Cols: 1}}}, 2, ErrInvalidConfig},
+		{"loss", modelFileMetadata{Loss: 99, InputCount: 1, Layers: []modelFileLayer{{NodeCount: 1, Rows: 2, Cols: 1}}}, 2, ErrInvalidConfig},
+	} {
+		_, err := Load(bytes.NewReader(craftModel(t, c.meta, c.weights)))
+		assert.Equal(t, c.cause, errors.Cause(err), c.name)
+	}
+	_, err = Load(bytes.NewReader(craftModel(t, modelFileMetadata{InputCount: 1, Layers: []modelFileLayer{{NodeCount: 1, Rows: 2, Cols: 1}}}, 2)))
+	assert.Nil(t, err)
+}
+
+//craftModel writes a model file with any metadata and that many zero weights, as Save never would
+func craftModel(t *testing.T, meta modelFileMetadata, weights int) []byte {
+	metaBytes, err := json.Marshal(meta)
+	assert.Nil(t, err)
+	body := append(metaBytes, make([]byte, 4*weights)...)
+
+	buf := bytes.Buffer{}
+	buf.WriteString(modelFileMagic)
+	binary.Write(&buf, binary.LittleEndian, uint32(ModelFileVersion))
+	binary.Write(&buf, binary.LittleEndian, uint32(len(metaBytes)))
+	buf.Write(body)
+	binary.Write(&buf, binary.LittleEndian, crc32.ChecksumIEEE(body))
+	return buf.Bytes()
+}
+
+func Test_ModeNames(t *testing.T) {
+	var a ActivationMode
+	assert.Nil(t, a.UnmarshalText([]byte("leakyrelu")))
+	assert.Equal(t, LeakyReLU, a)
+	assert.Equal(t, "SplitSoftmax", SplitSoftmax.String())
+
+	var l LossMode
+	assert.Nil(t, l.UnmarshalText([]byte("SquaredLoss")))
+	assert.Equal(t, SquaredLoss, l)
+	assert.NotNil(t, l.UnmarshalText([]byte("nope")))
+
+	var e EncodingMode
+	assert.Nil(t, e.UnmarshalText([]byte("2")))
+	assert.Equal(t, OneHotEncodingMode, e)
+	assert.Equal(t, "99", EncodingMode(99).String())
+}
@@ -2,90 +2,211 @@ package cogent
 
 import (
 	"fmt"
-	"log"
+	"math/rand"
 	"sync"
 	"time"
 
 	math "github.com/chewxy/math32"
+	"github.com/pkg/errors"
 
 	t "gorgonia.org/tensor"
 )
 
 const (
-	globalKey       = "global"
-	bestGlobalNNKey = "globalNN"
-	swarmKeyFormat  = "swarm_%d"
+	globalKey      = "global"
+	swarmKeyFormat = "swarm_%d"
 )
 
 //MultiSwarm x
 type MultiSwarm struct {
 	particleCount  int
-	blackboard     *sync.Map
+	blackboard     Blackboard
+	best           *bestNetwork
 	swarms         []*swarm
 	trainingConfig TrainingConfiguration
+	nnConfig       NeuralNetworkConfiguration
 	buckets        DataBuckets
+	logger         Logger
+	islands        *IslandConfiguration
+	//r picks the destinations of random migrations
+	r *rand.Rand
+
+	hooksMu     sync.Mutex
+	onIteration []func(stats IterationStats)
 
 	predictor *NeuralNetwork
 }
 
+//IterationStats is what every particle did in one training iteration
+type IterationStats struct {
+	Iteration int
+	Duration  time.Duration
+	//SwarmIDs are the ids, as in logs and blackboard keys, of the swarms the other per swarm
+	//slices describe in the same order, they start at MultiSwarmConfiguration.FirstSwarmID
+	SwarmIDs []int
+	//GlobalBestLoss and SwarmBestLosses are math.MaxFloat32 until a best is found
+	GlobalBestLoss  float32
+	SwarmBestLosses []float32
+	//SwarmDiversity is the mean distance of each swarm's particles from their centroid,
+	//it shrinks as a swarm converges
+	SwarmDiversity []float32
+	ParticleDeaths int
+	//Migrants is how many particles were replaced by migrants after the iteration, see IslandConfiguration
+	Migrants int
+	//LayerDurations is the time every particle spent activating each layer
+	LayerDurations Durations
+}
+
+//bestNetwork is the best network the particles in this process found, kept whole with its
+//metrics as the blackboard only holds positions
+type bestNetwork struct {
+	mu sync.Mutex
+	nn *NeuralNetwork
+}
+
+func (b *bestNetwork) offer(nn *NeuralNetwork) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.nn == nil || nn.Best.Loss < b.nn.Best.Loss {
+		b.nn = nn
+	}
+}
+
+func (b *bestNetwork) get() *NeuralNetwork {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.nn
+}
+
+//particleJob is a particle and the training info of its swarm
+type particleJob struct {
+	p   *particle
+	pti particleTrainingInfo
+}
+
 type swarm struct {
 	id        int
 	particles []*particle
 }
 
-//NewMultiSwarm x
-func NewMultiSwarm(config MultiSwarmConfiguration, trainingConfig TrainingConfiguration) *MultiSwarm {
-	if config.SwarmCount <= 0 {
-		panic("No swarm count in config")
-	}
+//NewMultiSwarm validates the configurations and creates every particle, errors wrap ErrInvalidConfig
+func NewMultiSwarm(config MultiSwarmConfiguration, trainingConfig TrainingConfiguration) (*MultiSwarm, error) {
+	return NewMultiSwarmWithBlackboard(config, trainingConfig, NewMemoryBlackboard())
+}
 
-	if trainingConfig.MaxIterations <= 0 {
-		panic("No iterations in training config")
+//NewMultiSwarmWithBlackboard shares bests through bb, e.g. an HTTPBlackboard so swarms in several
+//processes train together. Give each process its own FirstSwarmID so their swarms don't collide.
+func NewMultiSwarmWithBlackboard(config MultiSwarmConfiguration, trainingConfig TrainingConfiguration, bb Blackboard) (*MultiSwarm, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	if err := trainingConfig.validate(); err != nil {
+		return nil, err
+	}
+	if bb == nil {
+		return nil, invalidConfig("no blackboard")
 	}
 
-	bb := &sync.Map{}
-	tmpParticle := newParticle(-1, -1, bb, trainingConfig.WeightRange, config.NeuralNetworkConfiguration)
+	tmpParticle, err := newParticle(-1, -1, bb, trainingConfig.WeightRange, config.NeuralNetworkConfiguration)
+	if err != nil {
+		return nil, err
+	}
 
-	bb.Store(globalKey, Position{
-		Loss:   math.MaxFloat32,
-		Layers: tmpParticle.nn.Layers,
-	})
+	// starting positions only take when no other process has stored one
+	if _, err := bb.Offer(globalKey, nnToPosition(math.MaxFloat32, tmpParticle.nn)); err != nil {
+		return nil, errors.Wrap(err, "can't offer starting global position")
+	}
 
 	ms := MultiSwarm{
 		swarms:         make([]*swarm, config.SwarmCount),
 		particleCount:  int(config.SwarmCount * config.ParticleCount),
 		blackboard:     bb,
+		best:           &bestNetwork{},
 		trainingConfig: trainingConfig,
+		nnConfig:       config.NeuralNetworkConfiguration,
+		logger:         DefaultLogger,
+		islands:        config.Islands,
+		r:              rand.New(rand.NewSource(int64(config.FirstSwarmID))),
 	}
-	for swarmID := range ms.swarms {
+	for i := range ms.swarms {
 		s := &swarm{
-			id:        swarmID,
+			id:        config.FirstSwarmID + i,
 			particles: make([]*particle, config.ParticleCount),
 		}
 
 		for particleID := 0; particleID < int(config.ParticleCount); particleID++ {
-			s.particles[particleID] = newParticle(swarmID, particleID, bb, trainingConfig.WeightRange, config.NeuralNetworkConfiguration)
+			p, err := newParticle(s.id, particleID, bb, trainingConfig.WeightRange, config.NeuralNetworkConfiguration)
+			if err != nil {
+				return nil, err
+			}
+			p.logger = ms.logger
+			p.best = ms.best
+			s.particles[particleID] = p
 		}
-		ms.swarms[swarmID] = s
+		ms.swarms[i] = s
 
 		tmpParticle.nn.reset(tmpParticle.r, tmpParticle.layersTrainingInfo, trainingConfig.WeightRange)
 		swarmKey := fmt.Sprintf(swarmKeyFormat, s.id)
+		if _, err := bb.Offer(swarmKey, nnToPosition(math.MaxFloat32, tmpParticle.nn)); err != nil {
+			return nil, errors.Wrap(err, "can't offer starting swarm position")
+		}
+	}
 
-		bb.Store(swarmKey, Position{
-			Loss:   math.MaxFloat32,
-			Layers: tmpParticle.nn.Layers,
-		})
+	return &ms, nil
+}
+
+//SetLogger replaces DefaultLogger for the swarm and its particles, NopLogger silences them
+func (ms *MultiSwarm) SetLogger(logger Logger) {
+	ms.logger = loggerOrDefault(logger)
+	for _, s := range ms.swarms {
+		for _, p := range s.particles {
+			p.logger = ms.logger
+		}
 	}
+}
 
-	wbCount := ms.swarms[0].particles[0].nn.weightsAndBiasesCount()
-	log.Printf("using %d weights and biases", wbCount)
+//OnIteration calls fn with the stats of every iteration once all its particles are done, e.g.
+//Metrics.ObserveIteration. Every fn added is called, it can be added while training.
+func (ms *MultiSwarm) OnIteration(fn func(stats IterationStats)) {
+	ms.hooksMu.Lock()
+	defer ms.hooksMu.Unlock()
+	ms.onIteration = append(ms.onIteration, fn)
+}
+
+//Seed starts every particle from the weights of an existing network, e.g. to fine-tune an imported one
+func (ms *MultiSwarm) Seed(nn *NeuralNetwork) error {
+	template := ms.swarms[0].particles[0].nn
+	if len(template.Layers) != len(nn.Layers) {
+		return shapeMismatch("network has %d layers, swarm has %d", len(nn.Layers), len(template.Layers))
+	}
+	for i, l := range nn.Layers {
+		tl := template.Layers[i]
+		if !tl.WeightsAndBiases.Shape().Eq(l.WeightsAndBiases.Shape()) {
+			msg := "layer %d has shape %v, swarm has %v"
+			return shapeMismatch(msg, i, l.WeightsAndBiases.Shape(), tl.WeightsAndBiases.Shape())
+		}
+		if tl.Activation != l.Activation {
+			msg := "layer %d uses %s, swarm uses %s"
+			return invalidConfig(msg, i, l.Activation, tl.Activation)
+		}
+	}
 
-	return &ms
+	for _, s := range ms.swarms {
+		for _, p := range s.particles {
+			for i, l := range nn.Layers {
+				l.WeightsAndBiases.CopyTo(p.nn.Layers[i].WeightsAndBiases)
+			}
+		}
+	}
+	return nil
 }
 
-//Train x
-func (ms *MultiSwarm) Train(buckets DataBuckets, shouldMultithread bool) {
-	// log.Printf("%+v %+v", ms.dataset.Inputs, ms.dataset.Outputs)
+//Train runs every particle for MaxIterations, k-fold over the buckets. Buckets that don't match
+//the network's inputs, including the bias column, or outputs return ErrShapeMismatch.
+func (ms *MultiSwarm) Train(buckets DataBuckets, shouldMultithread bool) error {
+	if err := ms.nnConfig.validateBuckets(buckets); err != nil {
+		return err
+	}
 
 	pti := particleTrainingInfo{
 		TargetAccuracy:        ms.trainingConfig.TargetAccuracy,
@@ -97,24 +218,57 @@ func (ms *MultiSwarm) Train(buckets DataBuckets, shouldMultithread bool) {
 		DeathRate:             ms.trainingConfig.ProbablityOfDeath,
 		RidgeRegressionWeight: ms.trainingConfig.RidgeRegressionWeight,
 		StoreGlobalBest:       ms.trainingConfig.StoreGlobalBest,
+		ParallelBuckets:       ms.trainingConfig.ParallelBuckets,
+		BatchSize:             ms.trainingConfig.BatchSize,
+		ReevaluationSize:      ms.trainingConfig.ReevaluationSize,
 	}
 
-	iterations, avgTime := 0, time.Duration(0)
+	// islands train with their own hyperparameters
+	jobs := make([]particleJob, 0, ms.particleCount)
+	for i, s := range ms.swarms {
+		spti := pti
+		if ms.islands != nil {
+			spti = ms.islands.islandTrainingInfo(pti, i)
+		}
+		for _, p := range s.particles {
+			jobs = append(jobs, particleJob{p, spti})
+		}
+	}
+	workers := 1
+	if shouldMultithread {
+		workers = ms.trainingConfig.parallelism(len(jobs))
+	}
+	pool := newWorkerPool(workers)
+	defer pool.close()
+
+	wbCount := ms.swarms[0].particles[0].nn.weightsAndBiasesCount()
+	ms.logger.Info("training started",
+		"weightsAndBiases", wbCount,
+		"swarms", len(ms.swarms),
+		"particles", ms.particleCount,
+		"buckets", len(buckets),
+		"workers", workers,
+	)
+
+	iterations, totalTime := 0, time.Duration(0)
 	for ; iterations < ms.trainingConfig.MaxIterations; iterations++ {
-		// log.Printf("iteration %d started.", iterations)
 		start := time.Now()
-		wg := &sync.WaitGroup{}
-		wg.Add(ms.particleCount)
-		for _, s := range ms.swarms {
-			for _, p := range s.particles {
-				if shouldMultithread {
-					go p.train(wg, iterations, pti, buckets)
-				} else {
-					p.train(wg, iterations, pti, buckets)
-				}
+		bestGlobal, bestSwarms, err := ms.loadBests()
+		if err != nil {
+			return errors.Wrapf(err, "iteration %d", iterations)
+		}
+		errs := make([]error, len(jobs))
+		pool.run(len(jobs), func(i int) {
+			p := jobs[i].p
+			if err := p.train(iterations, jobs[i].pti, buckets, bestGlobal, bestSwarms[p.swarmID]); err != nil {
+				errs[i] = errors.Wrapf(err, "swarm %d particle %d", p.swarmID, p.id)
+			}
+		})
+		for _, err := range errs {
+			if err != nil {
+				return errors.Wrapf(err, "iteration %d", iterations)
 			}
 		}
-		wg.Wait()
 
 		// var nn *NeuralNetwork
 		// for _, s := range ms.swarms {
@@ -125,8 +279,29 @@ func (ms *MultiSwarm) Train(buckets DataBuckets, shouldMultithread bool) {
 		// 	}
 		// }
 		// bestAcc := nn.ClassificationAccuracy(buckets, -1)
-		log.Printf("iteration %d took %s.", iterations, time.Since(start))
-		// avgTime += time.Since(start)
+		migrants := 0
+		if ms.islands.shouldMigrate(iterations) {
+			var err error
+			if migrants, err = ms.migrate(); err != nil {
+				return errors.Wrapf(err, "iteration %d migration", iterations)
+			}
+		}
+
+		stats := ms.iterationStats(iterations, time.Since(start))
+		stats.Migrants = migrants
+		totalTime += stats.Duration
+		ms.logger.Info("iteration finished",
+			IterationKey, iterations,
+			"took", stats.Duration,
+			LossKey, stats.GlobalBestLoss,
+			"deaths", stats.ParticleDeaths,
+		)
+		ms.hooksMu.Lock()
+		hooks := ms.onIteration
+		ms.hooksMu.Unlock()
+		for _, fn := range hooks {
+			fn(stats)
+		}
 
 		// if bestAcc >= pti.TargetAccuracy {
 		// 	ms.predictor = nn
@@ -134,30 +309,188 @@ func (ms *MultiSwarm) Train(buckets DataBuckets, shouldMultithread bool) {
 		// }
 	}
 
-	log.Printf("Did %d iterations taking on average %s.", iterations, avgTime/time.Duration(iterations+1))
+	ms.logger.Info("training finished", "iterations", iterations, "average", totalTime/time.Duration(iterations))
+	return nil
+}
+
+//loadBests snapshots the global and every swarm's best for an iteration, the particles only
+//read them while other particles offer new ones
+func (ms *MultiSwarm) loadBests() (Position, map[int]Position, error) {
+	global, err := ms.loadCheckedPosition(globalKey)
+	if err != nil {
+		return Position{}, nil, err
+	}
+	swarms := make(map[int]Position, len(ms.swarms))
+	for _, s := range ms.swarms {
+		if swarms[s.id], err = ms.loadCheckedPosition(fmt.Sprintf(swarmKeyFormat, s.id)); err != nil {
+			return Position{}, nil, err
+		}
+	}
+	return global, swarms, nil
+}
+
+//loadCheckedPosition is loadPosition for positions the particles move towards, which another
+//process may have stored, so they must have the layers of this process's networks
+func (ms *MultiSwarm) loadCheckedPosition(key string) (Position, error) {
+	position, err := loadPosition(ms.blackboard, key)
+	if err != nil {
+		return Position{}, err
+	}
+	if err := ms.checkPosition(position); err != nil {
+		return Position{}, errors.Wrapf(err, "'%s' position", key)
+	}
+	return position, nil
+}
+
+//checkPosition compares position with the first particle's network, every particle has the same
+//layers and training only changes their weights
+func (ms *MultiSwarm) checkPosition(position Position) error {
+	return checkPosition(ms.swarms[0].particles[0].nn.Layers, position)
 }
 
-//Best x
-func (ms *MultiSwarm) predictNN() *NeuralNetwork {
+func (ms *MultiSwarm) bestLoss(key string) float32 {
+	position, ok, err := ms.blackboard.Load(key)
+	if err != nil {
+		ms.logger.Warn("can't load best loss", "key", key, "err", err)
+		return math.MaxFloat32
+	}
+	if !ok {
+		return math.MaxFloat32
+	}
+	return position.Loss
+}
+
+//iterationStats sums up the particles once they've all finished the iteration
+func (ms *MultiSwarm) iterationStats(iteration int, took time.Duration) IterationStats {
+	stats := IterationStats{
+		Iteration:       iteration,
+		Duration:        took,
+		SwarmIDs:        make([]int, len(ms.swarms)),
+		GlobalBestLoss:  ms.bestLoss(globalKey),
+		SwarmBestLosses: make([]float32, len(ms.swarms)),
+		SwarmDiversity:  make([]float32, len(ms.swarms)),
+		LayerDurations:  make(Durations, len(ms.nnConfig.LayerConfigs)),
+	}
+	for i, s := range ms.swarms {
+		stats.SwarmIDs[i] = s.id
+		stats.SwarmBestLosses[i] = ms.bestLoss(fmt.Sprintf(swarmKeyFormat, s.id))
+		stats.SwarmDiversity[i] = s.diversity()
+		for _, p := range s.particles {
+			stats.ParticleDeaths += p.deaths
+			for l, d := range p.layerDurations {
+				stats.LayerDurations[l] += d
+			}
+		}
+	}
+	return stats
+}
+
+//predictNN is this process's best network, or one made from the global position when another
+//process sharing the blackboard found a better one
+func (ms *MultiSwarm) predictNN() (*NeuralNetwork, error) {
 	if ms.predictor != nil {
-		return ms.predictor
+		return ms.predictor, nil
 	}
 
-	res, ok := ms.blackboard.Load(bestGlobalNNKey)
-	checkOk(ok)
-	bestGlobal := res.(NeuralNetwork)
-	return &bestGlobal
+	local := ms.best.get()
+	global, ok, err := ms.blackboard.Load(globalKey)
+	if err != nil {
+		if local != nil {
+			ms.logger.Warn("can't load global best, using local best", "err", err)
+			return local, nil
+		}
+		return nil, errors.Wrap(err, "can't load global best")
+	}
+	if !ok || global.Loss == math.MaxFloat32 || (local != nil && local.Best.Loss <= global.Loss) {
+		if local == nil {
+			return nil, ErrNoBestNetwork
+		}
+		return local, nil
+	}
+	if err := ms.checkPosition(global); err != nil {
+		if local != nil {
+			ms.logger.Warn("global best doesn't fit the network, using local best", "err", err)
+			return local, nil
+		}
+		return nil, errors.Wrap(err, "global best doesn't fit the network")
+	}
+
+	nn := &NeuralNetwork{
+		Loss:        ms.nnConfig.Loss,
+		Layers:      make([]LayerData, len(global.Layers)),
+		CurrentLoss: global.Loss,
+		Best:        global,
+	}
+	for i, l := range global.Layers {
+		nn.Layers[i] = l.Clone()
+	}
+	nn.Metadata.CreatedAt = time.Now().UTC()
+	nn.Metadata.Metrics.Loss = global.Loss
+	return nn, nil
+}
+
+//Best returns a copy of the best network found so far, or ErrNoBestNetwork before training finds one
+func (ms *MultiSwarm) Best() (*NeuralNetwork, error) {
+	nn, err := ms.predictNN()
+	if err != nil {
+		return nil, err
+	}
+	return nn.Clone(), nil
 }
 
 //ClassificationAccuracy x
-func (ms *MultiSwarm) ClassificationAccuracy(buckets DataBuckets) float32 {
-	ms.predictNN()
-	acc := ms.predictNN().ClassificationAccuracy(buckets, -1)
-	return acc
+func (ms *MultiSwarm) ClassificationAccuracy(buckets DataBuckets) (float32, error) {
+	nn, err := ms.predictNN()
+	if err != nil {
+		return 0, err
+	}
+	return nn.ClassificationAccuracy(buckets, -1)
 }
 
 //Predict x
-func (ms *MultiSwarm) Predict(inputs *t.Dense) *t.Dense {
-	output, _ := ms.predictNN().Activate(inputs)
-	return output
+func (ms *MultiSwarm) Predict(inputs *t.Dense) (*t.Dense, error) {
+	nn, err := ms.predictNN()
+	if err != nil {
+		return nil, err
+	}
+	output, _, err := nn.Activate(inputs)
+	return output, err
+}
+
+//diversity is the mean euclidean distance of the particles' weights from their centroid
+func (s *swarm) diversity() float32 {
+	if len(s.particles) < 2 {
+		return 0
+	}
+
+	centroid := make([]float32, s.particles[0].nn.weightsAndBiasesCount())
+	for _, p := range s.particles {
+		offset := 0
+		for _, l := range p.nn.Layers {
+			data := l.WeightsAndBiases.Data().([]float32)
+			for i, x := range data {
+				centroid[offset+i] += x
+			}
+			offset += len(data)
+		}
+	}
+	count := float32(len(s.particles))
+	for i := range centroid {
+		centroid[i] /= count
+	}
+
+	sum := float32(0)
+	for _, p := range s.particles {
+		offset, squared := 0, float32(0)
+		for _, l := range p.nn.Layers {
+			data := l.WeightsAndBiases.Data().([]float32)
+			for i, x := range data {
+				d := x - centroid[offset+i]
+				squared += d * d
+			}
+			offset += len(data)
+		}
+		sum += math.Sqrt(squared)
+	}
+	return sum / count
 }
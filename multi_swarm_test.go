@@ -1,11 +1,15 @@
 package cogent
 
 import (
+	"bytes"
 	"log"
+	"log/slog"
 	"math/rand"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -34,11 +38,16 @@ func basicMathConfig(data Data) MultiSwarmConfiguration {
 
 func basicMathTest(tt *testing.T, data Data) {
 	// tt.Parallel()
-	bucket := DataToTensorDataBucket(data, true)
-	buckets := DataBucketToBuckets(4, bucket)
-	s := NewMultiSwarm(basicMathConfig(data), DefaultTrainingConfig)
-	s.Train(buckets, false)
-	accuracy := s.ClassificationAccuracy(buckets)
+	bucket, err := DataToTensorDataBucket(data, true)
+	assert.Nil(tt, err)
+	buckets, err := DataBucketToBuckets(4, bucket)
+	assert.Nil(tt, err)
+	s, err := NewMultiSwarm(basicMathConfig(data), DefaultTrainingConfig)
+	assert.Nil(tt, err)
+	s.SetLogger(NopLogger)
+	assert.Nil(tt, s.Train(buckets, false))
+	accuracy, err := s.ClassificationAccuracy(buckets)
+	assert.Nil(tt, err)
 	assert.Equal(tt, 1.0, accuracy)
 }
 
@@ -145,8 +154,10 @@ func Test_Flowers(tt *testing.T) {
 		}
 		inputCount = len(data[0].Inputs)
 		outputCount = len(data[0].Outputs)
-		bucket := DataToTensorDataBucket(data, true)
-		buckets = DataBucketToBuckets(10, bucket)
+		bucket, err := DataToTensorDataBucket(data, true)
+		assert.Nil(tt, err)
+		buckets, err = DataBucketToBuckets(10, bucket)
+		assert.Nil(tt, err)
 	}
 
 	config := MultiSwarmConfiguration{
@@ -173,11 +184,14 @@ func Test_Flowers(tt *testing.T) {
 	tc.WeightRange = 10
 	tc.ProbablityOfDeath = 0.0001
 
-	s := NewMultiSwarm(config, tc)
+	s, err := NewMultiSwarm(config, tc)
+	assert.Nil(tt, err)
+	s.SetLogger(NopLogger)
 
 	start := time.Now()
-	s.Train(buckets, true)
-	accuracy := s.ClassificationAccuracy(buckets)
+	assert.Nil(tt, s.Train(buckets, true))
+	accuracy, err := s.ClassificationAccuracy(buckets)
+	assert.Nil(tt, err)
 	assert.Equal(tt, 1.0, accuracy)
 	log.Print(time.Since(start))
 }
@@ -258,7 +272,185 @@ func Test_Error(t *testing.T) {
 			expectedLoss := tt.loss[i]
 			actualLoss := lf(sample.expectedRow, sample.predictedRow)
 
-			assert.Equal(t, expectedLoss, actualLoss, string(tt.lm))
+			assert.Equal(t, expectedLoss, actualLoss, tt.lm.String())
 		}
 	}
 }
+
+func Test_TypedErrors(t *testing.T) {
+	data := Data{
+		{Inputs: []float32{0, 0}, Outputs: []float32{0, 1}},
+		{Inputs: []float32{1, 1}, Outputs: []float32{1, 0}},
+	}
+	config := basicMathConfig(data)
+	tc := DefaultTrainingConfig
+	// the first iteration only scores the starting positions
+	tc.MaxIterations = 3
+
+	invalid := []MultiSwarmConfiguration{config, config, config, config}
+	invalid[0].SwarmCount = 0
+	invalid[1].NeuralNetworkConfiguration.InputCount = 0
+	invalid[2].NeuralNetworkConfiguration.LayerConfigs = nil
+	invalid[3].NeuralNetworkConfiguration.Loss = LossMode(-1)
+	for i, c := range invalid {
+		_, err := NewMultiSwarm(c, tc)
+		assert.Equal(t, ErrInvalidConfig, errors.Cause(err), i)
+	}
+	badTraining := tc
+	badTraining.MaxIterations = 0
+	_, err := NewMultiSwarm(config, badTraining)
+	assert.Equal(t, ErrInvalidConfig, errors.Cause(err))
+
+	s, err := NewMultiSwarm(config, tc)
+	assert.Nil(t, err)
+	_, err = s.Best()
+	assert.Equal(t, ErrNoBestNetwork, errors.Cause(err))
+	_, err = s.ClassificationAccuracy(nil)
+	assert.Equal(t, ErrNoBestNetwork, errors.Cause(err))
+
+	_, err = DataToTensorDataBucket(nil, true)
+	assert.Equal(t, ErrShapeMismatch, errors.Cause(err))
+	_, err = DataToTensorDataBucket(append(data, DataRow{Inputs: []float32{1}, Outputs: []float32{1, 0}}), true)
+	assert.Equal(t, ErrShapeMismatch, errors.Cause(err))
+
+	// three inputs don't fit a network expecting two
+	wide, err := DataToTensorDataBucket(Data{{Inputs: []float32{0, 0, 0}, Outputs: []float32{0, 1}}}, true)
+	assert.Nil(t, err)
+	_, err = DataBucketToBuckets(0, wide)
+	assert.Equal(t, ErrInvalidConfig, errors.Cause(err))
+	buckets, err := DataBucketToBuckets(1, wide)
+	assert.Nil(t, err)
+	assert.Equal(t, ErrShapeMismatch, errors.Cause(s.Train(buckets, false)))
+
+	bucket, err := DataToTensorDataBucket(data, true)
+	assert.Nil(t, err)
+	buckets, err = DataBucketToBuckets(2, bucket)
+	assert.Nil(t, err)
+	assert.Nil(t, s.Train(buckets, false))
+	_, err = s.Predict(wide.Inputs)
+	assert.Equal(t, ErrShapeMismatch, errors.Cause(err))
+	predicted, err := s.Predict(bucket.Inputs)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{2, 2}, []int(predicted.Shape()))
+
+	nn, err := s.Best()
+	assert.Nil(t, err)
+	buf, err := nn.Marshal()
+	assert.Nil(t, err)
+	assert.Nil(t, (&NeuralNetwork{}).Unmarshal(buf))
+	assert.NotNil(t, (&NeuralNetwork{}).Unmarshal([]byte("nope")))
+}
+
+func Test_Validate(t *testing.T) {
+	data := Data{
+		{Inputs: []float32{0, 0}, Outputs: []float32{0, 1}},
+		{Inputs: []float32{1, 0}, Outputs: []float32{1, 0}},
+	}
+	bucket, err := DataToTensorDataBucket(data, true)
+	assert.Nil(t, err)
+	noBias, err := DataToTensorDataBucket(data, false)
+	assert.Nil(t, err)
+
+	fields := func(diagnostics []Diagnostic, severity DiagnosticSeverity) []string {
+		var fields []string
+		for _, d := range diagnostics {
+			if d.Severity == severity {
+				fields = append(fields, d.Field)
+			}
+		}
+		return fields
+	}
+
+	config := basicMathConfig(data)
+	diagnostics := config.Validate(bucket)
+	assert.Empty(t, fields(diagnostics, DiagnosticError))
+	assert.Nil(t, DiagnosticsError(diagnostics))
+	// the second input is always 0 and 32 weights fit 4 targets
+	assert.Equal(t, []string{"data", "LayerConfigs"}, fields(diagnostics, DiagnosticWarning))
+
+	diagnostics = config.Validate(noBias)
+	assert.Equal(t, []string{"InputCount"}, fields(diagnostics, DiagnosticError))
+	assert.Equal(t, ErrInvalidConfig, errors.Cause(DiagnosticsError(diagnostics)))
+
+	wide := basicMathConfig(data)
+	wide.NeuralNetworkConfiguration.InputCount = 3
+	wide.NeuralNetworkConfiguration.LayerConfigs[1].NodeCount = 3
+	assert.Equal(t, []string{"InputCount", "LayerConfigs[1].NodeCount"}, fields(wide.Validate(bucket), DiagnosticError))
+
+	split := basicMathConfig(data)
+	split.NeuralNetworkConfiguration.LayerConfigs[1] = LayerConfig{NodeCount: 3, Activation: SplitSoftmax}
+	assert.Equal(t, []string{"LayerConfigs[1].NodeCount"}, fields(split.Validate(nil), DiagnosticError))
+	_, err = NewMultiSwarm(split, DefaultTrainingConfig)
+	assert.Equal(t, ErrInvalidConfig, errors.Cause(err))
+
+	cross := basicMathConfig(data)
+	cross.NeuralNetworkConfiguration.LayerConfigs[1].Activation = ReLU
+	assert.Equal(t, []string{"Loss"}, fields(cross.Validate(nil), DiagnosticError))
+	cross.NeuralNetworkConfiguration.Loss = KullbackLeiblerDivergenceLoss
+	assert.Equal(t, []string{"Loss"}, fields(cross.Validate(nil), DiagnosticWarning))
+
+	hidden := basicMathConfig(data)
+	hidden.NeuralNetworkConfiguration.LayerConfigs[0].Activation = Softmax
+	multiLabel, err := DataToTensorDataBucket(Data{
+		{Inputs: []float32{0, 0}, Outputs: []float32{1, 1}},
+		{Inputs: []float32{1, 1}, Outputs: []float32{0.5, 0}},
+	}, true)
+	assert.Nil(t, err)
+	diagnostics = hidden.Validate(multiLabel)
+	assert.Empty(t, fields(diagnostics, DiagnosticError))
+	expected := []string{"LayerConfigs[0].Activation", "Loss", "LayerConfigs[1].Activation", "LayerConfigs"}
+	assert.Equal(t, expected, fields(diagnostics, DiagnosticWarning))
+}
+
+type recordingLogger struct {
+	mu       sync.Mutex
+	messages map[string][]interface{}
+}
+
+func (r *recordingLogger) record(msg string, args []interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages[msg] = args
+}
+
+func (r *recordingLogger) Debug(msg string, args ...interface{}) { r.record(msg, args) }
+func (r *recordingLogger) Info(msg string, args ...interface{})  { r.record(msg, args) }
+func (r *recordingLogger) Warn(msg string, args ...interface{})  { r.record(msg, args) }
+func (r *recordingLogger) Error(msg string, args ...interface{}) { r.record(msg, args) }
+
+func Test_Logger(t *testing.T) {
+	var _ Logger = slog.Default()
+
+	buf := &bytes.Buffer{}
+	l := NewLogger(buf, InfoLevel)
+	l.Debug("hidden")
+	l.Info("shown", SwarmKey, 1, LossKey, 0.5)
+	l.Warn("odd", "key")
+	assert.NotContains(t, buf.String(), "hidden")
+	assert.Contains(t, buf.String(), "INFO shown swarm=1 loss=0.5\n")
+	assert.Contains(t, buf.String(), "WARN odd !BADKEY=key\n")
+
+	data := Data{
+		{Inputs: []float32{0, 0}, Outputs: []float32{0, 1}},
+		{Inputs: []float32{1, 1}, Outputs: []float32{1, 0}},
+	}
+	bucket, err := DataToTensorDataBucket(data, true)
+	assert.Nil(t, err)
+	buckets, err := DataBucketToBuckets(2, bucket)
+	assert.Nil(t, err)
+	tc := DefaultTrainingConfig
+	tc.MaxIterations = 3
+	s, err := NewMultiSwarm(basicMathConfig(data), tc)
+	assert.Nil(t, err)
+
+	r := &recordingLogger{messages: map[string][]interface{}{}}
+	s.SetLogger(r)
+	assert.Nil(t, s.Train(buckets, true))
+	for _, msg := range []string{"training started", "iteration finished", "training finished", "local best", "global best"} {
+		assert.Contains(t, r.messages, msg)
+	}
+	assert.Equal(t, []interface{}{IterationKey, 2}, r.messages["iteration finished"][:2])
+	assert.Equal(t, SwarmKey, r.messages["global best"][0])
+	assert.Equal(t, ParticleKey, r.messages["global best"][2])
+	assert.Equal(t, IterationKey, r.messages["global best"][4])
+}
@@ -3,13 +3,11 @@ package cogent
 import (
 	"bytes"
 	"encoding/gob"
-	"log"
 	"math/rand"
-	"runtime"
 	"time"
 
 	math "github.com/chewxy/math32"
-	"github.com/davecgh/go-spew/spew"
+	"github.com/pkg/errors"
 
 	t "gorgonia.org/tensor"
 )
@@ -45,6 +43,7 @@ type NeuralNetwork struct {
 	Layers      []LayerData
 	CurrentLoss float32
 	Best        Position
+	Metadata    ModelMetadata
 }
 
 //LayerConfig x
@@ -85,6 +84,56 @@ func (l LayerData) Clone() LayerData {
 	}
 }
 
+//Clone deep copies the network so it can be used while the original keeps training
+func (nn *NeuralNetwork) Clone() *NeuralNetwork {
+	layers := make([]LayerData, len(nn.Layers))
+	for i, l := range nn.Layers {
+		layers[i] = l.Clone()
+	}
+
+	bestLayers := make([]LayerData, len(nn.Best.Layers))
+	for i, l := range nn.Best.Layers {
+		bestLayers[i] = l.Clone()
+	}
+
+	return &NeuralNetwork{
+		Loss:        nn.Loss,
+		Layers:      layers,
+		CurrentLoss: nn.CurrentLoss,
+		Best: Position{
+			Loss:   nn.Best.Loss,
+			Layers: bestLayers,
+		},
+		Metadata: ModelMetadata{
+			CreatedAt: nn.Metadata.CreatedAt,
+			Metrics:   nn.Metadata.Metrics,
+			Encodings: append([]EncodingMode(nil), nn.Metadata.Encodings...),
+			Columns:   append([]ColumnSpec(nil), nn.Metadata.Columns...),
+
+			InputEncoder:  nn.Metadata.InputEncoder,
+			OutputEncoder: nn.Metadata.OutputEncoder,
+		},
+	}
+}
+
+//Configuration describes the network's architecture, e.g. to build a MultiSwarm around an imported network
+func (nn *NeuralNetwork) Configuration() NeuralNetworkConfiguration {
+	nnc := NeuralNetworkConfiguration{
+		Loss:         nn.Loss,
+		LayerConfigs: make([]LayerConfig, len(nn.Layers)),
+	}
+	if len(nn.Layers) > 0 {
+		nnc.InputCount = nn.Layers[0].WeightsAndBiases.Shape()[0] - 1
+	}
+	for i, l := range nn.Layers {
+		nnc.LayerConfigs[i] = LayerConfig{
+			NodeCount:  l.NodeCount,
+			Activation: l.Activation,
+		}
+	}
+	return nnc
+}
+
 func (nn *NeuralNetwork) weightsAndBiasesCount() int {
 	count := 0
 	for _, l := range nn.Layers {
@@ -139,8 +188,20 @@ func resetBiasColumn(tt *t.Dense) {
 //Durations x
 type Durations []time.Duration
 
-//Activate feeds forward through the network
-func (nn *NeuralNetwork) Activate(initialInputs *t.Dense) (*t.Dense, Durations) {
+//Activate feeds forward through the network, inputs need the bias column
+func (nn *NeuralNetwork) Activate(initialInputs *t.Dense) (*t.Dense, Durations, error) {
+	if len(nn.Layers) == 0 {
+		return nil, nil, invalidConfig("network has no layers")
+	}
+	if initialInputs == nil {
+		return nil, nil, shapeMismatch("no inputs")
+	}
+	inputShape, weightsShape := initialInputs.Shape(), nn.Layers[0].WeightsAndBiases.Shape()
+	if len(inputShape) != 2 || inputShape[1] != weightsShape[0] {
+		msg := "inputs have shape %v, the network expects %d columns including the bias"
+		return nil, nil, shapeMismatch(msg, inputShape, weightsShape[0])
+	}
+
 	inputs := initialInputs
 	layerDurations := make(Durations, len(nn.Layers))
 	lastLayerIndex := len(nn.Layers) - 1
@@ -148,7 +209,10 @@ func (nn *NeuralNetwork) Activate(initialInputs *t.Dense) (*t.Dense, Durations)
 	for i, l := range nn.Layers {
 		start := time.Now()
 		// log.Printf("<Activate Layer %d>\nInput\n%+v\nLayer\n%+v", i, inputs, l.WeightsAndBiases)
-		outputs := must(inputs.MatMul(l.WeightsAndBiases))
+		outputs, err := inputs.MatMul(l.WeightsAndBiases)
+		if err != nil {
+			return nil, nil, errors.Wrapf(ErrShapeMismatch, "layer %d: %s", i, err)
+		}
 		activationFunc := activations[l.Activation]
 		activated = activationFunc(outputs)
 		// log.Printf("Outputs\n%+v\nActivated\n%+v", outputs, activated)
@@ -159,15 +223,13 @@ func (nn *NeuralNetwork) Activate(initialInputs *t.Dense) (*t.Dense, Durations)
 			inputs = activated
 		}
 	}
-	return activated, layerDurations
+	return activated, layerDurations, nil
 }
 
 //ClassificationAccuracy percentage correct using winner-takes all
-func (nn *NeuralNetwork) ClassificationAccuracy(buckets DataBuckets, testIndex int) float32 {
+func (nn *NeuralNetwork) ClassificationAccuracy(buckets DataBuckets, testIndex int) (float32, error) {
 	var correctCount, totalCount float32
 
-	layerCount := len(nn.Layers)
-	layerActivateAvg := make(Durations, layerCount)
 	for b := 0; b < len(buckets); b++ {
 		if testIndex >= 0 && b != testIndex {
 			continue
@@ -181,12 +243,15 @@ func (nn *NeuralNetwork) ClassificationAccuracy(buckets DataBuckets, testIndex i
 
 		expected := bucket.Outputs
 		expectedBacking := expected.Data().([]float32)
-		actual, durations := nn.Activate(bucket.Inputs)
+		actual, _, err := nn.Activate(bucket.Inputs)
+		if err != nil {
+			return 0, errors.Wrapf(err, "bucket %d", b)
+		}
+		if !actual.Shape().Eq(expected.Shape()) {
+			return 0, shapeMismatch("bucket %d outputs have shape %v, the network's %v", b, expected.Shape(), actual.Shape())
+		}
 		actualBacking := actual.Data().([]float32)
 		// log.Printf("Expected\n%+v\nActual\n%+v", expected, actual)
-		for i, d := range durations {
-			layerActivateAvg[i] += d
-		}
 
 		for i := 0; i < rowCount; i++ {
 			start := i * colCount
@@ -218,41 +283,29 @@ func (nn *NeuralNetwork) ClassificationAccuracy(buckets DataBuckets, testIndex i
 			totalCount++
 		}
 	}
-	for i := range layerActivateAvg {
-		layerActivateAvg[i] /= time.Duration(layerCount)
-	}
-	spew.Dump(layerActivateAvg)
 
 	ratio := correctCount / totalCount
-	return ratio
+	return ratio, nil
 }
 
 //Marshal x
-func (nn *NeuralNetwork) Marshal() []byte {
+func (nn *NeuralNetwork) Marshal() ([]byte, error) {
 	var buf bytes.Buffer
 	e := gob.NewEncoder(&buf)
-	err := e.Encode(nn)
-	if err != nil {
-		log.Fatal(err)
+	if err := e.Encode(nn); err != nil {
+		return nil, errors.Wrap(err, "can't marshal neural network")
 	}
-	return buf.Bytes()
+	return buf.Bytes(), nil
 }
 
 //Unmarshal x
-func (nn *NeuralNetwork) Unmarshal(buf []byte) {
+func (nn *NeuralNetwork) Unmarshal(buf []byte) error {
 	r := bytes.NewReader(buf)
 	d := gob.NewDecoder(r)
-	err := d.Decode(nn)
-	if err != nil {
-		log.Fatal(err)
-	}
-}
-
-func checkErr(err error) {
-	if err != nil {
-		log.Print(err)
-		runtime.Breakpoint()
+	if err := d.Decode(nn); err != nil {
+		return errors.Wrap(err, "can't unmarshal neural network")
 	}
+	return nil
 }
 
 func argmax(a []float32) int {
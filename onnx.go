@@ -0,0 +1,564 @@
+package cogent
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	math "github.com/chewxy/math32"
+	"github.com/pkg/errors"
+
+	t "gorgonia.org/tensor"
+)
+
+const (
+	onnxIRVersion      = 7
+	onnxOpsetVersion   = 13
+	onnxInputName      = "input"
+	onnxOutputName     = "output"
+	onnxActivationsKey = "cogent.activations"
+	onnxLossKey        = "cogent.loss"
+)
+
+//onnxRowActivations look at the whole row, so hidden layers keep their bias passthrough column
+//until after the activation to match Activate exactly
+var onnxRowActivations = map[ActivationMode]bool{
+	Softmax:      true,
+	SplitSoftmax: true,
+}
+
+//onnxActivationOps maps single ONNX operators to the activation they implement
+var onnxActivationOps = map[string]ActivationMode{
+	"Identity":  Identity,
+	"Sigmoid":   Sigmoid,
+	"Tanh":      HyperbolicTangent,
+	"Atan":      ArcTan,
+	"Softsign":  Softsign,
+	"Relu":      ReLU,
+	"LeakyRelu": LeakyReLU,
+	"Elu":       ELU,
+	"Selu":      SELU,
+	"Softplus":  SoftPlus,
+	"Sin":       Sinusoid,
+	"Softmax":   Softmax,
+}
+
+type onnxGraphBuilder struct {
+	graph onnxGraph
+}
+
+func (b *onnxGraphBuilder) add(opType, output string, attributes []onnxAttribute, inputs ...string) string {
+	b.graph.nodes = append(b.graph.nodes, onnxNode{
+		name:       output,
+		opType:     opType,
+		inputs:     inputs,
+		outputs:    []string{output},
+		attributes: attributes,
+	})
+	return output
+}
+
+func (b *onnxGraphBuilder) constant(name string, dims []int64, values ...float32) string {
+	b.graph.initializers = append(b.graph.initializers, onnxTensor{
+		name:     name,
+		dims:     dims,
+		dataType: onnxFloat,
+		floats:   values,
+	})
+	return name
+}
+
+func (b *onnxGraphBuilder) int64Constant(name string, values ...int64) string {
+	b.graph.initializers = append(b.graph.initializers, onnxTensor{
+		name:     name,
+		dims:     []int64{int64(len(values))},
+		dataType: onnxInt64,
+		ints:     values,
+	})
+	return name
+}
+
+func floatAttribute(name string, f float32) onnxAttribute {
+	return onnxAttribute{name: name, kind: onnxAttributeFloat, f: f}
+}
+
+func intAttribute(name string, i int64) onnxAttribute {
+	return onnxAttribute{name: name, kind: onnxAttributeInt, i: i}
+}
+
+//activation appends the nodes computing mode on x, a (batch x width) tensor
+func (b *onnxGraphBuilder) activation(mode ActivationMode, prefix, x string, width int) (string, error) {
+	name := func(suffix string) string {
+		return prefix + "." + suffix
+	}
+	out := name("activation")
+	unary := func(opType string, attributes ...onnxAttribute) (string, error) {
+		return b.add(opType, out, attributes, x), nil
+	}
+
+	switch mode {
+	case Identity, Maxout:
+		//Maxout only rewrites values equal to the max with the max, so it leaves the input untouched
+		return unary("Identity")
+	case BinaryStep:
+		zero := b.constant(name("zero"), nil, 0)
+		one := b.constant(name("one"), nil, 1)
+		positive := b.add("Greater", name("positive"), nil, x, zero)
+		return b.add("Where", out, nil, positive, one, x), nil
+	case Sigmoid:
+		return unary("Sigmoid")
+	case HyperbolicTangent:
+		return unary("Tanh")
+	case ArcTan:
+		return unary("Atan")
+	case Softsign:
+		return unary("Softsign")
+	case ISRU:
+		one := b.constant(name("one"), nil, 1)
+		squared := b.add("Mul", name("squared"), nil, x, x)
+		plusOne := b.add("Add", name("plus_one"), nil, squared, one)
+		root := b.add("Sqrt", name("root"), nil, plusOne)
+		return b.add("Div", out, nil, x, root), nil
+	case ReLU:
+		return unary("Relu")
+	case LeakyReLU:
+		return unary("LeakyRelu", floatAttribute("alpha", 0.01))
+	case ELU:
+		return unary("Elu", floatAttribute("alpha", 1))
+	case SELU:
+		return unary("Selu", floatAttribute("alpha", 1.67326), floatAttribute("gamma", 1.0507))
+	case SoftPlus:
+		return unary("Softplus")
+	case BentIdentity:
+		one := b.constant(name("one"), nil, 1)
+		half := b.constant(name("half"), nil, 0.5)
+		squared := b.add("Mul", name("squared"), nil, x, x)
+		plusOne := b.add("Add", name("plus_one"), nil, squared, one)
+		root := b.add("Sqrt", name("root"), nil, plusOne)
+		minusOne := b.add("Sub", name("minus_one"), nil, root, one)
+		halved := b.add("Mul", name("halved"), nil, minusOne, half)
+		return b.add("Add", out, nil, halved, x), nil
+	case Sinusoid:
+		return unary("Sin")
+	case Sinc:
+		zero := b.constant(name("zero"), nil, 0)
+		one := b.constant(name("one"), nil, 1)
+		isZero := b.add("Equal", name("is_zero"), nil, x, zero)
+		sin := b.add("Sin", name("sin"), nil, x)
+		ratio := b.add("Div", name("ratio"), nil, sin, x)
+		return b.add("Where", out, nil, isZero, one, ratio), nil
+	case Gaussian:
+		squared := b.add("Mul", name("squared"), nil, x, x)
+		negated := b.add("Neg", name("negated"), nil, squared)
+		return b.add("Exp", out, nil, negated), nil
+	case Softmax:
+		return unary("Softmax", intAttribute("axis", 1))
+	case SplitSoftmax:
+		if width < 2 {
+			return "", errors.Errorf("split softmax needs at least 2 outputs, %s has %d", prefix, width)
+		}
+		offset := int64(width / 2)
+		sizes := b.int64Constant(name("split_sizes"), offset, int64(width)-offset)
+		left, right := name("left"), name("right")
+		b.graph.nodes = append(b.graph.nodes, onnxNode{
+			name:       name("split"),
+			opType:     "Split",
+			inputs:     []string{x, sizes},
+			outputs:    []string{left, right},
+			attributes: []onnxAttribute{intAttribute("axis", 1)},
+		})
+		axis := []onnxAttribute{intAttribute("axis", 1)}
+		leftSoftmax := b.add("Softmax", name("left_softmax"), axis, left)
+		rightSoftmax := b.add("Softmax", name("right_softmax"), axis, right)
+		joined := b.add("Concat", name("joined"), axis, leftSoftmax, rightSoftmax)
+		return b.add("Softmax", out, axis, joined), nil
+	default:
+		return "", errors.Errorf("can't export activation '%s' to onnx", mode)
+	}
+}
+
+//ExportONNX writes the network as an ONNX model of MatMul, Add and activation operators.
+//The bias row of each layer becomes the Add operand and the bias passthrough column is dropped.
+func (nn *NeuralNetwork) ExportONNX(w io.Writer) error {
+	if len(nn.Layers) == 0 {
+		return errors.New("can't export network without layers")
+	}
+
+	b := &onnxGraphBuilder{
+		graph: onnxGraph{name: "cogent"},
+	}
+	inputCount := nn.Layers[0].WeightsAndBiases.Shape()[0] - 1
+	lastLayerIndex := len(nn.Layers) - 1
+	activationNames := make([]string, len(nn.Layers))
+
+	x := onnxInputName
+	width := inputCount
+	for i, l := range nn.Layers {
+		s := l.WeightsAndBiases.Shape()
+		rows, cols := s[0], s[1]
+		if rows != width+1 {
+			return errors.Errorf("layer %d has %d rows, expected %d", i, rows, width+1)
+		}
+		data := l.WeightsAndBiases.Data().([]float32)
+
+		isHidden := i != lastLayerIndex
+		keepPassthrough := isHidden && onnxRowActivations[l.Activation]
+		width = cols
+		if isHidden && !keepPassthrough {
+			width = cols - 1
+		}
+
+		weights := make([]float32, 0, (rows-1)*width)
+		for r := 0; r < rows-1; r++ {
+			offset := r * cols
+			weights = append(weights, data[offset:offset+width]...)
+		}
+		biasOffset := (rows - 1) * cols
+		bias := append([]float32(nil), data[biasOffset:biasOffset+width]...)
+
+		prefix := fmt.Sprintf("layer%d", i)
+		weightsName := b.constant(prefix+".weight", []int64{int64(rows - 1), int64(width)}, weights...)
+		biasName := b.constant(prefix+".bias", []int64{int64(width)}, bias...)
+		product := b.add("MatMul", prefix+".matmul", nil, x, weightsName)
+		linear := b.add("Add", prefix+".linear", nil, product, biasName)
+
+		activated, err := b.activation(l.Activation, prefix, linear, width)
+		if err != nil {
+			return errors.Wrapf(err, "can't export layer %d", i)
+		}
+
+		if keepPassthrough {
+			width = cols - 1
+			starts := b.int64Constant(prefix+".starts", 0)
+			ends := b.int64Constant(prefix+".ends", int64(width))
+			axes := b.int64Constant(prefix+".axes", 1)
+			activated = b.add("Slice", prefix+".output", nil, activated, starts, ends, axes)
+		}
+
+		x = activated
+		activationNames[i] = l.Activation.String()
+	}
+	b.add("Identity", onnxOutputName, nil, x)
+
+	b.graph.inputs = []onnxValueInfo{{
+		name:     onnxInputName,
+		elemType: onnxFloat,
+		dims:     []int64{-1, int64(inputCount)},
+	}}
+	b.graph.outputs = []onnxValueInfo{{
+		name:     onnxOutputName,
+		elemType: onnxFloat,
+		dims:     []int64{-1, int64(width)},
+	}}
+
+	model := onnxModel{
+		irVersion:    onnxIRVersion,
+		opsetVersion: onnxOpsetVersion,
+		producerName: "cogent",
+		graph:        b.graph,
+		metadata: map[string]string{
+			onnxActivationsKey: strings.Join(activationNames, ","),
+			onnxLossKey:        nn.Loss.String(),
+		},
+	}
+
+	_, err := w.Write(model.marshal())
+	return errors.Wrap(err, "can't write onnx model")
+}
+
+type onnxLayer struct {
+	in, width int
+	weights   []float32
+	bias      []float32
+	chain     []onnxNode
+}
+
+//ImportONNX reads a simple ONNX MLP, a chain of MatMul+Add or Gemm layers each followed by
+//an activation, into a network that can be fine-tuned with MultiSwarm.Seed
+func ImportONNX(r io.Reader) (*NeuralNetwork, error) {
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't read onnx model")
+	}
+
+	m, err := unmarshalONNXModel(buf)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't decode onnx model")
+	}
+	g := m.graph
+
+	initializers := map[string]onnxTensor{}
+	for _, t := range g.initializers {
+		initializers[t.name] = t
+	}
+
+	current := ""
+	for _, in := range g.inputs {
+		if _, ok := initializers[in.name]; !ok {
+			current = in.name
+			break
+		}
+	}
+	if current == "" {
+		return nil, errors.New("onnx graph has no input")
+	}
+
+	var layers []*onnxLayer
+	for _, n := range g.nodes {
+		var layer *onnxLayer
+		if len(layers) > 0 {
+			layer = layers[len(layers)-1]
+		}
+
+		switch {
+		case n.opType == "MatMul" || n.opType == "Gemm":
+			l, err := newONNXLayer(n, current, initializers)
+			if err != nil {
+				return nil, err
+			}
+			layers = append(layers, l)
+		case layer == nil:
+			return nil, errors.Errorf("unsupported onnx operator '%s' before the first layer", n.opType)
+		case n.opType == "Add" && layer.bias == nil && len(layer.chain) == 0:
+			bias, err := onnxBias(n, current, layer.width, initializers)
+			if err != nil {
+				return nil, err
+			}
+			layer.bias = bias
+		default:
+			layer.chain = append(layer.chain, n)
+		}
+
+		if len(n.outputs) > 0 {
+			current = n.outputs[0]
+		}
+	}
+	if len(layers) == 0 {
+		return nil, errors.New("onnx graph has no MatMul or Gemm layers")
+	}
+
+	var modes []ActivationMode
+	if names, ok := m.metadata[onnxActivationsKey]; ok {
+		for _, name := range strings.Split(names, ",") {
+			var mode ActivationMode
+			if err := mode.UnmarshalText([]byte(name)); err != nil {
+				return nil, err
+			}
+			modes = append(modes, mode)
+		}
+		if len(modes) != len(layers) {
+			msg := "onnx metadata has %d activations for %d layers"
+			return nil, errors.Errorf(msg, len(modes), len(layers))
+		}
+	}
+
+	nn := &NeuralNetwork{
+		Loss:        CrossLoss,
+		Layers:      make([]LayerData, len(layers)),
+		CurrentLoss: math.MaxFloat32,
+	}
+	if loss, ok := m.metadata[onnxLossKey]; ok {
+		if err := nn.Loss.UnmarshalText([]byte(loss)); err != nil {
+			return nil, err
+		}
+	}
+
+	lastLayerIndex := len(layers) - 1
+	expectedIn := layers[0].in
+	for i, l := range layers {
+		if l.in != expectedIn {
+			return nil, shapeMismatch("layer %d takes %d inputs, previous layer has %d", i, l.in, expectedIn)
+		}
+		if l.bias != nil && len(l.bias) != l.width {
+			return nil, shapeMismatch("layer %d has %d biases for %d outputs", i, len(l.bias), l.width)
+		}
+
+		var mode ActivationMode
+		if modes != nil {
+			mode = modes[i]
+		} else {
+			mode, err = onnxChainActivation(l.chain)
+			if err != nil {
+				return nil, errors.Wrapf(err, "can't import layer %d", i)
+			}
+		}
+
+		nodeCount, cols := l.width, l.width
+		if i != lastLayerIndex {
+			if onnxChainHasOp(l.chain, "Slice") {
+				nodeCount = l.width - 1
+			} else {
+				if onnxRowActivations[mode] {
+					return nil, errors.Errorf("can't import %s on hidden layer %d without a bias column", mode, i)
+				}
+				cols = l.width + 1
+			}
+		}
+		if nodeCount <= 0 {
+			return nil, shapeMismatch("layer %d has no nodes", i)
+		}
+
+		rows := l.in + 1
+		data := make([]float32, rows*cols)
+		for r := 0; r < l.in; r++ {
+			copy(data[r*cols:], l.weights[r*l.width:(r+1)*l.width])
+		}
+		copy(data[l.in*cols:], l.bias)
+
+		nn.Layers[i] = LayerData{
+			NodeCount:  nodeCount,
+			Activation: mode,
+			WeightsAndBiases: t.New(
+				t.Of(Float),
+				t.WithShape(rows, cols),
+				t.WithBacking(data),
+			),
+		}
+		expectedIn = nodeCount
+	}
+
+	nn.Best = nnToPosition(math.MaxFloat32, nn)
+	return nn, nil
+}
+
+func newONNXLayer(n onnxNode, current string, initializers map[string]onnxTensor) (*onnxLayer, error) {
+	if len(n.inputs) < 2 || n.inputs[0] != current {
+		return nil, errors.Errorf("%s node '%s' isn't fed by the previous layer", n.opType, n.name)
+	}
+
+	w, ok := initializers[n.inputs[1]]
+	if !ok || len(w.dims) != 2 {
+		return nil, errors.Errorf("%s node '%s' needs a 2d float initializer for its weights", n.opType, n.name)
+	}
+	// dims come from an untrusted file, checked one at a time so their product can't overflow
+	if w.dims[0] <= 0 || w.dims[1] <= 0 || w.dims[0] > maxModelLayerWeights || w.dims[1] > maxModelLayerWeights/w.dims[0] ||
+		int64(len(w.floats)) != w.dims[0]*w.dims[1] {
+		msg := "%s node '%s' has %d weights for dims %v"
+		return nil, shapeMismatch(msg, n.opType, n.name, len(w.floats), w.dims)
+	}
+
+	l := &onnxLayer{
+		in:      int(w.dims[0]),
+		width:   int(w.dims[1]),
+		weights: w.floats,
+	}
+	if n.opType == "MatMul" {
+		return l, nil
+	}
+
+	for _, name := range []string{"alpha", "beta"} {
+		if a, ok := n.attribute(name); ok && a.f != 1 {
+			return nil, errors.Errorf("gemm node '%s' has unsupported %s %f", n.name, name, a.f)
+		}
+	}
+	if a, ok := n.attribute("transA"); ok && a.i != 0 {
+		return nil, errors.Errorf("gemm node '%s' has unsupported transA", n.name)
+	}
+	if a, ok := n.attribute("transB"); ok && a.i != 0 {
+		l.in, l.width = l.width, l.in
+		transposed := make([]float32, len(w.floats))
+		for r := 0; r < l.width; r++ {
+			for c := 0; c < l.in; c++ {
+				transposed[c*l.width+r] = w.floats[r*l.in+c]
+			}
+		}
+		l.weights = transposed
+	}
+
+	if len(n.inputs) > 2 && n.inputs[2] != "" {
+		bias, err := onnxBias(onnxNode{name: n.name, inputs: []string{current, n.inputs[2]}}, current, l.width, initializers)
+		if err != nil {
+			return nil, err
+		}
+		l.bias = bias
+	}
+	return l, nil
+}
+
+func onnxBias(n onnxNode, current string, width int, initializers map[string]onnxTensor) ([]float32, error) {
+	for i, in := range n.inputs {
+		b, ok := initializers[in]
+		if !ok || len(n.inputs) != 2 || n.inputs[1-i] != current {
+			continue
+		}
+
+		switch len(b.floats) {
+		case width:
+			return b.floats, nil
+		case 1:
+			bias := make([]float32, width)
+			for j := range bias {
+				bias[j] = b.floats[0]
+			}
+			return bias, nil
+		}
+		return nil, shapeMismatch("bias '%s' has %d values for %d outputs", in, len(b.floats), width)
+	}
+	return nil, errors.Errorf("add node '%s' isn't a layer bias", n.name)
+}
+
+func onnxChainActivation(chain []onnxNode) (ActivationMode, error) {
+	var ops []onnxNode
+	for _, n := range chain {
+		if n.opType != "Identity" {
+			ops = append(ops, n)
+		}
+	}
+
+	switch len(ops) {
+	case 0:
+		return Identity, nil
+	case 1:
+	default:
+		return Identity, errors.Errorf("unsupported activation chain of %d operators", len(ops))
+	}
+
+	n := ops[0]
+	mode, ok := onnxActivationOps[n.opType]
+	if !ok {
+		return Identity, errors.Errorf("unsupported onnx operator '%s'", n.opType)
+	}
+
+	expectFloat := func(name string, expected float32) error {
+		if a, ok := n.attribute(name); ok && math.Abs(a.f-expected) > 1e-4 {
+			return errors.Errorf("%s with %s %f isn't supported, only %f", n.opType, name, a.f, expected)
+		}
+		return nil
+	}
+	switch mode {
+	case LeakyReLU:
+		return mode, expectFloat("alpha", 0.01)
+	case ELU:
+		return mode, expectFloat("alpha", 1)
+	case SELU:
+		if err := expectFloat("alpha", 1.67326); err != nil {
+			return mode, err
+		}
+		return mode, expectFloat("gamma", 1.0507)
+	case Softmax:
+		if a, ok := n.attribute("axis"); ok && a.i != 1 && a.i != -1 {
+			return mode, errors.Errorf("softmax over axis %d isn't supported", a.i)
+		}
+	}
+	return mode, nil
+}
+
+func onnxChainHasOp(chain []onnxNode, opType string) bool {
+	for _, n := range chain {
+		if n.opType == opType {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,624 @@
+package cogent
+
+//Minimal protobuf wire encoding for the subset of onnx.proto used by ExportONNX and ImportONNX.
+//Field numbers follow https://github.com/onnx/onnx/blob/master/onnx/onnx.proto
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+
+	onnxFloat = 1
+	onnxInt64 = 7
+
+	onnxAttributeFloat  = 1
+	onnxAttributeInt    = 2
+	onnxAttributeString = 3
+	onnxAttributeInts   = 7
+)
+
+type onnxTensor struct {
+	name     string
+	dims     []int64
+	dataType int32
+	floats   []float32
+	ints     []int64
+}
+
+type onnxAttribute struct {
+	name string
+	kind int32
+	f    float32
+	i    int64
+	s    string
+	ints []int64
+}
+
+type onnxNode struct {
+	name       string
+	opType     string
+	inputs     []string
+	outputs    []string
+	attributes []onnxAttribute
+}
+
+type onnxValueInfo struct {
+	name     string
+	elemType int32
+	//dims of -1 are symbolic, e.g. the batch size
+	dims []int64
+}
+
+type onnxGraph struct {
+	name         string
+	nodes        []onnxNode
+	initializers []onnxTensor
+	inputs       []onnxValueInfo
+	outputs      []onnxValueInfo
+}
+
+type onnxModel struct {
+	irVersion       int64
+	opsetVersion    int64
+	producerName    string
+	producerVersion string
+	graph           onnxGraph
+	metadata        map[string]string
+}
+
+func (n *onnxNode) attribute(name string) (onnxAttribute, bool) {
+	for _, a := range n.attributes {
+		if a.name == name {
+			return a, true
+		}
+	}
+	return onnxAttribute{}, false
+}
+
+type protoWriter struct {
+	buf []byte
+}
+
+func (w *protoWriter) varint(v uint64) {
+	for v >= 0x80 {
+		w.buf = append(w.buf, byte(v)|0x80)
+		v >>= 7
+	}
+	w.buf = append(w.buf, byte(v))
+}
+
+func (w *protoWriter) tag(field, wireType int) {
+	w.varint(uint64(field<<3 | wireType))
+}
+
+func (w *protoWriter) int64Field(field int, v int64) {
+	w.tag(field, protoVarint)
+	w.varint(uint64(v))
+}
+
+func (w *protoWriter) float32Field(field int, f float32) {
+	w.tag(field, protoFixed32)
+	w.buf = append(w.buf, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(w.buf[len(w.buf)-4:], math.Float32bits(f))
+}
+
+func (w *protoWriter) bytesField(field int, b []byte) {
+	w.tag(field, protoBytes)
+	w.varint(uint64(len(b)))
+	w.buf = append(w.buf, b...)
+}
+
+func (w *protoWriter) stringField(field int, s string) {
+	w.bytesField(field, []byte(s))
+}
+
+func (w *protoWriter) packedInt64sField(field int, vs []int64) {
+	packed := protoWriter{}
+	for _, v := range vs {
+		packed.varint(uint64(v))
+	}
+	w.bytesField(field, packed.buf)
+}
+
+func (t *onnxTensor) marshal() []byte {
+	w := protoWriter{}
+	w.packedInt64sField(1, t.dims)
+	w.int64Field(2, int64(t.dataType))
+	w.stringField(8, t.name)
+
+	var raw []byte
+	switch t.dataType {
+	case onnxFloat:
+		raw = make([]byte, 4*len(t.floats))
+		for i, f := range t.floats {
+			binary.LittleEndian.PutUint32(raw[4*i:], math.Float32bits(f))
+		}
+	case onnxInt64:
+		raw = make([]byte, 8*len(t.ints))
+		for i, v := range t.ints {
+			binary.LittleEndian.PutUint64(raw[8*i:], uint64(v))
+		}
+	}
+	w.bytesField(9, raw)
+	return w.buf
+}
+
+func (a *onnxAttribute) marshal() []byte {
+	w := protoWriter{}
+	w.stringField(1, a.name)
+	switch a.kind {
+	case onnxAttributeFloat:
+		w.float32Field(2, a.f)
+	case onnxAttributeInt:
+		w.int64Field(3, a.i)
+	case onnxAttributeString:
+		w.stringField(4, a.s)
+	case onnxAttributeInts:
+		w.packedInt64sField(8, a.ints)
+	}
+	w.int64Field(20, int64(a.kind))
+	return w.buf
+}
+
+func (n *onnxNode) marshal() []byte {
+	w := protoWriter{}
+	for _, in := range n.inputs {
+		w.stringField(1, in)
+	}
+	for _, out := range n.outputs {
+		w.stringField(2, out)
+	}
+	w.stringField(3, n.name)
+	w.stringField(4, n.opType)
+	for _, a := range n.attributes {
+		w.bytesField(5, a.marshal())
+	}
+	return w.buf
+}
+
+func (v *onnxValueInfo) marshal() []byte {
+	shape := protoWriter{}
+	for _, d := range v.dims {
+		dim := protoWriter{}
+		if d < 0 {
+			dim.stringField(2, "batch")
+		} else {
+			dim.int64Field(1, d)
+		}
+		shape.bytesField(1, dim.buf)
+	}
+
+	tensorType := protoWriter{}
+	tensorType.int64Field(1, int64(v.elemType))
+	tensorType.bytesField(2, shape.buf)
+
+	typeProto := protoWriter{}
+	typeProto.bytesField(1, tensorType.buf)
+
+	w := protoWriter{}
+	w.stringField(1, v.name)
+	w.bytesField(2, typeProto.buf)
+	return w.buf
+}
+
+func (g *onnxGraph) marshal() []byte {
+	w := protoWriter{}
+	for _, n := range g.nodes {
+		w.bytesField(1, n.marshal())
+	}
+	w.stringField(2, g.name)
+	for _, t := range g.initializers {
+		w.bytesField(5, t.marshal())
+	}
+	for _, in := range g.inputs {
+		w.bytesField(11, in.marshal())
+	}
+	for _, out := range g.outputs {
+		w.bytesField(12, out.marshal())
+	}
+	return w.buf
+}
+
+func (m *onnxModel) marshal() []byte {
+	w := protoWriter{}
+	w.int64Field(1, m.irVersion)
+	w.stringField(2, m.producerName)
+	w.stringField(3, m.producerVersion)
+	w.bytesField(7, m.graph.marshal())
+
+	opset := protoWriter{}
+	opset.stringField(1, "")
+	opset.int64Field(2, m.opsetVersion)
+	w.bytesField(8, opset.buf)
+
+	for _, k := range sortedKeys(m.metadata) {
+		entry := protoWriter{}
+		entry.stringField(1, k)
+		entry.stringField(2, m.metadata[k])
+		w.bytesField(14, entry.buf)
+	}
+	return w.buf
+}
+
+type protoField struct {
+	number   int
+	wireType int
+	varint   uint64
+	fixed    uint64
+	bytes    []byte
+}
+
+func readVarint(b []byte) (uint64, int, error) {
+	var v uint64
+	for i := 0; i < len(b) && i < 10; i++ {
+		v |= uint64(b[i]&0x7f) << (7 * uint(i))
+		if b[i] < 0x80 {
+			return v, i + 1, nil
+		}
+	}
+	return 0, 0, errors.New("malformed protobuf varint")
+}
+
+func readProtoFields(b []byte) ([]protoField, error) {
+	var fields []protoField
+	for len(b) > 0 {
+		key, n, err := readVarint(b)
+		if err != nil {
+			return nil, err
+		}
+		b = b[n:]
+
+		f := protoField{
+			number:   int(key >> 3),
+			wireType: int(key & 7),
+		}
+		switch f.wireType {
+		case protoVarint:
+			f.varint, n, err = readVarint(b)
+			if err != nil {
+				return nil, err
+			}
+		case protoFixed64:
+			if len(b) < 8 {
+				return nil, errors.New("truncated protobuf fixed64")
+			}
+			f.fixed, n = binary.LittleEndian.Uint64(b), 8
+		case protoFixed32:
+			if len(b) < 4 {
+				return nil, errors.New("truncated protobuf fixed32")
+			}
+			f.fixed, n = uint64(binary.LittleEndian.Uint32(b)), 4
+		case protoBytes:
+			length, ln, err := readVarint(b)
+			if err != nil {
+				return nil, err
+			}
+			if uint64(len(b)-ln) < length {
+				return nil, errors.New("truncated protobuf bytes")
+			}
+			f.bytes = b[ln : ln+int(length)]
+			n = ln + int(length)
+		default:
+			return nil, errors.Errorf("unsupported protobuf wire type %d", f.wireType)
+		}
+		b = b[n:]
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+//int64s reads a repeated int64 field that may be packed or not
+func (f *protoField) int64s() ([]int64, error) {
+	if f.wireType == protoVarint {
+		return []int64{int64(f.varint)}, nil
+	}
+
+	var vs []int64
+	b := f.bytes
+	for len(b) > 0 {
+		v, n, err := readVarint(b)
+		if err != nil {
+			return nil, err
+		}
+		vs = append(vs, int64(v))
+		b = b[n:]
+	}
+	return vs, nil
+}
+
+//float32s reads a repeated float field that may be packed or not
+func (f *protoField) float32s() []float32 {
+	if f.wireType == protoFixed32 {
+		return []float32{math.Float32frombits(uint32(f.fixed))}
+	}
+
+	vs := make([]float32, len(f.bytes)/4)
+	for i := range vs {
+		vs[i] = math.Float32frombits(binary.LittleEndian.Uint32(f.bytes[4*i:]))
+	}
+	return vs
+}
+
+func unmarshalONNXTensor(b []byte) (onnxTensor, error) {
+	t := onnxTensor{}
+	fields, err := readProtoFields(b)
+	if err != nil {
+		return t, err
+	}
+
+	var raw []byte
+	for _, f := range fields {
+		switch f.number {
+		case 1:
+			dims, err := f.int64s()
+			if err != nil {
+				return t, err
+			}
+			t.dims = append(t.dims, dims...)
+		case 2:
+			t.dataType = int32(f.varint)
+		case 4:
+			t.floats = append(t.floats, f.float32s()...)
+		case 7:
+			ints, err := f.int64s()
+			if err != nil {
+				return t, err
+			}
+			t.ints = append(t.ints, ints...)
+		case 8:
+			t.name = string(f.bytes)
+		case 9:
+			raw = f.bytes
+		}
+	}
+
+	if raw != nil {
+		switch t.dataType {
+		case onnxFloat:
+			t.floats = make([]float32, len(raw)/4)
+			for i := range t.floats {
+				t.floats[i] = math.Float32frombits(binary.LittleEndian.Uint32(raw[4*i:]))
+			}
+		case onnxInt64:
+			t.ints = make([]int64, len(raw)/8)
+			for i := range t.ints {
+				t.ints[i] = int64(binary.LittleEndian.Uint64(raw[8*i:]))
+			}
+		default:
+			return t, errors.Errorf("unsupported tensor data type %d for '%s'", t.dataType, t.name)
+		}
+	}
+	return t, nil
+}
+
+func unmarshalONNXAttribute(b []byte) (onnxAttribute, error) {
+	a := onnxAttribute{}
+	fields, err := readProtoFields(b)
+	if err != nil {
+		return a, err
+	}
+
+	for _, f := range fields {
+		switch f.number {
+		case 1:
+			a.name = string(f.bytes)
+		case 2:
+			a.f = math.Float32frombits(uint32(f.fixed))
+		case 3:
+			a.i = int64(f.varint)
+		case 4:
+			a.s = string(f.bytes)
+		case 8:
+			ints, err := f.int64s()
+			if err != nil {
+				return a, err
+			}
+			a.ints = append(a.ints, ints...)
+		case 20:
+			a.kind = int32(f.varint)
+		}
+	}
+	return a, nil
+}
+
+func unmarshalONNXNode(b []byte) (onnxNode, error) {
+	n := onnxNode{}
+	fields, err := readProtoFields(b)
+	if err != nil {
+		return n, err
+	}
+
+	for _, f := range fields {
+		switch f.number {
+		case 1:
+			n.inputs = append(n.inputs, string(f.bytes))
+		case 2:
+			n.outputs = append(n.outputs, string(f.bytes))
+		case 3:
+			n.name = string(f.bytes)
+		case 4:
+			n.opType = string(f.bytes)
+		case 5:
+			a, err := unmarshalONNXAttribute(f.bytes)
+			if err != nil {
+				return n, errors.Wrapf(err, "can't read attribute of node '%s'", n.name)
+			}
+			n.attributes = append(n.attributes, a)
+		}
+	}
+	return n, nil
+}
+
+func unmarshalONNXValueInfo(b []byte) (onnxValueInfo, error) {
+	v := onnxValueInfo{}
+	fields, err := readProtoFields(b)
+	if err != nil {
+		return v, err
+	}
+
+	for _, f := range fields {
+		switch f.number {
+		case 1:
+			v.name = string(f.bytes)
+		case 2:
+			typeFields, err := readProtoFields(f.bytes)
+			if err != nil {
+				return v, err
+			}
+			for _, tf := range typeFields {
+				if tf.number != 1 {
+					continue
+				}
+				tensorFields, err := readProtoFields(tf.bytes)
+				if err != nil {
+					return v, err
+				}
+				for _, tt := range tensorFields {
+					switch tt.number {
+					case 1:
+						v.elemType = int32(tt.varint)
+					case 2:
+						dims, err := unmarshalONNXShape(tt.bytes)
+						if err != nil {
+							return v, err
+						}
+						v.dims = dims
+					}
+				}
+			}
+		}
+	}
+	return v, nil
+}
+
+func unmarshalONNXShape(b []byte) ([]int64, error) {
+	fields, err := readProtoFields(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var dims []int64
+	for _, f := range fields {
+		if f.number != 1 {
+			continue
+		}
+		dimFields, err := readProtoFields(f.bytes)
+		if err != nil {
+			return nil, err
+		}
+		d := int64(-1)
+		for _, df := range dimFields {
+			if df.number == 1 {
+				d = int64(df.varint)
+			}
+		}
+		dims = append(dims, d)
+	}
+	return dims, nil
+}
+
+func unmarshalONNXGraph(b []byte) (onnxGraph, error) {
+	g := onnxGraph{}
+	fields, err := readProtoFields(b)
+	if err != nil {
+		return g, err
+	}
+
+	for _, f := range fields {
+		switch f.number {
+		case 1:
+			n, err := unmarshalONNXNode(f.bytes)
+			if err != nil {
+				return g, err
+			}
+			g.nodes = append(g.nodes, n)
+		case 2:
+			g.name = string(f.bytes)
+		case 5:
+			t, err := unmarshalONNXTensor(f.bytes)
+			if err != nil {
+				return g, err
+			}
+			g.initializers = append(g.initializers, t)
+		case 11, 12:
+			v, err := unmarshalONNXValueInfo(f.bytes)
+			if err != nil {
+				return g, err
+			}
+			if f.number == 11 {
+				g.inputs = append(g.inputs, v)
+			} else {
+				g.outputs = append(g.outputs, v)
+			}
+		}
+	}
+	return g, nil
+}
+
+func unmarshalONNXModel(b []byte) (*onnxModel, error) {
+	m := &onnxModel{
+		metadata: map[string]string{},
+	}
+	fields, err := readProtoFields(b)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range fields {
+		switch f.number {
+		case 1:
+			m.irVersion = int64(f.varint)
+		case 2:
+			m.producerName = string(f.bytes)
+		case 3:
+			m.producerVersion = string(f.bytes)
+		case 7:
+			g, err := unmarshalONNXGraph(f.bytes)
+			if err != nil {
+				return nil, errors.Wrap(err, "can't read graph")
+			}
+			m.graph = g
+		case 8:
+			opsetFields, err := readProtoFields(f.bytes)
+			if err != nil {
+				return nil, err
+			}
+			domain := ""
+			var version int64
+			for _, of := range opsetFields {
+				switch of.number {
+				case 1:
+					domain = string(of.bytes)
+				case 2:
+					version = int64(of.varint)
+				}
+			}
+			if domain == "" || domain == "ai.onnx" {
+				m.opsetVersion = version
+			}
+		case 14:
+			entryFields, err := readProtoFields(f.bytes)
+			if err != nil {
+				return nil, err
+			}
+			var k, v string
+			for _, ef := range entryFields {
+				switch ef.number {
+				case 1:
+					k = string(ef.bytes)
+				case 2:
+					v = string(ef.bytes)
+				}
+			}
+			m.metadata[k] = v
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,384 @@
+package cogent
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	math "github.com/chewxy/math32"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+//onnxValue is a row major matrix, scalars and vectors broadcast across rows
+type onnxValue struct {
+	rows, cols int
+	data       []float32
+}
+
+func (v onnxValue) at(r, c int) float32 {
+	if v.rows == 1 {
+		r = 0
+	}
+	if v.cols == 1 {
+		c = 0
+	}
+	return v.data[r*v.cols+c]
+}
+
+func onnxMap(v onnxValue, fn func(x float32) float32) onnxValue {
+	out := onnxValue{v.rows, v.cols, make([]float32, len(v.data))}
+	for i, x := range v.data {
+		out.data[i] = fn(x)
+	}
+	return out
+}
+
+func onnxZip(a, b onnxValue, fn func(x, y float32) float32) onnxValue {
+	rows, cols := a.rows, a.cols
+	if b.rows > rows {
+		rows = b.rows
+	}
+	if b.cols > cols {
+		cols = b.cols
+	}
+	out := onnxValue{rows, cols, make([]float32, rows*cols)}
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			out.data[r*cols+c] = fn(a.at(r, c), b.at(r, c))
+		}
+	}
+	return out
+}
+
+func onnxSoftmax(v onnxValue) onnxValue {
+	out := onnxValue{v.rows, v.cols, append([]float32(nil), v.data...)}
+	for r := 0; r < v.rows; r++ {
+		softmaxModifyRow(out.data[r*v.cols : (r+1)*v.cols])
+	}
+	return out
+}
+
+func onnxColumns(v onnxValue, start, end int) onnxValue {
+	out := onnxValue{v.rows, end - start, nil}
+	for r := 0; r < v.rows; r++ {
+		out.data = append(out.data, v.data[r*v.cols+start:r*v.cols+end]...)
+	}
+	return out
+}
+
+//evaluateONNX runs the handful of operators ExportONNX emits
+func evaluateONNX(t *testing.T, m *onnxModel, input onnxValue) onnxValue {
+	values := map[string]onnxValue{
+		m.graph.inputs[0].name: input,
+	}
+	ints := map[string][]int64{}
+	for _, init := range m.graph.initializers {
+		if init.dataType == onnxInt64 {
+			ints[init.name] = init.ints
+			continue
+		}
+		v := onnxValue{1, 1, init.floats}
+		switch len(init.dims) {
+		case 1:
+			v.cols = int(init.dims[0])
+		case 2:
+			v.rows, v.cols = int(init.dims[0]), int(init.dims[1])
+		}
+		values[init.name] = v
+	}
+
+	for _, n := range m.graph.nodes {
+		in := func(i int) onnxValue {
+			v, ok := values[n.inputs[i]]
+			if !ok {
+				t.Fatalf("missing value '%s' for node '%s'", n.inputs[i], n.name)
+			}
+			return v
+		}
+		boolean := func(b bool) float32 {
+			if b {
+				return 1
+			}
+			return 0
+		}
+
+		var out onnxValue
+		switch n.opType {
+		case "MatMul":
+			a, b := in(0), in(1)
+			out = onnxValue{a.rows, b.cols, make([]float32, a.rows*b.cols)}
+			for r := 0; r < a.rows; r++ {
+				for c := 0; c < b.cols; c++ {
+					var sum float32
+					for k := 0; k < a.cols; k++ {
+						sum += a.at(r, k) * b.at(k, c)
+					}
+					out.data[r*b.cols+c] = sum
+				}
+			}
+		case "Gemm":
+			a, b, c := in(0), in(1), in(2)
+			transposed := onnxValue{b.cols, b.rows, make([]float32, len(b.data))}
+			for r := 0; r < b.rows; r++ {
+				for col := 0; col < b.cols; col++ {
+					transposed.data[col*b.rows+r] = b.at(r, col)
+				}
+			}
+			product := onnxValue{a.rows, transposed.cols, make([]float32, a.rows*transposed.cols)}
+			for r := 0; r < a.rows; r++ {
+				for col := 0; col < transposed.cols; col++ {
+					for k := 0; k < a.cols; k++ {
+						product.data[r*transposed.cols+col] += a.at(r, k) * transposed.at(k, col)
+					}
+				}
+			}
+			out = onnxZip(product, c, func(x, y float32) float32 { return x + y })
+		case "Add":
+			out = onnxZip(in(0), in(1), func(x, y float32) float32 { return x + y })
+		case "Sub":
+			out = onnxZip(in(0), in(1), func(x, y float32) float32 { return x - y })
+		case "Mul":
+			out = onnxZip(in(0), in(1), func(x, y float32) float32 { return x * y })
+		case "Div":
+			out = onnxZip(in(0), in(1), func(x, y float32) float32 { return x / y })
+		case "Greater":
+			out = onnxZip(in(0), in(1), func(x, y float32) float32 { return boolean(x > y) })
+		case "Equal":
+			out = onnxZip(in(0), in(1), func(x, y float32) float32 { return boolean(x == y) })
+		case "Where":
+			cond := in(0)
+			chosen := onnxZip(in(1), in(2), func(x, y float32) float32 { return 0 })
+			for r := 0; r < chosen.rows; r++ {
+				for c := 0; c < chosen.cols; c++ {
+					if cond.at(r, c) != 0 {
+						chosen.data[r*chosen.cols+c] = in(1).at(r, c)
+					} else {
+						chosen.data[r*chosen.cols+c] = in(2).at(r, c)
+					}
+				}
+			}
+			out = chosen
+		case "Identity":
+			out = in(0)
+		case "Neg":
+			out = onnxMap(in(0), func(x float32) float32 { return -x })
+		case "Exp":
+			out = onnxMap(in(0), math.Exp)
+		case "Sqrt":
+			out = onnxMap(in(0), math.Sqrt)
+		case "Sin":
+			out = onnxMap(in(0), math.Sin)
+		case "Atan":
+			out = onnxMap(in(0), math.Atan)
+		case "Tanh":
+			out = onnxMap(in(0), math.Tanh)
+		case "Sigmoid":
+			out = onnxMap(in(0), func(x float32) float32 { return 1 / (1 + math.Exp(-x)) })
+		case "Softsign":
+			out = onnxMap(in(0), func(x float32) float32 { return x / (1 + math.Abs(x)) })
+		case "Softplus":
+			out = onnxMap(in(0), func(x float32) float32 { return math.Log(1 + math.Exp(x)) })
+		case "Relu":
+			out = onnxMap(in(0), func(x float32) float32 { return math.Max(0, x) })
+		case "LeakyRelu":
+			alpha, _ := n.attribute("alpha")
+			out = onnxMap(in(0), func(x float32) float32 {
+				if x < 0 {
+					return alpha.f * x
+				}
+				return x
+			})
+		case "Elu":
+			alpha, _ := n.attribute("alpha")
+			out = onnxMap(in(0), func(x float32) float32 {
+				if x < 0 {
+					return alpha.f * (math.Exp(x) - 1)
+				}
+				return x
+			})
+		case "Selu":
+			alpha, _ := n.attribute("alpha")
+			gamma, _ := n.attribute("gamma")
+			out = onnxMap(in(0), func(x float32) float32 {
+				if x < 0 {
+					return gamma.f * alpha.f * (math.Exp(x) - 1)
+				}
+				return gamma.f * x
+			})
+		case "Softmax":
+			out = onnxSoftmax(in(0))
+		case "Split":
+			x, sizes := in(0), ints[n.inputs[1]]
+			offset := 0
+			for i, size := range sizes {
+				values[n.outputs[i]] = onnxColumns(x, offset, offset+int(size))
+				offset += int(size)
+			}
+			continue
+		case "Concat":
+			a, b := in(0), in(1)
+			out = onnxValue{a.rows, a.cols + b.cols, nil}
+			for r := 0; r < a.rows; r++ {
+				out.data = append(out.data, a.data[r*a.cols:(r+1)*a.cols]...)
+				out.data = append(out.data, b.data[r*b.cols:(r+1)*b.cols]...)
+			}
+		case "Slice":
+			start, end := ints[n.inputs[1]][0], ints[n.inputs[2]][0]
+			out = onnxColumns(in(0), int(start), int(end))
+		default:
+			t.Fatalf("unsupported operator '%s'", n.opType)
+		}
+		values[n.outputs[0]] = out
+	}
+	return values[m.graph.outputs[0].name]
+}
+
+func Test_ONNXRoundTrip(t *testing.T) {
+	data := Data{
+		{Inputs: []float32{0, 0, 1}, Outputs: []float32{1, 0, 0, 1}},
+		{Inputs: []float32{0.5, -1, 0}, Outputs: []float32{0, 1, 1, 0}},
+		{Inputs: []float32{-2, 0.25, 3}, Outputs: []float32{1, 0, 1, 0}},
+	}
+	bucket, err := DataToTensorDataBucket(data, true)
+	assert.Nil(t, err)
+	input := onnxValue{len(data), 3, nil}
+	for _, row := range data {
+		input.data = append(input.data, row.Inputs...)
+	}
+
+	for mode := range activations {
+		for _, final := range []ActivationMode{Softmax, SplitSoftmax, mode} {
+			name := fmt.Sprintf("%s->%s", mode, final)
+			nn := testNeuralNetwork(3,
+				LayerConfig{NodeCount: 5, Activation: mode},
+				LayerConfig{NodeCount: 4, Activation: final},
+			)
+			// put a zero in front of the activations to exercise Sinc and BinaryStep edges
+			nn.Layers[0].WeightsAndBiases.Data().([]float32)[0] = 0
+			expected, _, err := nn.Activate(bucket.Inputs)
+			assert.Nil(t, err)
+
+			buf := bytes.Buffer{}
+			assert.Nil(t, nn.ExportONNX(&buf), name)
+
+			m, err := unmarshalONNXModel(buf.Bytes())
+			assert.Nil(t, err, name)
+			assert.Equal(t, int64(onnxOpsetVersion), m.opsetVersion)
+			assert.Equal(t, []int64{-1, 3}, m.graph.inputs[0].dims)
+			evaluated := evaluateONNX(t, m, input)
+			assert.InDeltaSlice(t, expected.Data(), evaluated.data, 1e-4, name)
+
+			imported, err := ImportONNX(bytes.NewReader(buf.Bytes()))
+			assert.Nil(t, err, name)
+			assert.Equal(t, nn.Loss, imported.Loss)
+			assert.Equal(t, nn.Configuration(), imported.Configuration(), name)
+			actual, _, err := imported.Activate(bucket.Inputs)
+			assert.Nil(t, err)
+			assert.InDeltaSlice(t, expected.Data(), actual.Data(), 1e-6, name)
+		}
+	}
+}
+
+func Test_ONNXImportForeign(t *testing.T) {
+	// 2 inputs -> Gemm(transB) 3 relu -> MatMul+Add 2 softmax, like a torch.nn.Linear export
+	b := onnxGraphBuilder{graph: onnxGraph{name: "torch"}}
+	b.constant("fc1.weight", []int64{3, 2}, 1, -1, 2, 0.5, -1, 1)
+	b.constant("fc1.bias", []int64{3}, 0.1, 0.2, 0.3)
+	b.constant("fc2.weight", []int64{3, 2}, 1, 0, 0, 1, 1, 1)
+	b.constant("fc2.bias", []int64{1}, -0.5)
+	b.graph.nodes = append(b.graph.nodes, onnxNode{
+		name:       "gemm",
+		opType:     "Gemm",
+		inputs:     []string{"x", "fc1.weight", "fc1.bias"},
+		outputs:    []string{"h"},
+		attributes: []onnxAttribute{intAttribute("transB", 1), floatAttribute("alpha", 1)},
+	})
+	b.add("Relu", "h_relu", nil, "h")
+	b.add("MatMul", "o", nil, "h_relu", "fc2.weight")
+	b.add("Add", "o_bias", nil, "o", "fc2.bias")
+	b.add("Softmax", "y", []onnxAttribute{intAttribute("axis", -1)}, "o_bias")
+	b.graph.inputs = []onnxValueInfo{{name: "x", elemType: onnxFloat, dims: []int64{-1, 2}}}
+	b.graph.outputs = []onnxValueInfo{{name: "y", elemType: onnxFloat, dims: []int64{-1, 2}}}
+	m := onnxModel{irVersion: 6, opsetVersion: 11, graph: b.graph}
+
+	nn, err := ImportONNX(bytes.NewReader(m.marshal()))
+	assert.Nil(t, err)
+	assert.Equal(t, NeuralNetworkConfiguration{
+		Loss:       CrossLoss,
+		InputCount: 2,
+		LayerConfigs: []LayerConfig{
+			{NodeCount: 3, Activation: ReLU},
+			{NodeCount: 2, Activation: Softmax},
+		},
+	}, nn.Configuration())
+
+	input := onnxValue{2, 2, []float32{1, 2, -1, 0.5}}
+	expected := evaluateONNX(t, &m, input)
+	bucket, err := DataToTensorDataBucket(Data{
+		{Inputs: []float32{1, 2}, Outputs: []float32{0, 0}},
+		{Inputs: []float32{-1, 0.5}, Outputs: []float32{0, 0}},
+	}, true)
+	assert.Nil(t, err)
+	actual, _, err := nn.Activate(bucket.Inputs)
+	assert.Nil(t, err)
+	assert.InDeltaSlice(t, expected.data, actual.Data(), 1e-6)
+
+	// imported networks can seed a swarm for fine tuning
+	ms, err := NewMultiSwarm(MultiSwarmConfiguration{
+		NeuralNetworkConfiguration: nn.Configuration(),
+		SwarmCount:                 2,
+		ParticleCount:              2,
+	}, DefaultTrainingConfig)
+	assert.Nil(t, err)
+	assert.Nil(t, ms.Seed(nn))
+	for _, s := range ms.swarms {
+		for _, p := range s.particles {
+			seeded, _, err := p.nn.Activate(bucket.Inputs)
+			assert.Nil(t, err)
+			assert.Equal(t, actual.Data(), seeded.Data())
+		}
+	}
+
+	other := testNeuralNetwork(2, LayerConfig{NodeCount: 2, Activation: Softmax})
+	assert.NotNil(t, ms.Seed(other))
+
+	b.graph.nodes[1].opType = "HardSigmoid"
+	m.graph = b.graph
+	_, err = ImportONNX(bytes.NewReader(m.marshal()))
+	assert.NotNil(t, err)
+}
+
+func Test_ONNXImportMalformed(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		weights []int64
+		floats  []float32
+		bias    []float32
+		next    []int64
+	}{
+		{"negative dims", []int64{-1, -2}, []float32{1, 2}, nil, nil},
+		{"zero dims", []int64{0, 2}, nil, nil, nil},
+		{"too few weights", []int64{2, 2}, []float32{1, 2, 3}, nil, nil},
+		{"huge dims", []int64{1 << 40, 1 << 40}, []float32{1}, nil, nil},
+		{"bias width", []int64{2, 2}, []float32{1, 2, 3, 4}, []float32{1, 2, 3}, nil},
+		{"next layer inputs", []int64{2, 2}, []float32{1, 2, 3, 4}, nil, []int64{3, 1}},
+	} {
+		b := onnxGraphBuilder{graph: onnxGraph{name: "malformed"}}
+		b.constant("w", c.weights, c.floats...)
+		b.add("MatMul", "h", nil, "x", "w")
+		if c.bias != nil {
+			b.constant("b", []int64{int64(len(c.bias))}, c.bias...)
+			b.add("Add", "h_bias", nil, "h", "b")
+		}
+		if c.next != nil {
+			b.constant("w2", c.next, make([]float32, c.next[0]*c.next[1])...)
+			b.add("MatMul", "y", nil, b.graph.nodes[len(b.graph.nodes)-1].outputs[0], "w2")
+		}
+		b.graph.inputs = []onnxValueInfo{{name: "x", elemType: onnxFloat, dims: []int64{-1, 2}}}
+		m := onnxModel{irVersion: 6, opsetVersion: 11, graph: b.graph}
+
+		_, err := ImportONNX(bytes.NewReader(m.marshal()))
+		assert.Equal(t, ErrShapeMismatch, errors.Cause(err), c.name)
+	}
+}
@@ -1,16 +1,15 @@
 package cogent
 
 import (
-	"encoding/gob"
 	fmt "fmt"
-	"log"
 	"math/rand"
 	"os"
-	"runtime"
 	"strings"
 	"sync"
+	"time"
 
 	math "github.com/chewxy/math32"
+	"github.com/pkg/errors"
 
 	t "gorgonia.org/tensor"
 )
@@ -24,10 +23,18 @@ type particle struct {
 	id                 int
 	fn                 lossFn
 	nn                 *NeuralNetwork
-	blackboard         *sync.Map
+	blackboard         Blackboard
+	best               *bestNetwork
 	swarmID            int
 	r                  *rand.Rand
 	layersTrainingInfo []*layerTrainingInfo
+	logger             Logger
+
+	//iteration is the one train is running, for the logs
+	iteration int
+	//deaths and layerDurations are this iteration's, for IterationStats
+	deaths         int
+	layerDurations Durations
 }
 
 //NewNeuralNetworkConfiguration x
@@ -40,13 +47,13 @@ func NewNeuralNetworkConfiguration(inputCount int, lc ...LayerConfig) *NeuralNet
 	return &nnc
 }
 
-func newParticle(swarmID, particleID int, blackboard *sync.Map, weightRange float32, nnConfig NeuralNetworkConfiguration) *particle {
+func newParticle(swarmID, particleID int, blackboard Blackboard, weightRange float32, nnConfig NeuralNetworkConfiguration) (*particle, error) {
 	// var nnConfig NeuralNetworkConfiguration
 	// var trainingConfig TrainingConfiguration
 
 	fn := LossFns[nnConfig.Loss]
 	if fn == nil {
-		log.Fatalf("Invalid loss type '%d'", nnConfig.Loss)
+		return nil, invalidConfig("unknown loss %d", nnConfig.Loss)
 	}
 	nn := NeuralNetwork{
 		Layers:      make([]LayerData, len(nnConfig.LayerConfigs)),
@@ -107,7 +114,7 @@ func newParticle(swarmID, particleID int, blackboard *sync.Map, weightRange floa
 		blackboard:         blackboard,
 		r:                  r,
 		layersTrainingInfo: ltis,
-	}
+	}, nil
 }
 
 type particleTrainingInfo struct {
@@ -121,6 +128,9 @@ type particleTrainingInfo struct {
 	DeathRate             float32
 	RidgeRegressionWeight float32
 	StoreGlobalBest       bool
+	ParallelBuckets       bool
+	BatchSize             int
+	ReevaluationSize      int
 }
 
 type updateData struct {
@@ -132,64 +142,45 @@ type updateData struct {
 	lossCh                          chan float32
 }
 
-func updatePositionsAndVelocities(ud updateData) {
+//updatePositionsAndVelocities moves each weight towards the particle's, swarm's and global bests
+func updatePositionsAndVelocities(ud updateData) error {
 	p := ud.p
-	bestSwarm := ud.bestSwarm
-	bestGlobal := ud.bestGlobal
 	for i, l := range p.nn.Layers {
-		currentLocal := l.WeightsAndBiases
 		lti := p.layersTrainingInfo[i]
+		current := l.WeightsAndBiases.Data().([]float32)
+		velocities := lti.Velocities.Data().([]float32)
+		jitter := lti.Jitter.Data().([]float32)
+		bestLocal := p.nn.Best.Layers[i].WeightsAndBiases.Data().([]float32)
+		bestSwarm := ud.bestSwarm.Layers[i].WeightsAndBiases.Data().([]float32)
+		bestGlobal := ud.bestGlobal.Layers[i].WeightsAndBiases.Data().([]float32)
+
+		for _, other := range [][]float32{velocities, jitter, bestLocal, bestSwarm, bestGlobal} {
+			if len(other) != len(current) {
+				return shapeMismatch("layer %d has %d weights, a best position has %d", i, len(current), len(other))
+			}
+		}
 
-		bestLocal := p.nn.Best.Layers[i].WeightsAndBiases
-		currentLocalVelocity := lti.Velocities
-		oldVelocityFactor := must(currentLocalVelocity.MulScalar(ud.inertialWeight, true))
-
-		bestLocalDelta := must(bestLocal.Sub(currentLocal))
-		localPositionFactor := must(must(lti.Jitter.MulScalar(ud.cognitiveWeight, true)).Mul(bestLocalDelta))
-
-		bestSwarm := bestSwarm.Layers[i].WeightsAndBiases
-		bestSwarmlDelta := must(bestSwarm.Sub(currentLocal))
-		swarmPositionFactor := must(must(lti.Jitter.MulScalar(ud.socialWeight, true)).Mul(bestSwarmlDelta))
-
-		bestGlobal := bestGlobal.Layers[i].WeightsAndBiases
-		bestGlobalDelta := must(bestGlobal.Sub(currentLocal))
-		globalPositionFactor := must(must(lti.Jitter.MulScalar(ud.globalWeight, true)).Mul(bestGlobalDelta))
-
-		revisedVelocity := must(
-			must(
-				must(
-					oldVelocityFactor.Add(localPositionFactor),
-				).Add(swarmPositionFactor),
-			).Add(globalPositionFactor),
-		)
-		// log.Printf("Layer:%d velocities were\n%+v\nNow\n%+v", i, l.Velocities, revisedVelocity)
-		revisedVelocity.CopyTo(lti.Velocities)
-	}
+		for j, w := range current {
+			velocity := ud.inertialWeight*velocities[j] +
+				ud.cognitiveWeight*jitter[j]*(bestLocal[j]-w) +
+				ud.socialWeight*jitter[j]*(bestSwarm[j]-w) +
+				ud.globalWeight*jitter[j]*(bestGlobal[j]-w)
+			velocities[j] = velocity
 
-	for i, l := range p.nn.Layers {
-		lti := p.layersTrainingInfo[i]
-		revisedPosition := must(l.WeightsAndBiases.Add(lti.Velocities))
-		data := revisedPosition.Data().([]float32)
-		for i, w := range data {
-			clamped := math.Max(-ud.weightRange, math.Min(ud.weightRange, w)) // restriction
-			data[i] = clamped
+			// restriction
+			current[j] = math.Max(-ud.weightRange, math.Min(ud.weightRange, w+velocity))
 		}
-
-		// log.Printf("Layer:%d weights were\n%+v\nNow\n%+v", i, l.WeightsAndBiases, revisedPosition)
-		revisedPosition.CopyTo(l.WeightsAndBiases)
 	}
+	return nil
 }
 
-func (p *particle) train(wg *sync.WaitGroup, maxIterations int, pti particleTrainingInfo, buckets DataBuckets) {
+//train moves the particle towards bestGlobal and bestSwarm, the bests when the iteration
+//started. Every particle in the iteration sees the same ones whichever order they run in.
+func (p *particle) train(maxIterations int, pti particleTrainingInfo, buckets DataBuckets, bestGlobal, bestSwarm Position) error {
 	// start := time.Now()
-	res, ok := p.blackboard.Load(globalKey)
-	checkOk(ok)
-	bestGlobal := res.(Position)
-
-	bestSwarmKey := fmt.Sprintf(swarmKeyFormat, p.swarmID)
-	res, ok = p.blackboard.Load(bestSwarmKey)
-	checkOk(ok)
-	bestSwarm := res.(Position)
+	p.iteration = maxIterations
+	p.deaths = 0
+	p.layerDurations = make(Durations, len(p.nn.Layers))
 
 	for i := range p.nn.Layers {
 		lti := p.layersTrainingInfo[i]
@@ -199,7 +190,7 @@ func (p *particle) train(wg *sync.WaitGroup, maxIterations int, pti particleTrai
 	var kfoldTotalLossAvg, bucketCount float32
 	for testIndex := range buckets {
 		for i := 0; i < maxIterations; i++ {
-			updatePositionsAndVelocities(updateData{
+			err := updatePositionsAndVelocities(updateData{
 				p:               p,
 				bestSwarm:       &bestSwarm,
 				bestGlobal:      &bestGlobal,
@@ -209,7 +200,13 @@ func (p *particle) train(wg *sync.WaitGroup, maxIterations int, pti particleTrai
 				globalWeight:    pti.GlobalWeight,
 				weightRange:     pti.WeightRange,
 			})
-			loss := p.calculateMeanLoss(testIndex, buckets, pti.RidgeRegressionWeight)
+			if err != nil {
+				return err
+			}
+			loss, err := p.calculateMeanLoss(testIndex, p.batch(buckets, pti), pti.RidgeRegressionWeight, pti.ParallelBuckets)
+			if err != nil {
+				return err
+			}
 			kfoldTotalLossAvg += loss.train
 			bucketCount++
 		}
@@ -221,36 +218,56 @@ func (p *particle) train(wg *sync.WaitGroup, maxIterations int, pti particleTrai
 	// }
 	// log.Printf("Iteration:%d <%d:%d> took %s. %f", iteration, p.swarmID, p.id, time.Since(start), kfoldLossAvg)
 
-	wasSwarmBest, wasGlobalBest := p.setBest(kfoldTotalLossAvg, pti.RidgeRegressionWeight, buckets, pti.StoreGlobalBest)
+	wasSwarmBest, wasGlobalBest, err := p.setBest(kfoldTotalLossAvg, pti, buckets)
+	if err != nil {
+		return err
+	}
 	if !wasGlobalBest && !wasSwarmBest {
 		//The best don't die
 		deathChance := p.r.Float32()
 		if deathChance < pti.DeathRate {
-			log.Printf("<Swarm%d:Particle%d> died!", p.swarmID, p.id)
+			p.log().Debug("particle died", p.logFields()...)
+			p.deaths++
 			p.nn.reset(p.r, p.layersTrainingInfo, pti.WeightRange)
 			randomIndex := p.r.Intn(len(buckets))
-			loss := p.calculateMeanLoss(randomIndex, buckets, pti.RidgeRegressionWeight)
-			p.setBest(loss.test, pti.RidgeRegressionWeight, buckets, pti.StoreGlobalBest)
+			loss, err := p.calculateMeanLoss(randomIndex, p.batch(buckets, pti), pti.RidgeRegressionWeight, pti.ParallelBuckets)
+			if err != nil {
+				return err
+			}
+			if _, _, err := p.setBest(loss.test, pti, buckets); err != nil {
+				return err
+			}
 		}
 
 	}
+	return nil
+}
 
-	wg.Done()
+func (p *particle) log() Logger {
+	return loggerOrDefault(p.logger)
 }
 
-func must(d *t.Dense, err error) *t.Dense {
-	if err != nil {
-		log.Fatal(err)
-	}
-	return d
+//logFields prefixes args with the particle's swarm, id and iteration
+func (p *particle) logFields(args ...interface{}) []interface{} {
+	fields := []interface{}{SwarmKey, p.swarmID, ParticleKey, p.id, IterationKey, p.iteration}
+	return append(fields, args...)
 }
 
 //DataBuckets x
 type DataBuckets []*DataBucket
 
-//DataBucketToBuckets x
-func DataBucketToBuckets(k int, dataset *DataBucket) DataBuckets {
+//DataBucketToBuckets shuffles the rows and splits them into k equal buckets, dropping the remainder
+func DataBucketToBuckets(k int, dataset *DataBucket) (DataBuckets, error) {
+	if k <= 0 {
+		return nil, invalidConfig("%d buckets", k)
+	}
+	if dataset == nil || dataset.Inputs == nil || dataset.Outputs == nil {
+		return nil, shapeMismatch("no data to split into buckets")
+	}
 	rowCount := dataset.RowCount()
+	if rowCount == 0 || dataset.Inputs.Shape()[0] != rowCount {
+		return nil, shapeMismatch("%d input rows and %d output rows", dataset.Inputs.Shape()[0], rowCount)
+	}
 	if rowCount < k {
 		k = rowCount
 	}
@@ -287,7 +304,7 @@ func DataBucketToBuckets(k int, dataset *DataBucket) DataBuckets {
 		buckets[i] = bucket
 	}
 
-	return buckets
+	return buckets, nil
 }
 
 //ShuffleDatabucket x
@@ -324,47 +341,78 @@ func ShuffleDatabucket(dataset *DataBucket) {
 	})
 }
 
-func (p *particle) setBest(loss float32, ridgeRegressionWeight float32, buckets DataBuckets, storeGlobalBest bool) (bool, bool) {
+func (p *particle) setBest(loss float32, pti particleTrainingInfo, buckets DataBuckets) (bool, bool, error) {
 	p.nn.CurrentLoss = loss
 	var wasSwarmBest, wasGlobalBest bool
 	localBestLoss := p.nn.Best.Loss
+	if loss < localBestLoss && pti.BatchSize > 0 {
+		// a batch loss is noisy, bests are only ever compared on their reevaluated losses
+		reevaluated, err := p.reevaluate(buckets, pti)
+		if err != nil {
+			return false, false, err
+		}
+		p.log().Debug("reevaluated", p.logFields("batch", loss, LossKey, reevaluated)...)
+		loss = reevaluated
+	}
 	if loss < localBestLoss {
 		blf := "max"
 		if p.nn.Best.Loss != math.MaxFloat32 {
 			blf = fmt.Sprintf("%0.16f", p.nn.Best.Loss)
 		}
-		log.Printf("Local best <Swarm%d:Particle%d> from %s->%f", p.swarmID, p.id, blf, loss)
+		p.log().Debug("local best", p.logFields("from", blf, LossKey, loss)...)
 		updatedBest := nnToPosition(loss, p.nn)
 		p.nn.Best = updatedBest
 
 		bestSwarmKey := fmt.Sprintf(swarmKeyFormat, p.swarmID)
-		res, ok := p.blackboard.Load(bestSwarmKey)
-		checkOk(ok)
-		bestSwarm := res.(Position)
+		bestSwarm, err := loadPosition(p.blackboard, bestSwarmKey)
+		if err != nil {
+			return false, false, err
+		}
 
 		if loss < bestSwarm.Loss {
+			// another particle, maybe in another process, can store a better one first
+			wasSwarmBest, err = p.blackboard.Offer(bestSwarmKey, updatedBest)
+			if err != nil {
+				return false, false, errors.Wrap(err, "can't offer swarm best")
+			}
+		}
+		if wasSwarmBest {
 			blf = "max"
 			if bestSwarm.Loss != math.MaxFloat32 {
 				blf = fmt.Sprintf("%0.16f", bestSwarm.Loss)
 			}
-			log.Printf("Swarm best  <Swarm%d:Particle%d>> from %s->%f", p.swarmID, p.id, blf, loss)
-			p.blackboard.Store(bestSwarmKey, updatedBest)
-			wasSwarmBest = true
+			p.log().Debug("swarm best", p.logFields("from", blf, LossKey, loss)...)
 
-			res, ok = p.blackboard.Load(globalKey)
-			checkOk(ok)
-			bestGlobal := res.(Position)
+			bestGlobal, err := loadPosition(p.blackboard, globalKey)
+			if err != nil {
+				return false, false, err
+			}
 			if loss < bestGlobal.Loss {
+				wasGlobalBest, err = p.blackboard.Offer(globalKey, updatedBest)
+				if err != nil {
+					return false, false, errors.Wrap(err, "can't offer global best")
+				}
+			}
+			if wasGlobalBest {
 				blf := "max"
 				if bestGlobal.Loss != math.MaxFloat32 {
 					blf = fmt.Sprintf("%0.16f", bestGlobal.Loss)
 				}
-				log.Printf("Global best  <Swarm%d:Particle%d> from %s->%f", p.swarmID, p.id, blf, loss)
-				p.blackboard.Store(globalKey, updatedBest)
-				wasGlobalBest = true
 
-				rmse := p.rmse(buckets)
-				testAcc := p.nn.ClassificationAccuracy(buckets, -1)
+				rmse, err := p.rmse(buckets)
+				if err != nil {
+					return false, false, err
+				}
+				testAcc, err := p.nn.ClassificationAccuracy(buckets, -1)
+				if err != nil {
+					return false, false, err
+				}
+				p.nn.Metadata.CreatedAt = time.Now().UTC()
+				p.nn.Metadata.Metrics = TrainingMetrics{
+					Loss:     loss,
+					RMSE:     rmse,
+					Accuracy: testAcc,
+				}
 
 				nodeCounts := make([]string, len(p.nn.Layers))
 				for i, l := range p.nn.Layers {
@@ -378,35 +426,55 @@ func (p *particle) setBest(loss float32, ridgeRegressionWeight float32, buckets
 				filename := sb.String()
 				// log.Printf("Iteration:%d <Swarm%d:Particle%d> New global best found", iteration, p.swarmID, p.id)
 
-				if storeGlobalBest {
-					f, err := os.Create(filename)
-					checkErr(err)
-					e := gob.NewEncoder(f)
-					err = e.Encode(p.nn)
-					checkErr(err)
-					err = f.Close()
-					checkErr(err)
+				if pti.StoreGlobalBest {
+					if err := p.storeGlobalBest(filename); err != nil {
+						return false, false, err
+					}
 				}
-				log.Print(filename)
+				p.log().Info("global best", p.logFields(
+					"from", blf,
+					LossKey, loss,
+					"rmse", rmse,
+					"accuracy", testAcc,
+					"model", filename,
+				)...)
 			}
 		}
 	}
 
-	if wasGlobalBest {
-		p.blackboard.Store(bestGlobalNNKey, *p.nn)
+	if wasGlobalBest && p.best != nil {
+		p.best.offer(p.nn.Clone())
 	}
 
-	return wasSwarmBest, wasGlobalBest
+	return wasSwarmBest, wasGlobalBest, nil
+}
+
+func (p *particle) storeGlobalBest(filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return errors.Wrap(err, "can't store global best")
+	}
+	if err := p.nn.Save(f); err != nil {
+		f.Close()
+		return err
+	}
+	return errors.Wrap(f.Close(), "can't store global best")
 }
 
-func (p *particle) rmse(buckets DataBuckets) float32 {
+func (p *particle) rmse(buckets DataBuckets) (float32, error) {
 	var rmse, count float32
 	for _, bucket := range buckets {
 		expected := bucket.Outputs
-		actual, _ := p.nn.Activate(bucket.Inputs)
+		actual, _, err := p.nn.Activate(bucket.Inputs)
+		if err != nil {
+			return 0, err
+		}
 
 		// log.Printf("In rmse \nExpected:%+v\nActual:%+v", expected, actual)
-		diff := must(actual.Sub(expected))
+		diff, err := actual.Sub(expected)
+		if err != nil {
+			return 0, errors.Wrap(ErrShapeMismatch, err.Error())
+		}
 		backing := diff.Data().([]float32)
 
 		for _, x := range backing {
@@ -415,29 +483,73 @@ func (p *particle) rmse(buckets DataBuckets) float32 {
 		}
 	}
 	rmse = math.Sqrt(rmse / count)
-	return rmse
+	return rmse, nil
 }
 
 type meanLoss struct {
 	test, train, total float32
 }
 
-func (p *particle) calculateMeanLoss(testBucketIndex int, buckets DataBuckets, ridgeRegressionWeight float32) meanLoss {
+//bucketLoss is one bucket's evaluation, kept apart so buckets can be evaluated concurrently
+type bucketLoss struct {
+	loss      float32
+	rowCount  int
+	durations Durations
+	err       error
+}
+
+func (p *particle) bucketLoss(bucket *DataBucket) bucketLoss {
+	expected := DenseToRows(bucket.Outputs)
+	outputs, durations, err := p.nn.Activate(bucket.Inputs)
+	if err != nil {
+		return bucketLoss{err: err}
+	}
+	actual := DenseToRows(outputs)
+	return bucketLoss{
+		loss:      p.fn(expected, actual),
+		rowCount:  len(expected),
+		durations: durations,
+	}
+}
+
+func (p *particle) calculateMeanLoss(testBucketIndex int, buckets DataBuckets, ridgeRegressionWeight float32, parallel bool) (meanLoss, error) {
 	meanLoss := meanLoss{}
 	var testCount, trainCout float32
 
-	for i, bucket := range buckets {
-		expected := DenseToRows(bucket.Outputs)
-		outputs, _ := p.nn.Activate(bucket.Inputs)
-		actual := DenseToRows(outputs)
-		loss := p.fn(expected, actual)
+	// the network is only read while the buckets are evaluated
+	losses := make([]bucketLoss, len(buckets))
+	if parallel && len(buckets) > 1 {
+		wg := sync.WaitGroup{}
+		wg.Add(len(buckets))
+		for i, bucket := range buckets {
+			go func(i int, bucket *DataBucket) {
+				defer wg.Done()
+				losses[i] = p.bucketLoss(bucket)
+			}(i, bucket)
+		}
+		wg.Wait()
+	} else {
+		for i, bucket := range buckets {
+			losses[i] = p.bucketLoss(bucket)
+		}
+	}
+
+	for i, bl := range losses {
+		if bl.err != nil {
+			return meanLoss, bl.err
+		}
+		for l, d := range bl.durations {
+			if l < len(p.layerDurations) {
+				p.layerDurations[l] += d
+			}
+		}
 
 		if testBucketIndex < 0 || i == testBucketIndex {
-			meanLoss.test += loss
-			testCount += float32(len(expected))
+			meanLoss.test += bl.loss
+			testCount += float32(bl.rowCount)
 		} else {
-			meanLoss.train += loss
-			trainCout += float32(len(expected))
+			meanLoss.train += bl.loss
+			trainCout += float32(bl.rowCount)
 		}
 	}
 	meanLoss.total = (meanLoss.test + meanLoss.train) / (testCount + trainCout)
@@ -458,11 +570,5 @@ func (p *particle) calculateMeanLoss(testBucketIndex int, buckets DataBuckets, r
 	meanLoss.test += l2Regularization
 	meanLoss.train += l2Regularization
 	meanLoss.total += l2Regularization
-	return meanLoss
-}
-
-func checkOk(ok bool) {
-	if !ok {
-		runtime.Breakpoint()
-	}
+	return meanLoss, nil
 }
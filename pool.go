@@ -0,0 +1,63 @@
+package cogent
+
+import (
+	"runtime"
+	"sync"
+)
+
+//workerPool runs jobs on a fixed number of goroutines, Train starts one and feeds it every
+//particle each iteration rather than starting a goroutine per particle
+type workerPool struct {
+	jobs chan func()
+	done sync.WaitGroup
+}
+
+//newWorkerPool starts size workers, size below 1 uses GOMAXPROCS
+func newWorkerPool(size int) *workerPool {
+	if size < 1 {
+		size = runtime.GOMAXPROCS(0)
+	}
+	wp := &workerPool{jobs: make(chan func())}
+	wp.done.Add(size)
+	for i := 0; i < size; i++ {
+		go func() {
+			defer wp.done.Done()
+			for job := range wp.jobs {
+				job()
+			}
+		}()
+	}
+	return wp
+}
+
+//run calls fn for 0 to n-1 on the workers and waits for them all
+func (wp *workerPool) run(n int, fn func(i int)) {
+	wg := sync.WaitGroup{}
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		i := i
+		wp.jobs <- func() {
+			defer wg.Done()
+			fn(i)
+		}
+	}
+	wg.Wait()
+}
+
+//close stops the workers once they finish their jobs
+func (wp *workerPool) close() {
+	close(wp.jobs)
+	wp.done.Wait()
+}
+
+//parallelism is how many workers Train uses, never more than there are particles
+func (c TrainingConfiguration) parallelism(particleCount int) int {
+	size := c.Parallelism
+	if size < 1 {
+		size = runtime.GOMAXPROCS(0)
+	}
+	if size > particleCount {
+		size = particleCount
+	}
+	return size
+}
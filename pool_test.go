@@ -0,0 +1,84 @@
+package cogent
+
+import (
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_WorkerPool(t *testing.T) {
+	pool := newWorkerPool(3)
+	var running, most int32
+	ran := make([]bool, 20)
+	pool.run(len(ran), func(i int) {
+		now := atomic.AddInt32(&running, 1)
+		for {
+			seen := atomic.LoadInt32(&most)
+			if now <= seen || atomic.CompareAndSwapInt32(&most, seen, now) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		ran[i] = true
+		atomic.AddInt32(&running, -1)
+	})
+	pool.close()
+	for i, r := range ran {
+		assert.True(t, r, i)
+	}
+	assert.True(t, most <= 3, most)
+
+	tc := DefaultTrainingConfig
+	assert.Equal(t, runtime.GOMAXPROCS(0), tc.parallelism(1000))
+	assert.Equal(t, 1, tc.parallelism(1))
+	tc.Parallelism = 4
+	assert.Equal(t, 4, tc.parallelism(8))
+	tc.Parallelism = -1
+	assert.NotNil(t, tc.validate())
+}
+
+//Test_ParallelTraining is meant for go test -race, particles share the bests while they train
+func Test_ParallelTraining(t *testing.T) {
+	data := Data{
+		{Inputs: []float32{0, 0}, Outputs: []float32{0, 1}},
+		{Inputs: []float32{0, 1}, Outputs: []float32{1, 0}},
+		{Inputs: []float32{1, 0}, Outputs: []float32{1, 0}},
+		{Inputs: []float32{1, 1}, Outputs: []float32{0, 1}},
+	}
+	bucket, err := DataToTensorDataBucket(data, true)
+	assert.Nil(t, err)
+	buckets, err := DataBucketToBuckets(4, bucket)
+	assert.Nil(t, err)
+
+	for _, parallelBuckets := range []bool{false, true} {
+		config := basicMathConfig(data)
+		config.SwarmCount = 3
+		config.Islands = &IslandConfiguration{MigrationInterval: 2, Migrants: 1, Topology: RandomTopology}
+		tc := DefaultTrainingConfig
+		tc.MaxIterations = 6
+		tc.ProbablityOfDeath = 0.2
+		tc.Parallelism = 3
+		tc.ParallelBuckets = parallelBuckets
+		ms, err := NewMultiSwarm(config, tc)
+		assert.Nil(t, err)
+		ms.SetLogger(NopLogger)
+		assert.Nil(t, ms.Train(buckets, true))
+
+		// whatever order the particles ran in, the shared bests are the lowest they found
+		global := ms.bestLoss(globalKey)
+		for _, s := range ms.swarms {
+			swarm := ms.bestLoss(fmt.Sprintf(swarmKeyFormat, s.id))
+			assert.True(t, global <= swarm)
+			for _, p := range s.particles {
+				assert.True(t, swarm <= p.nn.Best.Loss)
+			}
+		}
+		nn, err := ms.Best()
+		assert.Nil(t, err)
+		assert.Equal(t, global, nn.Best.Loss)
+	}
+}
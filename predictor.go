@@ -0,0 +1,156 @@
+package cogent
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+
+	t "gorgonia.org/tensor"
+)
+
+//PredictorConfiguration x
+type PredictorConfiguration struct {
+	//MaxBatchSize splits large calls into micro-batches of at most this many rows, 0 means no limit
+	MaxBatchSize int
+
+	//RowEncoder turns a raw table row into network inputs for PredictTable
+	RowEncoder func(row []string) ([]float32, error)
+
+	//OnPrediction is called after every prediction with its stats
+	OnPrediction func(stats PredictionStats)
+}
+
+//PredictionStats x
+type PredictionStats struct {
+	Rows           int
+	Batches        int
+	Latency        time.Duration
+	LayerDurations Durations
+}
+
+//Predictor runs inference on a snapshot of a trained network and is safe for concurrent use
+type Predictor struct {
+	nn          *NeuralNetwork
+	config      PredictorConfiguration
+	inputCount  int
+	outputCount int
+}
+
+//NewPredictor x
+func NewPredictor(nn *NeuralNetwork, config PredictorConfiguration) (*Predictor, error) {
+	if nn == nil {
+		return nil, errors.New("no neural network to predict with")
+	}
+
+	layerCount := len(nn.Layers)
+	if layerCount == 0 {
+		return nil, invalidConfig("neural network has no layers")
+	}
+
+	if config.MaxBatchSize < 0 {
+		return nil, invalidConfig("max batch size %d", config.MaxBatchSize)
+	}
+
+	p := &Predictor{
+		nn:          nn.Clone(),
+		config:      config,
+		inputCount:  nn.Layers[0].WeightsAndBiases.Shape()[0] - 1,
+		outputCount: nn.Layers[layerCount-1].WeightsAndBiases.Shape()[1],
+	}
+	return p, nil
+}
+
+//InputCount is the number of floats expected per row, excluding the bias column
+func (p *Predictor) InputCount() int {
+	return p.inputCount
+}
+
+//OutputCount x
+func (p *Predictor) OutputCount() int {
+	return p.outputCount
+}
+
+//Predict x
+func (p *Predictor) Predict(inputs [][]float32) ([][]float32, error) {
+	outputs, _, err := p.PredictWithStats(inputs)
+	return outputs, err
+}
+
+//PredictTable encodes raw table rows with the configured RowEncoder and predicts them
+func (p *Predictor) PredictTable(table [][]string) ([][]float32, error) {
+	if p.config.RowEncoder == nil {
+		return nil, errors.New("no row encoder configured")
+	}
+
+	inputs := make([][]float32, len(table))
+	for r, row := range table {
+		encoded, err := p.config.RowEncoder(row)
+		if err != nil {
+			return nil, errors.Wrapf(err, "can't encode row %d", r)
+		}
+		inputs[r] = encoded
+	}
+	return p.Predict(inputs)
+}
+
+//PredictWithStats x
+func (p *Predictor) PredictWithStats(inputs [][]float32) ([][]float32, PredictionStats, error) {
+	start := time.Now()
+	rowCount := len(inputs)
+	stats := PredictionStats{
+		Rows:           rowCount,
+		LayerDurations: make(Durations, len(p.nn.Layers)),
+	}
+
+	for r, row := range inputs {
+		if len(row) != p.inputCount {
+			msg := "row %d has %d inputs, network expects %d"
+			return nil, stats, shapeMismatch(msg, r, len(row), p.inputCount)
+		}
+	}
+
+	batchSize := p.config.MaxBatchSize
+	if batchSize <= 0 || batchSize > rowCount {
+		batchSize = rowCount
+	}
+
+	outputs := make([][]float32, 0, rowCount)
+	for offset := 0; offset < rowCount; offset += batchSize {
+		end := offset + batchSize
+		if end > rowCount {
+			end = rowCount
+		}
+
+		activated, durations, err := p.nn.Activate(p.batchToDense(inputs[offset:end]))
+		if err != nil {
+			return nil, stats, err
+		}
+		for i, d := range durations {
+			stats.LayerDurations[i] += d
+		}
+		outputs = append(outputs, DenseToRows(activated)...)
+		stats.Batches++
+	}
+
+	stats.Latency = time.Since(start)
+	if p.config.OnPrediction != nil {
+		p.config.OnPrediction(stats)
+	}
+	return outputs, stats, nil
+}
+
+func (p *Predictor) batchToDense(batch [][]float32) *t.Dense {
+	colCount := p.inputCount + 1
+	backing := make([]float32, len(batch)*colCount)
+	for r, row := range batch {
+		offset := r * colCount
+		copy(backing[offset:], row)
+		backing[offset+p.inputCount] = 1
+	}
+
+	return t.New(
+		t.Of(Float),
+		t.WithShape(len(batch), colCount),
+		t.WithBacking(backing),
+	)
+}
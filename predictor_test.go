@@ -0,0 +1,103 @@
+package cogent
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testNeuralNetwork(inputCount int, lc ...LayerConfig) *NeuralNetwork {
+	config := NewNeuralNetworkConfiguration(inputCount, lc...)
+	p, err := newParticle(0, 0, NewMemoryBlackboard(), 1, *config)
+	if err != nil {
+		panic(err)
+	}
+	return p.nn
+}
+
+func Test_Predictor(t *testing.T) {
+	nn := testNeuralNetwork(3,
+		LayerConfig{NodeCount: 4, Activation: ReLU},
+		LayerConfig{NodeCount: 2, Activation: Softmax},
+	)
+	data := Data{
+		{Inputs: []float32{0, 0, 1}, Outputs: []float32{1, 0}},
+		{Inputs: []float32{0, 1, 0}, Outputs: []float32{0, 1}},
+		{Inputs: []float32{1, 0, 0}, Outputs: []float32{1, 0}},
+		{Inputs: []float32{1, 1, 1}, Outputs: []float32{0, 1}},
+		{Inputs: []float32{0.5, 0.25, 1}, Outputs: []float32{0, 1}},
+	}
+	bucket, err := DataToTensorDataBucket(data, true)
+	assert.Nil(t, err)
+	expectedDense, _, err := nn.Activate(bucket.Inputs)
+	assert.Nil(t, err)
+	expected := DenseToRows(expectedDense)
+
+	inputs := make([][]float32, len(data))
+	for i, row := range data {
+		inputs[i] = row.Inputs
+	}
+
+	for _, batchSize := range []int{0, 1, 2, 5, 10} {
+		var reported PredictionStats
+		p, err := NewPredictor(nn, PredictorConfiguration{
+			MaxBatchSize: batchSize,
+			OnPrediction: func(stats PredictionStats) {
+				reported = stats
+			},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, 3, p.InputCount())
+		assert.Equal(t, 2, p.OutputCount())
+
+		actual, stats, err := p.PredictWithStats(inputs)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, actual, "batch size %d", batchSize)
+		assert.Equal(t, len(data), stats.Rows)
+		assert.Equal(t, stats, reported)
+		if batchSize == 2 {
+			assert.Equal(t, 3, stats.Batches)
+		}
+	}
+
+	p, err := NewPredictor(nn, PredictorConfiguration{})
+	assert.Nil(t, err)
+	_, err = p.Predict([][]float32{{1, 2}})
+	assert.NotNil(t, err)
+	_, err = p.PredictTable([][]string{{"1", "2", "3"}})
+	assert.NotNil(t, err)
+
+	_, err = NewPredictor(nil, PredictorConfiguration{})
+	assert.NotNil(t, err)
+}
+
+func Test_PredictorConcurrent(t *testing.T) {
+	nn := testNeuralNetwork(2,
+		LayerConfig{NodeCount: 6, Activation: LeakyReLU},
+		LayerConfig{NodeCount: 2, Activation: Softmax},
+	)
+	p, err := NewPredictor(nn, PredictorConfiguration{MaxBatchSize: 3})
+	assert.Nil(t, err)
+
+	inputs := [][]float32{{0, 0}, {0, 1}, {1, 0}, {1, 1}}
+	expected, err := p.Predict(inputs)
+	assert.Nil(t, err)
+
+	// mutating the source network must not affect the predictor
+	fillTensorWithRandom(nil, nn.Layers[0].WeightsAndBiases, 1, 10)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				actual, err := p.Predict(inputs)
+				assert.Nil(t, err)
+				assert.Equal(t, expected, actual)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,166 @@
+package serve
+
+import (
+	"os"
+	"time"
+
+	"github.com/delaneyj/cogent"
+	"github.com/pkg/errors"
+)
+
+//model is one loaded model file, replaced as a whole when the file changes
+type model struct {
+	name      string
+	path      string
+	modTime   time.Time
+	size      int64
+	loadedAt  time.Time
+	nn        *cogent.NeuralNetwork
+	predictor *cogent.Predictor
+
+	inputColumns  []string
+	outputColumns []string
+}
+
+//ModelInfo is what the metadata endpoints return about a model
+type ModelInfo struct {
+	Name          string                 `json:"name"`
+	Path          string                 `json:"path"`
+	LoadedAt      time.Time              `json:"loadedAt"`
+	CreatedAt     time.Time              `json:"createdAt"`
+	Metrics       cogent.TrainingMetrics `json:"metrics"`
+	Loss          cogent.LossMode        `json:"loss"`
+	InputCount    int                    `json:"inputCount"`
+	OutputCount   int                    `json:"outputCount"`
+	Layers        []LayerInfo            `json:"layers"`
+	InputColumns  []string               `json:"inputColumns,omitempty"`
+	OutputColumns []string               `json:"outputColumns,omitempty"`
+	//Encoded is set when the model saved its encoders, so rows can be sent as raw column values
+	Encoded bool `json:"encoded"`
+}
+
+//LayerInfo x
+type LayerInfo struct {
+	NodeCount  int                   `json:"nodeCount"`
+	Activation cogent.ActivationMode `json:"activation"`
+}
+
+func loadModel(name, path string, config cogent.PredictorConfiguration) (*model, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't open model '%s'", name)
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't stat model '%s'", name)
+	}
+	nn, err := cogent.Load(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't load model '%s'", name)
+	}
+
+	m := &model{
+		name:          name,
+		path:          path,
+		modTime:       fi.ModTime(),
+		size:          fi.Size(),
+		loadedAt:      time.Now().UTC(),
+		nn:            nn,
+		inputColumns:  columnNames(nn.Metadata.Columns, cogent.InputRole),
+		outputColumns: columnNames(nn.Metadata.Columns, cogent.OutputRole),
+	}
+	if nn.Metadata.InputEncoder != nil {
+		config.RowEncoder = nn.Metadata.InputEncoder.TransformRow
+	}
+	m.predictor, err = cogent.NewPredictor(nn, config)
+	if err != nil {
+		return nil, errors.Wrapf(err, "model '%s'", name)
+	}
+	return m, nil
+}
+
+//changed is whether the file on disk is no longer the one loaded
+func (m *model) changed() (bool, error) {
+	fi, err := os.Stat(m.path)
+	if err != nil {
+		return false, errors.Wrapf(err, "can't stat model '%s'", m.name)
+	}
+	return !fi.ModTime().Equal(m.modTime) || fi.Size() != m.size, nil
+}
+
+func (m *model) info() ModelInfo {
+	info := ModelInfo{
+		Name:          m.name,
+		Path:          m.path,
+		LoadedAt:      m.loadedAt,
+		CreatedAt:     m.nn.Metadata.CreatedAt,
+		Metrics:       m.nn.Metadata.Metrics,
+		Loss:          m.nn.Loss,
+		InputCount:    m.predictor.InputCount(),
+		OutputCount:   m.predictor.OutputCount(),
+		Layers:        make([]LayerInfo, len(m.nn.Layers)),
+		InputColumns:  m.inputColumns,
+		OutputColumns: m.outputColumns,
+		Encoded:       m.nn.Metadata.InputEncoder != nil,
+	}
+	for i, l := range m.nn.Layers {
+		info.Layers[i] = LayerInfo{NodeCount: l.NodeCount, Activation: l.Activation}
+	}
+	return info
+}
+
+//tableRows orders each row's values by the model's input columns for its RowEncoder
+func (m *model) tableRows(rows []map[string]string) ([][]string, error) {
+	if m.nn.Metadata.InputEncoder == nil {
+		return nil, errors.Errorf("model '%s' has no saved encoders, send inputs instead of rows", m.name)
+	}
+
+	table := make([][]string, len(rows))
+	for r, row := range rows {
+		record := make([]string, len(m.inputColumns))
+		for c, name := range m.inputColumns {
+			value, ok := row[name]
+			if !ok {
+				return nil, errors.Errorf("row %d has no '%s' column", r, name)
+			}
+			record[c] = value
+		}
+		table[r] = record
+	}
+	return table, nil
+}
+
+//decode maps the outputs back to column values when the model saved its output encoder
+func (m *model) decode(outputs [][]float32) ([]map[string]string, error) {
+	if m.nn.Metadata.OutputEncoder == nil {
+		return nil, nil
+	}
+
+	table, err := m.nn.Metadata.OutputEncoder.InverseTransform(outputs)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't decode predictions")
+	}
+	decoded := make([]map[string]string, len(table))
+	for r, record := range table {
+		row := make(map[string]string, len(record))
+		for c, value := range record {
+			if c < len(m.outputColumns) {
+				row[m.outputColumns[c]] = value
+			}
+		}
+		decoded[r] = row
+	}
+	return decoded, nil
+}
+
+func columnNames(columns []cogent.ColumnSpec, role cogent.ColumnRole) []string {
+	var names []string
+	for _, c := range columns {
+		if c.Role == role {
+			names = append(names, c.Name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,381 @@
+package serve
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/delaneyj/cogent"
+	"github.com/pkg/errors"
+)
+
+//DefaultReloadInterval is how often Watch checks the model files for changes
+const DefaultReloadInterval = 5 * time.Second
+
+//MaxRequestBytes caps the size of a prediction request body
+const MaxRequestBytes = 32 << 20
+
+//Configuration x
+type Configuration struct {
+	//Models maps the name each model is served under to its model file
+	Models map[string]string
+	//ReloadInterval is how often Watch checks for changed model files, defaults to DefaultReloadInterval
+	ReloadInterval time.Duration
+	//MaxBatchSize is passed to every model's Predictor
+	MaxBatchSize int
+	//Logger defaults to cogent.DefaultLogger
+	Logger cogent.Logger
+	//Metrics, when set, observes every prediction and is served on /metrics
+	Metrics *cogent.Metrics
+}
+
+//Server serves predictions from saved models over HTTP and JSON:
+//
+//	GET  /healthz                        liveness and the models loaded
+//	GET  /models                         every model's metadata
+//	GET  /models/{name}                  one model's metadata
+//	POST /models/{name}/predict          predict one row
+//	POST /models/{name}/predict/batch    predict many rows
+//	GET  /metrics                        Prometheus metrics, when configured
+type Server struct {
+	config Configuration
+	logger cogent.Logger
+
+	mu     sync.RWMutex
+	models map[string]*model
+}
+
+//New loads every model in the configuration, failing if any can't be loaded
+func New(config Configuration) (*Server, error) {
+	if len(config.Models) == 0 {
+		return nil, errors.Wrap(cogent.ErrInvalidConfig, "no models to serve")
+	}
+	if config.ReloadInterval <= 0 {
+		config.ReloadInterval = DefaultReloadInterval
+	}
+
+	s := &Server{
+		config: config,
+		logger: config.Logger,
+		models: make(map[string]*model, len(config.Models)),
+	}
+	if s.logger == nil {
+		s.logger = cogent.DefaultLogger
+	}
+	for name, path := range config.Models {
+		m, err := loadModel(name, path, s.predictorConfiguration())
+		if err != nil {
+			return nil, err
+		}
+		s.models[name] = m
+		s.logger.Info("model loaded", "model", name, "path", path)
+	}
+	return s, nil
+}
+
+func (s *Server) predictorConfiguration() cogent.PredictorConfiguration {
+	config := cogent.PredictorConfiguration{MaxBatchSize: s.config.MaxBatchSize}
+	if s.config.Metrics != nil {
+		config.OnPrediction = s.config.Metrics.ObservePrediction
+	}
+	return config
+}
+
+func (s *Server) model(name string) (*model, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	m, ok := s.models[name]
+	return m, ok
+}
+
+//Reload loads every model whose file changed since it was loaded. A model that fails to
+//load keeps serving the previous version and the first error is returned.
+func (s *Server) Reload() error {
+	s.mu.RLock()
+	loaded := make([]*model, 0, len(s.models))
+	for _, m := range s.models {
+		loaded = append(loaded, m)
+	}
+	s.mu.RUnlock()
+
+	var firstErr error
+	for _, m := range loaded {
+		changed, err := m.changed()
+		if err == nil && changed {
+			var reloaded *model
+			reloaded, err = loadModel(m.name, m.path, s.predictorConfiguration())
+			if err == nil {
+				s.mu.Lock()
+				s.models[m.name] = reloaded
+				s.mu.Unlock()
+				s.logger.Info("model reloaded", "model", m.name, "path", m.path)
+			}
+		}
+		if err != nil {
+			s.logger.Warn("model not reloaded", "model", m.name, "err", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+//Watch calls Reload every ReloadInterval until ctx is done
+func (s *Server) Watch(ctx context.Context) {
+	ticker := time.NewTicker(s.config.ReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Reload()
+		}
+	}
+}
+
+//PredictRequest is a single row, either raw column values for a model with saved encoders or encoded inputs
+type PredictRequest struct {
+	Row    map[string]string `json:"row,omitempty"`
+	Inputs []float32         `json:"inputs,omitempty"`
+}
+
+//PredictResponse has the network's outputs and, when the model saved its output encoder, the decoded columns
+type PredictResponse struct {
+	Outputs []float32         `json:"outputs"`
+	Decoded map[string]string `json:"decoded,omitempty"`
+}
+
+//BatchPredictRequest x
+type BatchPredictRequest struct {
+	Rows   []map[string]string `json:"rows,omitempty"`
+	Inputs [][]float32         `json:"inputs,omitempty"`
+}
+
+//BatchPredictResponse x
+type BatchPredictResponse struct {
+	Predictions []PredictResponse `json:"predictions"`
+}
+
+//HealthResponse x
+type HealthResponse struct {
+	Status string   `json:"status"`
+	Models []string `json:"models"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+//httpError is an error with the status to respond with
+type httpError struct {
+	status int
+	err    error
+}
+
+func (e httpError) Error() string {
+	return e.err.Error()
+}
+
+func badRequest(err error) error {
+	return httpError{http.StatusBadRequest, err}
+}
+
+//ServeHTTP routes to the endpoints listed on Server
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+	parts := strings.Split(path, "/")
+
+	var (
+		response interface{}
+		err      error
+	)
+	switch {
+	case path == "healthz":
+		response, err = s.health(r)
+	case path == "metrics" && s.config.Metrics != nil:
+		s.config.Metrics.ServeHTTP(w, r)
+		return
+	case path == "models":
+		response, err = s.list(r)
+	case parts[0] == "models" && len(parts) == 2:
+		response, err = s.info(r, parts[1])
+	case parts[0] == "models" && len(parts) == 3 && parts[2] == "predict":
+		response, err = s.predict(r, parts[1])
+	case parts[0] == "models" && len(parts) == 4 && parts[2] == "predict" && parts[3] == "batch":
+		response, err = s.predictBatch(r, parts[1])
+	default:
+		err = httpError{http.StatusNotFound, errors.Errorf("no endpoint '%s'", r.URL.Path)}
+	}
+
+	status := http.StatusOK
+	if err != nil {
+		status = http.StatusInternalServerError
+		if he, ok := err.(httpError); ok {
+			status = he.status
+		} else if errors.Cause(err) == cogent.ErrShapeMismatch {
+			status = http.StatusBadRequest
+		}
+		if status == http.StatusInternalServerError {
+			s.logger.Error("request failed", "path", r.URL.Path, "err", err)
+		}
+		response = errorResponse{err.Error()}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+func requireMethod(r *http.Request, method string) error {
+	if r.Method != method {
+		return httpError{http.StatusMethodNotAllowed, errors.Errorf("%s needs %s", r.URL.Path, method)}
+	}
+	return nil
+}
+
+func (s *Server) requireModel(name string) (*model, error) {
+	m, ok := s.model(name)
+	if !ok {
+		return nil, httpError{http.StatusNotFound, errors.Errorf("no model '%s'", name)}
+	}
+	return m, nil
+}
+
+func (s *Server) health(r *http.Request) (interface{}, error) {
+	if err := requireMethod(r, http.MethodGet); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := HealthResponse{Status: "ok"}
+	for name := range s.models {
+		res.Models = append(res.Models, name)
+	}
+	sort.Strings(res.Models)
+	return res, nil
+}
+
+func (s *Server) list(r *http.Request) (interface{}, error) {
+	if err := requireMethod(r, http.MethodGet); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	infos := make([]ModelInfo, 0, len(s.models))
+	for _, m := range s.models {
+		infos = append(infos, m.info())
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos, nil
+}
+
+func (s *Server) info(r *http.Request, name string) (interface{}, error) {
+	if err := requireMethod(r, http.MethodGet); err != nil {
+		return nil, err
+	}
+	m, err := s.requireModel(name)
+	if err != nil {
+		return nil, err
+	}
+	return m.info(), nil
+}
+
+func decodeRequest(r *http.Request, v interface{}) error {
+	if err := requireMethod(r, http.MethodPost); err != nil {
+		return err
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, MaxRequestBytes)).Decode(v); err != nil {
+		return badRequest(errors.Wrap(err, "can't decode request"))
+	}
+	return nil
+}
+
+func (s *Server) predict(r *http.Request, name string) (interface{}, error) {
+	req := PredictRequest{}
+	if err := decodeRequest(r, &req); err != nil {
+		return nil, err
+	}
+	m, err := s.requireModel(name)
+	if err != nil {
+		return nil, err
+	}
+
+	batch := BatchPredictRequest{}
+	switch {
+	case req.Row != nil && req.Inputs != nil:
+		return nil, badRequest(errors.New("send either row or inputs"))
+	case req.Row != nil:
+		batch.Rows = []map[string]string{req.Row}
+	case req.Inputs != nil:
+		batch.Inputs = [][]float32{req.Inputs}
+	}
+
+	res, err := s.run(m, batch)
+	if err != nil {
+		return nil, err
+	}
+	return res.Predictions[0], nil
+}
+
+func (s *Server) predictBatch(r *http.Request, name string) (interface{}, error) {
+	req := BatchPredictRequest{}
+	if err := decodeRequest(r, &req); err != nil {
+		return nil, err
+	}
+	m, err := s.requireModel(name)
+	if err != nil {
+		return nil, err
+	}
+	return s.run(m, req)
+}
+
+//run predicts either the raw rows or the encoded inputs
+func (s *Server) run(m *model, req BatchPredictRequest) (*BatchPredictResponse, error) {
+	var (
+		outputs [][]float32
+		err     error
+	)
+	switch {
+	case len(req.Rows) > 0 && len(req.Inputs) > 0:
+		return nil, badRequest(errors.New("send either rows or inputs"))
+	case len(req.Rows) > 0:
+		table, tableErr := m.tableRows(req.Rows)
+		if tableErr != nil {
+			return nil, badRequest(tableErr)
+		}
+		outputs, err = m.predictor.PredictTable(table)
+		if err != nil {
+			// raw values the encoders can't handle are the caller's to fix
+			err = badRequest(err)
+		}
+	case len(req.Inputs) > 0:
+		outputs, err = m.predictor.Predict(req.Inputs)
+	default:
+		return nil, badRequest(errors.New("nothing to predict"))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	decoded, err := m.decode(outputs)
+	if err != nil {
+		return nil, err
+	}
+	res := &BatchPredictResponse{Predictions: make([]PredictResponse, len(outputs))}
+	for i, o := range outputs {
+		res.Predictions[i].Outputs = o
+		if decoded != nil {
+			res.Predictions[i].Decoded = decoded[i]
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,158 @@
+package serve
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/delaneyj/cogent"
+	"github.com/stretchr/testify/assert"
+)
+
+//trainModel writes a small model with its encoders to path
+func trainModel(t *testing.T, path string, hidden int) {
+	columns := []cogent.ColumnSpec{
+		{Name: "x", Encoding: cogent.NormalizedEncodingMode, Role: cogent.InputRole},
+		{Name: "colour", Encoding: cogent.OneHotEncodingMode, Role: cogent.InputRole},
+		{Name: "label", Encoding: cogent.OneHotEncodingMode, Role: cogent.OutputRole},
+	}
+	csv := "x,colour,label\n1,red,yes\n2,blue,no\n3,red,yes\n4,blue,no\n"
+	loaded, err := cogent.LoadCSV(strings.NewReader(csv), columns, cogent.CSVOptions{Logger: cogent.NopLogger})
+	assert.Nil(t, err)
+	buckets, err := loaded.Buckets(2)
+	assert.Nil(t, err)
+
+	config := cogent.MultiSwarmConfiguration{
+		NeuralNetworkConfiguration: cogent.NeuralNetworkConfiguration{
+			Loss:       cogent.CrossLoss,
+			InputCount: len(loaded.Data[0].Inputs),
+			LayerConfigs: []cogent.LayerConfig{
+				{NodeCount: hidden, Activation: cogent.ReLU},
+				{NodeCount: 2, Activation: cogent.Softmax},
+			},
+		},
+		SwarmCount:    1,
+		ParticleCount: 4,
+	}
+	tc := cogent.DefaultTrainingConfig
+	tc.MaxIterations = 3
+	ms, err := cogent.NewMultiSwarm(config, tc)
+	assert.Nil(t, err)
+	ms.SetLogger(cogent.NopLogger)
+	assert.Nil(t, ms.Train(buckets, false))
+
+	nn, err := ms.Best()
+	assert.Nil(t, err)
+	nn.Metadata.Columns = columns
+	nn.Metadata.InputEncoder = loaded.InputEncoder
+	nn.Metadata.OutputEncoder = loaded.OutputEncoder
+
+	buf := bytes.Buffer{}
+	assert.Nil(t, nn.Save(&buf))
+	assert.Nil(t, os.WriteFile(path, buf.Bytes(), 0644))
+}
+
+func request(t *testing.T, s http.Handler, method, path, body string, v interface{}) int {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if v != nil {
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), v), w.Body.String())
+	}
+	return w.Code
+}
+
+func Test_Server(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "labels.nn")
+	trainModel(t, path, 3)
+
+	_, err := New(Configuration{})
+	assert.NotNil(t, err)
+	_, err = New(Configuration{Models: map[string]string{"missing": filepath.Join(dir, "missing.nn")}})
+	assert.NotNil(t, err)
+
+	metrics := cogent.NewMetrics("")
+	s, err := New(Configuration{
+		Models:  map[string]string{"labels": path},
+		Logger:  cogent.NopLogger,
+		Metrics: metrics,
+	})
+	assert.Nil(t, err)
+
+	health := HealthResponse{}
+	assert.Equal(t, http.StatusOK, request(t, s, "GET", "/healthz", "", &health))
+	assert.Equal(t, HealthResponse{Status: "ok", Models: []string{"labels"}}, health)
+
+	infos := []ModelInfo{}
+	assert.Equal(t, http.StatusOK, request(t, s, "GET", "/models", "", &infos))
+	assert.Len(t, infos, 1)
+	info := infos[0]
+	assert.Equal(t, "labels", info.Name)
+	assert.Equal(t, []string{"x", "colour"}, info.InputColumns)
+	assert.Equal(t, []string{"label"}, info.OutputColumns)
+	assert.Equal(t, 3, info.InputCount)
+	assert.Equal(t, 2, info.OutputCount)
+	assert.Equal(t, 3, info.Layers[0].NodeCount)
+	assert.True(t, info.Encoded)
+
+	one := PredictResponse{}
+	assert.Equal(t, http.StatusOK, request(t, s, "POST", "/models/labels/predict", `{"row":{"x":"2","colour":"blue"}}`, &one))
+	assert.Len(t, one.Outputs, 2)
+	assert.Contains(t, []string{"yes", "no"}, one.Decoded["label"])
+
+	encoded := PredictResponse{}
+	assert.Equal(t, http.StatusOK, request(t, s, "POST", "/models/labels/predict", `{"inputs":[0,0,1]}`, &encoded))
+	assert.Len(t, encoded.Outputs, 2)
+
+	batch := BatchPredictResponse{}
+	body := `{"rows":[{"x":"1","colour":"red"},{"x":"4","colour":"blue"},{"x":"2","colour":"red"}]}`
+	assert.Equal(t, http.StatusOK, request(t, s, "POST", "/models/labels/predict/batch", body, &batch))
+	assert.Len(t, batch.Predictions, 3)
+
+	for _, c := range []struct {
+		method, path, body string
+		status             int
+	}{
+		{"GET", "/models/nope", "", http.StatusNotFound},
+		{"GET", "/nope", "", http.StatusNotFound},
+		{"GET", "/models/labels/predict", "", http.StatusMethodNotAllowed},
+		{"POST", "/models/labels/predict", `not json`, http.StatusBadRequest},
+		{"POST", "/models/labels/predict", `{}`, http.StatusBadRequest},
+		{"POST", "/models/labels/predict", `{"row":{"x":"1"}}`, http.StatusBadRequest},
+		{"POST", "/models/labels/predict", `{"row":{"x":"abc","colour":"red"}}`, http.StatusBadRequest},
+		{"POST", "/models/labels/predict", `{"inputs":[1,2]}`, http.StatusBadRequest},
+	} {
+		failed := errorResponse{}
+		assert.Equal(t, c.status, request(t, s, c.method, c.path, c.body, &failed), c.path+" "+c.body)
+		assert.NotEmpty(t, failed.Error)
+	}
+
+	r := httptest.NewRequest("GET", "/metrics", nil)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	assert.Contains(t, w.Body.String(), "cogent_predicted_rows_total 5\n")
+
+	// an unchanged file isn't reloaded, a retrained one with a wider hidden layer is
+	assert.Nil(t, s.Reload())
+	loadedAt := info.LoadedAt
+	trainModel(t, path, 5)
+	later := time.Now().Add(time.Second)
+	assert.Nil(t, os.Chtimes(path, later, later))
+	assert.Nil(t, s.Reload())
+	assert.Equal(t, http.StatusOK, request(t, s, "GET", "/models/labels", "", &info))
+	assert.Equal(t, 5, info.Layers[0].NodeCount)
+	assert.True(t, info.LoadedAt.After(loadedAt))
+
+	// a broken file keeps the previous model serving
+	assert.Nil(t, os.WriteFile(path, []byte("not a model"), 0644))
+	assert.NotNil(t, s.Reload())
+	assert.Equal(t, http.StatusOK, request(t, s, "GET", "/models/labels", "", &info))
+	assert.Equal(t, 5, info.Layers[0].NodeCount)
+}
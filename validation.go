@@ -0,0 +1,275 @@
+package cogent
+
+import (
+	"fmt"
+
+	math "github.com/chewxy/math32"
+)
+
+//DiagnosticSeverity is whether a diagnostic stops training or only warns about it
+type DiagnosticSeverity int
+
+//DiagnosticSeverities
+const (
+	DiagnosticWarning DiagnosticSeverity = iota
+	DiagnosticError
+)
+
+var diagnosticSeverityNames = []string{
+	"warning",
+	"error",
+}
+
+//String x
+func (s DiagnosticSeverity) String() string {
+	return modeName(diagnosticSeverityNames, int(s))
+}
+
+//MarshalText x
+func (s DiagnosticSeverity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+//Diagnostic is one problem Validate found, Field is the configuration path it's about
+type Diagnostic struct {
+	Severity DiagnosticSeverity `json:"severity"`
+	Field    string             `json:"field"`
+	Message  string             `json:"message"`
+}
+
+//String x
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s %s: %s", d.Severity, d.Field, d.Message)
+}
+
+//DiagnosticsError is the first error diagnostic wrapped in ErrInvalidConfig, or nil when there are only warnings
+func DiagnosticsError(diagnostics []Diagnostic) error {
+	for _, d := range diagnostics {
+		if d.Severity == DiagnosticError {
+			return invalidConfig("%s: %s", d.Field, d.Message)
+		}
+	}
+	return nil
+}
+
+type diagnostics []Diagnostic
+
+func (ds *diagnostics) errorf(field, format string, args ...interface{}) {
+	*ds = append(*ds, Diagnostic{DiagnosticError, field, fmt.Sprintf(format, args...)})
+}
+
+func (ds *diagnostics) warnf(field, format string, args ...interface{}) {
+	*ds = append(*ds, Diagnostic{DiagnosticWarning, field, fmt.Sprintf(format, args...)})
+}
+
+//probabilityActivations output values between 0 and 1
+var probabilityActivations = map[ActivationMode]bool{
+	Sigmoid:      true,
+	Softmax:      true,
+	SplitSoftmax: true,
+}
+
+//positiveActivations never output zero or less, which the divergence losses take logs or roots of
+var positiveActivations = map[ActivationMode]bool{
+	Sigmoid:      true,
+	Softmax:      true,
+	SplitSoftmax: true,
+	SoftPlus:     true,
+	Gaussian:     true,
+}
+
+var divergenceLosses = map[LossMode]bool{
+	HellingerDistanceLoss:                    true,
+	KullbackLeiblerDivergenceLoss:            true,
+	GeneralizedKullbackLeiblerDivergenceLoss: true,
+	ItakuraSaitoDistanceLoss:                 true,
+}
+
+//Validate checks the configuration, and the data when it isn't nil, before training starts.
+//Mismatches that would panic or give meaningless losses mid-training are errors, risky
+//combinations are warnings. data is expected to have its bias column, as Train does.
+func (c MultiSwarmConfiguration) Validate(data *DataBucket) []Diagnostic {
+	ds := diagnostics{}
+	if c.SwarmCount <= 0 {
+		ds.errorf("SwarmCount", "%d swarms, needs at least 1", c.SwarmCount)
+	}
+	if c.ParticleCount <= 0 {
+		ds.errorf("ParticleCount", "%d particles, needs at least 1", c.ParticleCount)
+	} else if c.ParticleCount == 1 {
+		ds.warnf("ParticleCount", "a single particle per swarm only learns from its own and the global best")
+	}
+	if c.FirstSwarmID < 0 {
+		ds.errorf("FirstSwarmID", "%d, can't be negative", c.FirstSwarmID)
+	}
+	if c.Islands != nil {
+		c.diagnoseIslands(&ds)
+	}
+
+	nnc := c.NeuralNetworkConfiguration
+	nnc.diagnose(&ds)
+	if data != nil && len(nnc.LayerConfigs) > 0 {
+		nnc.diagnoseData(&ds, data)
+	}
+	return ds
+}
+
+//diagnoseIslands checks the island model against the swarms it runs on
+func (c MultiSwarmConfiguration) diagnoseIslands(ds *diagnostics) {
+	ic := c.Islands
+	if ic.MigrationInterval < 0 {
+		ds.errorf("Islands.MigrationInterval", "%d, can't be negative", ic.MigrationInterval)
+	}
+	if ic.Migrants < 0 {
+		ds.errorf("Islands.Migrants", "%d, can't be negative", ic.Migrants)
+	} else if c.ParticleCount > 0 && ic.Migrants >= c.ParticleCount {
+		ds.errorf("Islands.Migrants", "%d migrants, an island only has %d particles", ic.Migrants, c.ParticleCount)
+	}
+	if ic.Topology < RingTopology || ic.Topology > FullyConnectedTopology {
+		ds.errorf("Islands.Topology", "unknown migration topology %d", ic.Topology)
+	}
+	if c.SwarmCount > 0 && len(ic.Swarms) > c.SwarmCount {
+		ds.errorf("Islands.Swarms", "hyperparameters for %d islands, there are %d swarms", len(ic.Swarms), c.SwarmCount)
+	}
+	for i, h := range ic.Swarms {
+		if h.InertialWeight < 0 || h.CognitiveWeight < 0 || h.SocialWeight < 0 || h.ProbablityOfDeath < 0 {
+			ds.errorf(fmt.Sprintf("Islands.Swarms[%d]", i), "hyperparameters can't be negative")
+		}
+	}
+	switch {
+	case c.SwarmCount == 1:
+		ds.warnf("Islands", "a single island has nowhere to migrate to")
+	case ic.MigrationInterval == 0 || ic.Migrants == 0:
+		ds.warnf("Islands", "islands never migrate, they only evolve independently")
+	}
+}
+
+//diagnose checks the network on its own
+func (c NeuralNetworkConfiguration) diagnose(ds *diagnostics) {
+	if _, ok := LossFns[c.Loss]; !ok {
+		ds.errorf("Loss", "unknown loss %d", c.Loss)
+	}
+	if c.InputCount <= 0 {
+		ds.errorf("InputCount", "%d inputs, needs at least 1", c.InputCount)
+	}
+	if len(c.LayerConfigs) == 0 {
+		ds.errorf("LayerConfigs", "no layers")
+		return
+	}
+
+	lastLayerIndex := len(c.LayerConfigs) - 1
+	for i, l := range c.LayerConfigs {
+		field := fmt.Sprintf("LayerConfigs[%d]", i)
+		if l.NodeCount <= 0 {
+			ds.errorf(field+".NodeCount", "%d nodes, needs at least 1", l.NodeCount)
+		}
+		if _, ok := activations[l.Activation]; !ok {
+			ds.errorf(field+".Activation", "unknown activation %d", l.Activation)
+			continue
+		}
+		if i == lastLayerIndex {
+			continue
+		}
+		switch l.Activation {
+		case Softmax, SplitSoftmax:
+			ds.warnf(field+".Activation", "%s on a hidden layer squashes what the next layer sees, it's meant for the output layer", l.Activation)
+		}
+	}
+
+	last := c.LayerConfigs[lastLayerIndex]
+	field := fmt.Sprintf("LayerConfigs[%d]", lastLayerIndex)
+	switch {
+	case last.Activation == SplitSoftmax && last.NodeCount%2 != 0:
+		ds.errorf(field+".NodeCount", "SplitSoftmax splits the outputs into two halves, %d nodes is odd", last.NodeCount)
+	case last.Activation == Softmax && last.NodeCount == 1:
+		ds.errorf(field+".NodeCount", "Softmax over a single node always outputs 1, use Sigmoid")
+	}
+
+	switch {
+	case c.Loss == CrossLoss && !probabilityActivations[last.Activation]:
+		ds.errorf("Loss", "CrossLoss needs probabilities but the output layer uses %s, use Sigmoid, Softmax or SplitSoftmax", last.Activation)
+	case divergenceLosses[c.Loss] && !positiveActivations[last.Activation]:
+		ds.warnf("Loss", "%s takes logs or roots of the outputs but %s can output zero or less", c.Loss, last.Activation)
+	}
+}
+
+//diagnoseData checks the data lines up with the network and suits the loss
+func (c NeuralNetworkConfiguration) diagnoseData(ds *diagnostics, data *DataBucket) {
+	if data.Inputs == nil || data.Outputs == nil {
+		ds.errorf("data", "no inputs or outputs")
+		return
+	}
+	is, os := data.Inputs.Shape(), data.Outputs.Shape()
+	if len(is) != 2 || len(os) != 2 {
+		ds.errorf("data", "inputs and outputs aren't rows and columns")
+		return
+	}
+	if is[0] == 0 || is[0] != os[0] {
+		ds.errorf("data", "%d input rows and %d output rows", is[0], os[0])
+		return
+	}
+
+	rowCount := is[0]
+	switch is[1] {
+	case c.InputCount + 1:
+	case c.InputCount:
+		ds.errorf("InputCount", "data has %d input columns but no bias column, use DataToTensorDataBucket(data, true)", is[1])
+	default:
+		ds.errorf("InputCount", "%d inputs but the data has %d input columns, %d without the bias", c.InputCount, is[1], is[1]-1)
+	}
+
+	lastLayerIndex := len(c.LayerConfigs) - 1
+	last := c.LayerConfigs[lastLayerIndex]
+	if os[1] != last.NodeCount {
+		field := fmt.Sprintf("LayerConfigs[%d].NodeCount", lastLayerIndex)
+		ds.errorf(field, "%d output nodes but the data has %d output columns", last.NodeCount, os[1])
+		return
+	}
+
+	outputs := data.Outputs.Data().([]float32)
+	nonBinary, unnormalised := 0, 0
+	for r := 0; r < rowCount; r++ {
+		row := outputs[r*os[1] : (r+1)*os[1]]
+		sum := float32(0)
+		for _, x := range row {
+			if x != 0 && x != 1 {
+				nonBinary++
+			}
+			sum += x
+		}
+		if math.Abs(sum-1) > 0.001 {
+			unnormalised++
+		}
+	}
+	if c.Loss == CrossLoss && nonBinary > 0 {
+		ds.warnf("Loss", "CrossLoss treats every target other than 1 as 0, %d targets aren't 0 or 1", nonBinary)
+	}
+	if last.Activation == Softmax && unnormalised > 0 {
+		field := fmt.Sprintf("LayerConfigs[%d].Activation", lastLayerIndex)
+		ds.warnf(field, "Softmax outputs sum to 1 but %d of %d rows of targets don't, use Sigmoid for multi-label outputs", unnormalised, rowCount)
+	}
+
+	inputs := data.Inputs.Data().([]float32)
+	for col := 0; col < is[1]-1 && col < c.InputCount; col++ {
+		constant := true
+		for r := 1; r < rowCount && constant; r++ {
+			constant = inputs[r*is[1]+col] == inputs[col]
+		}
+		if constant && rowCount > 1 {
+			ds.warnf("data", "input column %d is the same on every row and adds nothing", col)
+		}
+	}
+
+	weights, in := 0, c.InputCount
+	for _, l := range c.LayerConfigs {
+		weights += (in + 1) * l.NodeCount
+		in = l.NodeCount
+	}
+	if weights > rowCount*os[1] {
+		ds.warnf("LayerConfigs", "%d weights and biases for %d targets, the network can memorise the data", weights, rowCount*os[1])
+	}
+}
+
+//validate returns the first error Validate finds without data, warnings don't stop training
+func (c MultiSwarmConfiguration) validate() error {
+	return DiagnosticsError(c.Validate(nil))
+}